@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"mime/multipart"
@@ -13,23 +14,37 @@ import (
 
 	"koperasi-app/internal/config"
 	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
 	"koperasi-app/internal/scheduler"
 	"koperasi-app/internal/services"
+	"koperasi-app/internal/utils"
 )
 
 // App struct
 type App struct {
-	ctx                 context.Context
-	db                  *database.DB
-	config              *config.Config
-	customerService     *services.CustomerService
-	referralService     *services.ReferralService
-	documentService     *services.DocumentService
-	loanService         *services.LoanService
-	notificationService *services.NotificationService
-	auditService        *services.AuditService
-	userService         *services.UserService
-	scheduler           *scheduler.Scheduler
+	ctx                   context.Context
+	db                    *database.DB
+	config                *config.Config
+	encryptor             *utils.Encryptor
+	customerService       *services.CustomerService
+	referralService       *services.ReferralService
+	documentService       *services.DocumentService
+	loanService           *services.LoanService
+	loanProductService    *services.LoanProductService
+	paymentService        *services.PaymentService
+	loanApprovalService   *services.LoanApprovalService
+	creditScoringService  *services.CreditScoringService
+	collectibilityService *services.CollectibilityService
+	portfolioService      *services.PortfolioService
+	recoveryService       *services.RecoveryService
+	savingsService        *services.SavingsService
+	accountingService     *services.AccountingService
+	reportingService      *services.ReportingService
+	shuService            *services.SHUService
+	notificationService   *services.NotificationService
+	auditService          *services.AuditService
+	userService           *services.UserService
+	scheduler             *scheduler.Scheduler
 }
 
 // NewApp creates a new App application struct
@@ -61,17 +76,35 @@ func (a *App) startup(ctx context.Context) {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Load PII encryption key
+	encryptor, err := db.LoadEncryptor(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load encryption key: %v", err)
+	}
+	a.encryptor = encryptor
+
 	// Initialize services
-	a.customerService = services.NewCustomerService(db)
+	a.customerService = services.NewCustomerService(db, encryptor)
 	a.referralService = services.NewReferralService(db)
 	a.documentService = services.NewDocumentService(db, cfg)
-	a.loanService = services.NewLoanService(db)
+	a.creditScoringService = services.NewCreditScoringService(db, cfg, encryptor)
+	a.loanService = services.NewLoanService(db, cfg, a.creditScoringService)
+	a.loanProductService = services.NewLoanProductService(db)
 	a.notificationService = services.NewNotificationService(db, cfg)
 	a.auditService = services.NewAuditService(db)
+	a.paymentService = services.NewPaymentService(db, a.auditService)
+	a.loanApprovalService = services.NewLoanApprovalService(db, cfg, a.loanService)
 	a.userService = services.NewUserService(db)
+	a.collectibilityService = services.NewCollectibilityService(db)
+	a.portfolioService = services.NewPortfolioService(db)
+	a.recoveryService = services.NewRecoveryService(db)
+	a.savingsService = services.NewSavingsService(db, cfg)
+	a.accountingService = services.NewAccountingService(db)
+	a.reportingService = services.NewReportingService(db, cfg)
+	a.shuService = services.NewSHUService(db, cfg)
 
 	// Initialize scheduler
-	a.scheduler = scheduler.NewScheduler(db, cfg, a.notificationService, a.loanService)
+	a.scheduler = scheduler.NewScheduler(db, cfg, encryptor, a.notificationService, a.loanService, a.auditService, a.collectibilityService, a.savingsService)
 	if err := a.scheduler.Start(); err != nil {
 		log.Printf("Failed to start scheduler: %v", err)
 	}
@@ -511,9 +544,13 @@ func (a *App) GetPendingDocuments() (*services.APIResponse, error) {
 
 // Configuration APIs
 func (a *App) GetConfig() (*services.APIResponse, error) {
+	// Never hand the encryption key to the frontend
+	cfg := *a.config
+	cfg.Security.EncryptionKey = ""
+
 	return &services.APIResponse{
 		Success: true,
-		Data:    a.config,
+		Data:    cfg,
 	}, nil
 }
 
@@ -526,6 +563,64 @@ func (a *App) UpdateConfig(configData string) (*services.APIResponse, error) {
 		}, nil
 	}
 
+	// Security settings are not editable from the UI
+	newConfig.Security = a.config.Security
+
+	if err := newConfig.Penalty.Validate(); err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := newConfig.EarlySettlement.Validate(); err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := newConfig.Approval.Validate(); err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := newConfig.Delinquency.Validate(); err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := newConfig.Affordability.Validate(); err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := newConfig.Scoring.Validate(); err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := newConfig.Savings.Validate(); err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := newConfig.SavingsInterest.Validate(); err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+	if err := newConfig.SHU.Validate(); err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
 	if err := newConfig.Save(); err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -533,7 +628,8 @@ func (a *App) UpdateConfig(configData string) (*services.APIResponse, error) {
 		}, nil
 	}
 
-	a.config = &newConfig
+	// Update in place so services and the scheduler see the new settings
+	*a.config = newConfig
 
 	return &services.APIResponse{
 		Success: true,
@@ -543,7 +639,7 @@ func (a *App) UpdateConfig(configData string) (*services.APIResponse, error) {
 
 // Database seeding
 func (a *App) SeedDatabase() (*services.APIResponse, error) {
-	seeder := database.NewSeeder(a.db)
+	seeder := database.NewSeeder(a.db, a.encryptor)
 	if err := seeder.SeedAll(); err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -581,10 +677,7 @@ func (a *App) HealthCheck() (*services.APIResponse, error) {
 func (a *App) CreateLoan(req services.LoanCreateRequest) (*services.APIResponse, error) {
 	loan, err := a.loanService.CreateLoan(req)
 	if err != nil {
-		return &services.APIResponse{
-			Success: false,
-			Message: err.Error(),
-		}, nil
+		return loanErrorResponse(err), nil
 	}
 
 	return &services.APIResponse{
@@ -594,6 +687,22 @@ func (a *App) CreateLoan(req services.LoanCreateRequest) (*services.APIResponse,
 	}, nil
 }
 
+// loanErrorResponse reports a failed loan operation, including the
+// affordability calculation when that is why it failed
+func loanErrorResponse(err error) *services.APIResponse {
+	response := &services.APIResponse{
+		Success: false,
+		Message: err.Error(),
+	}
+
+	var affordabilityErr *services.AffordabilityError
+	if errors.As(err, &affordabilityErr) {
+		response.Data = affordabilityErr.Assessment
+	}
+
+	return response
+}
+
 func (a *App) GetLoan(id string) (*services.APIResponse, error) {
 	loan, err := a.loanService.GetLoan(id)
 	if err != nil {
@@ -612,10 +721,7 @@ func (a *App) GetLoan(id string) (*services.APIResponse, error) {
 func (a *App) UpdateLoan(id string, req services.LoanUpdateRequest) (*services.APIResponse, error) {
 	loan, err := a.loanService.UpdateLoan(id, req)
 	if err != nil {
-		return &services.APIResponse{
-			Success: false,
-			Message: err.Error(),
-		}, nil
+		return loanErrorResponse(err), nil
 	}
 
 	return &services.APIResponse{
@@ -640,8 +746,58 @@ func (a *App) ListLoans(req services.LoanListRequest) (*services.APIResponse, er
 	}, nil
 }
 
-func (a *App) DisburseLoan(id string) (*services.APIResponse, error) {
-	loan, err := a.loanService.DisburseLoan(id)
+func (a *App) SubmitLoanForApproval(loanID, userID string) (*services.APIResponse, error) {
+	loan, err := a.loanApprovalService.SubmitLoanForApproval(loanID, userID)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan submitted for approval",
+		Data:    loan,
+	}, nil
+}
+
+func (a *App) ApproveLoan(req services.LoanApprovalRequest) (*services.APIResponse, error) {
+	loan, err := a.loanApprovalService.ApproveLoan(req)
+	if err != nil {
+		return loanErrorResponse(err), nil
+	}
+
+	message := "Loan approved successfully"
+	if loan.Status != models.LoanStatusApproved {
+		message = "Approval recorded; the loan needs another approver"
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    loan,
+	}, nil
+}
+
+func (a *App) RejectLoan(req services.LoanApprovalRequest) (*services.APIResponse, error) {
+	loan, err := a.loanApprovalService.RejectLoan(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan rejected",
+		Data:    loan,
+	}, nil
+}
+
+func (a *App) DisburseLoan(id, userID string) (*services.APIResponse, error) {
+	loan, err := a.loanService.DisburseLoan(id, userID)
 	if err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -656,8 +812,133 @@ func (a *App) DisburseLoan(id string) (*services.APIResponse, error) {
 	}, nil
 }
 
+func (a *App) GetLoanTimeline(loanID string) (*services.APIResponse, error) {
+	timeline, err := a.loanService.GetLoanTimeline(loanID)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    timeline,
+	}, nil
+}
+
+func (a *App) ScoreCustomer(customerID string) (*services.APIResponse, error) {
+	score, err := a.creditScoringService.ScoreCustomer(customerID)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Customer scored successfully",
+		Data:    score,
+	}, nil
+}
+
+func (a *App) QuoteEarlySettlement(loanID string, date time.Time) (*services.APIResponse, error) {
+	quote, err := a.loanService.QuoteEarlySettlement(loanID, date)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    quote,
+	}, nil
+}
+
+func (a *App) SettleLoan(req services.LoanSettlementRequest) (*services.APIResponse, error) {
+	loan, err := a.loanService.SettleLoan(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan settled successfully",
+		Data:    loan,
+	}, nil
+}
+
+func (a *App) RestructureLoan(req services.LoanRestructureRequest) (*services.APIResponse, error) {
+	loan, err := a.loanService.RestructureLoan(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan restructured successfully",
+		Data:    loan,
+	}, nil
+}
+
+func (a *App) GetLoanRestructurings(loanID string) (*services.APIResponse, error) {
+	restructurings, err := a.loanService.GetLoanRestructurings(loanID)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    restructurings,
+	}, nil
+}
+
+func (a *App) WriteOffLoan(req services.LoanWriteOffRequest) (*services.APIResponse, error) {
+	loan, err := a.loanService.WriteOffLoan(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan written off successfully",
+		Data:    loan,
+	}, nil
+}
+
+func (a *App) SimulateLoan(req services.LoanSimulationRequest) (*services.APIResponse, error) {
+	simulation, err := a.loanService.SimulateLoan(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan simulation calculated successfully",
+		Data:    simulation,
+	}, nil
+}
+
 func (a *App) PayInstallment(req services.InstallmentPaymentRequest) (*services.APIResponse, error) {
-	installment, err := a.loanService.PayInstallment(req)
+	payment, err := a.paymentService.PayInstallment(req)
 	if err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -668,12 +949,12 @@ func (a *App) PayInstallment(req services.InstallmentPaymentRequest) (*services.
 	return &services.APIResponse{
 		Success: true,
 		Message: "Payment processed successfully",
-		Data:    installment,
+		Data:    payment,
 	}, nil
 }
 
-func (a *App) GetOverdueInstallments() (*services.APIResponse, error) {
-	installments, err := a.loanService.GetOverdueInstallments()
+func (a *App) PayLoan(req services.LoanPaymentRequest) (*services.APIResponse, error) {
+	result, err := a.paymentService.PayLoan(req)
 	if err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -683,13 +964,13 @@ func (a *App) GetOverdueInstallments() (*services.APIResponse, error) {
 
 	return &services.APIResponse{
 		Success: true,
-		Data:    installments,
+		Message: "Payment processed successfully",
+		Data:    result,
 	}, nil
 }
 
-// Notification APIs
-func (a *App) TestNotification(req services.NotificationTestRequest) (*services.APIResponse, error) {
-	err := a.notificationService.TestNotification(req)
+func (a *App) ReversePayment(req services.PaymentReversalRequest) (*services.APIResponse, error) {
+	reversal, err := a.paymentService.ReversePayment(req)
 	if err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -699,12 +980,13 @@ func (a *App) TestNotification(req services.NotificationTestRequest) (*services.
 
 	return &services.APIResponse{
 		Success: true,
-		Message: "Test notification sent successfully",
+		Message: "Payment reversed successfully",
+		Data:    reversal,
 	}, nil
 }
 
-func (a *App) SendNotification(req services.SendNotificationRequest) (*services.APIResponse, error) {
-	notification, err := a.notificationService.SendNotification(req)
+func (a *App) GetPayment(id string) (*services.APIResponse, error) {
+	payment, err := a.paymentService.GetPayment(id)
 	if err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -714,13 +996,12 @@ func (a *App) SendNotification(req services.SendNotificationRequest) (*services.
 
 	return &services.APIResponse{
 		Success: true,
-		Message: "Notification sent successfully",
-		Data:    notification,
+		Data:    payment,
 	}, nil
 }
 
-func (a *App) GetPendingNotifications() (*services.APIResponse, error) {
-	notifications, err := a.notificationService.GetPendingNotifications()
+func (a *App) GetLoanPayments(loanID string) (*services.APIResponse, error) {
+	payments, err := a.paymentService.GetLoanPayments(loanID)
 	if err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -730,21 +1011,28 @@ func (a *App) GetPendingNotifications() (*services.APIResponse, error) {
 
 	return &services.APIResponse{
 		Success: true,
-		Data:    notifications,
+		Data:    payments,
 	}, nil
 }
 
-// Scheduler APIs
-func (a *App) GetSchedulerStatus() (*services.APIResponse, error) {
-	status := a.scheduler.GetStatus()
+func (a *App) PostRecovery(req services.RecoveryRequest) (*services.APIResponse, error) {
+	recovery, err := a.recoveryService.PostRecovery(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
 	return &services.APIResponse{
 		Success: true,
-		Data:    status,
+		Message: "Recovery posted successfully",
+		Data:    recovery,
 	}, nil
 }
 
-func (a *App) TriggerReminderNotifications() (*services.APIResponse, error) {
-	err := a.scheduler.TriggerReminderNotifications()
+func (a *App) GetLoanRecoveries(loanID string) (*services.APIResponse, error) {
+	recoveries, err := a.recoveryService.GetLoanRecoveries(loanID)
 	if err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -754,12 +1042,12 @@ func (a *App) TriggerReminderNotifications() (*services.APIResponse, error) {
 
 	return &services.APIResponse{
 		Success: true,
-		Message: "Reminder notifications triggered successfully",
+		Data:    recoveries,
 	}, nil
 }
 
-func (a *App) TriggerPendingNotifications() (*services.APIResponse, error) {
-	err := a.scheduler.TriggerPendingNotifications()
+func (a *App) GetOverdueInstallments() (*services.APIResponse, error) {
+	installments, err := a.loanService.GetOverdueInstallments()
 	if err != nil {
 		return &services.APIResponse{
 			Success: false,
@@ -769,7 +1057,572 @@ func (a *App) TriggerPendingNotifications() (*services.APIResponse, error) {
 
 	return &services.APIResponse{
 		Success: true,
-		Message: "Pending notifications processed successfully",
+		Data:    installments,
+	}, nil
+}
+
+// Loan Product APIs
+func (a *App) CreateLoanProduct(req services.LoanProductCreateRequest) (*services.APIResponse, error) {
+	product, err := a.loanProductService.CreateLoanProduct(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan product created successfully",
+		Data:    product,
+	}, nil
+}
+
+func (a *App) GetLoanProduct(id string) (*services.APIResponse, error) {
+	product, err := a.loanProductService.GetLoanProduct(id)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    product,
+	}, nil
+}
+
+func (a *App) UpdateLoanProduct(id string, req services.LoanProductUpdateRequest) (*services.APIResponse, error) {
+	product, err := a.loanProductService.UpdateLoanProduct(id, req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan product updated successfully",
+		Data:    product,
+	}, nil
+}
+
+func (a *App) ListLoanProducts(activeOnly bool) (*services.APIResponse, error) {
+	products, err := a.loanProductService.ListLoanProducts(activeOnly)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    products,
+	}, nil
+}
+
+func (a *App) DeleteLoanProduct(id string) (*services.APIResponse, error) {
+	err := a.loanProductService.DeleteLoanProduct(id)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan product deleted successfully",
+	}, nil
+}
+
+// Savings APIs
+func (a *App) OpenSavingsAccount(req services.SavingsAccountOpenRequest) (*services.APIResponse, error) {
+	account, err := a.savingsService.OpenSavingsAccount(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Savings account opened successfully",
+		Data:    account,
+	}, nil
+}
+
+func (a *App) DepositSavings(req services.SavingsTransactionRequest) (*services.APIResponse, error) {
+	transaction, err := a.savingsService.Deposit(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Deposit posted successfully",
+		Data:    transaction,
+	}, nil
+}
+
+func (a *App) WithdrawSavings(req services.SavingsTransactionRequest) (*services.APIResponse, error) {
+	transaction, err := a.savingsService.Withdraw(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Withdrawal posted successfully",
+		Data:    transaction,
+	}, nil
+}
+
+func (a *App) GetSavingsAccount(id string) (*services.APIResponse, error) {
+	account, err := a.savingsService.GetSavingsAccount(id)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    account,
+	}, nil
+}
+
+func (a *App) GetMemberSavings(customerID string) (*services.APIResponse, error) {
+	accounts, err := a.savingsService.GetMemberSavings(customerID)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    accounts,
+	}, nil
+}
+
+func (a *App) GetSavingsStatement(req services.SavingsStatementRequest) (*services.APIResponse, error) {
+	statement, err := a.savingsService.GetSavingsStatement(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    statement,
+	}, nil
+}
+
+func (a *App) GetWajibArrears() (*services.APIResponse, error) {
+	arrears, err := a.savingsService.GetWajibArrears()
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    arrears,
+	}, nil
+}
+
+// Accounting APIs
+func (a *App) ListGLAccounts() (*services.APIResponse, error) {
+	accounts, err := a.accountingService.ListGLAccounts()
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    accounts,
+	}, nil
+}
+
+func (a *App) CreateGLAccount(req services.GLAccountCreateRequest) (*services.APIResponse, error) {
+	account, err := a.accountingService.CreateGLAccount(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Account created successfully",
+		Data:    account,
+	}, nil
+}
+
+func (a *App) PostJournalEntry(req services.JournalEntryRequest) (*services.APIResponse, error) {
+	entry, err := a.accountingService.PostJournalEntry(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Journal entry posted successfully",
+		Data:    entry,
+	}, nil
+}
+
+func (a *App) GetJournalEntry(id string) (*services.APIResponse, error) {
+	entry, err := a.accountingService.GetJournalEntry(id)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    entry,
+	}, nil
+}
+
+func (a *App) ListJournalEntries(req services.JournalEntryListRequest) (*services.APIResponse, error) {
+	entries, err := a.accountingService.ListJournalEntries(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    entries,
+	}, nil
+}
+
+// SHU APIs
+func (a *App) CalculateSHU(req services.SHURequest) (*services.APIResponse, error) {
+	distribution, err := a.shuService.CalculateSHU(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    distribution,
+	}, nil
+}
+
+func (a *App) PostSHU(req services.SHURequest) (*services.APIResponse, error) {
+	distribution, err := a.shuService.PostSHU(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "SHU posted successfully",
+		Data:    distribution,
+	}, nil
+}
+
+func (a *App) GetSHUDistribution(year int) (*services.APIResponse, error) {
+	distribution, err := a.shuService.GetSHUDistribution(year)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    distribution,
+	}, nil
+}
+
+// Notification APIs
+func (a *App) TestNotification(req services.NotificationTestRequest) (*services.APIResponse, error) {
+	err := a.notificationService.TestNotification(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Test notification sent successfully",
+	}, nil
+}
+
+func (a *App) SendNotification(req services.SendNotificationRequest) (*services.APIResponse, error) {
+	notification, err := a.notificationService.SendNotification(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Notification sent successfully",
+		Data:    notification,
+	}, nil
+}
+
+func (a *App) GetPendingNotifications() (*services.APIResponse, error) {
+	notifications, err := a.notificationService.GetPendingNotifications()
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    notifications,
+	}, nil
+}
+
+// Scheduler APIs
+func (a *App) GetSchedulerStatus() (*services.APIResponse, error) {
+	status := a.scheduler.GetStatus()
+	return &services.APIResponse{
+		Success: true,
+		Data:    status,
+	}, nil
+}
+
+func (a *App) TriggerReminderNotifications() (*services.APIResponse, error) {
+	err := a.scheduler.TriggerReminderNotifications()
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Reminder notifications triggered successfully",
+	}, nil
+}
+
+func (a *App) TriggerPendingNotifications() (*services.APIResponse, error) {
+	err := a.scheduler.TriggerPendingNotifications()
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Pending notifications processed successfully",
+	}, nil
+}
+
+func (a *App) TriggerLoanStatusUpdate() (*services.APIResponse, error) {
+	err := a.scheduler.TriggerLoanStatusUpdate()
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan statuses updated successfully",
+	}, nil
+}
+
+func (a *App) TriggerCollectibilityClassification() (*services.APIResponse, error) {
+	err := a.scheduler.TriggerCollectibilityClassification()
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Loan collectibility classified successfully",
+	}, nil
+}
+
+func (a *App) TriggerSavingsInterestAccrual() (*services.APIResponse, error) {
+	err := a.scheduler.TriggerSavingsInterestAccrual()
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Savings interest accrued successfully",
+	}, nil
+}
+
+func (a *App) TriggerSavingsInterestCrediting() (*services.APIResponse, error) {
+	err := a.scheduler.TriggerSavingsInterestCrediting()
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Savings interest credited successfully",
+	}, nil
+}
+
+// Reporting APIs
+func (a *App) GetPortfolioReport(req services.PortfolioRequest) (*services.APIResponse, error) {
+	report, err := a.portfolioService.GetPortfolioReport(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    report,
+	}, nil
+}
+
+func (a *App) GetRecoveryReport(req services.RecoveryReportRequest) (*services.APIResponse, error) {
+	report, err := a.recoveryService.GetRecoveryReport(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    report,
+	}, nil
+}
+
+func (a *App) GetCollectibilityReport(date time.Time) (*services.APIResponse, error) {
+	report, err := a.collectibilityService.GetCollectibilityReport(date)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    report,
+	}, nil
+}
+
+func (a *App) GetTrialBalance(req services.FinancialReportRequest) (*services.APIResponse, error) {
+	report, err := a.reportingService.TrialBalance(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    report,
+	}, nil
+}
+
+func (a *App) GetBalanceSheet(req services.FinancialReportRequest) (*services.APIResponse, error) {
+	report, err := a.reportingService.BalanceSheet(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    report,
+	}, nil
+}
+
+func (a *App) GetIncomeStatement(req services.FinancialReportRequest) (*services.APIResponse, error) {
+	report, err := a.reportingService.IncomeStatement(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Data:    report,
+	}, nil
+}
+
+func (a *App) ExportFinancialReport(req services.ReportExportRequest) (*services.APIResponse, error) {
+	path, err := a.reportingService.ExportReport(req)
+	if err != nil {
+		return &services.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		}, nil
+	}
+
+	return &services.APIResponse{
+		Success: true,
+		Message: "Report exported successfully",
+		Data:    path,
 	}, nil
 }
 
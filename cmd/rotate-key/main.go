@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"filippo.io/age"
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/services"
+	"koperasi-app/internal/utils"
+)
+
+// rotate-key replaces the PII encryption key and re-encrypts every customer.
+//
+// The new key is staged in <key file>.new before any data changes. If the run
+// is interrupted, running the command again picks up the staged key and only
+// re-encrypts rows that are not on the new key version yet.
+func main() {
+	userEmail := flag.String("user", "", "email of the user performing the rotation (for the audit log)")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	keyFile := cfg.Security.EncryptionKeyFile
+	if keyFile == "" {
+		log.Fatalf("No encryption_key_file configured")
+	}
+	stagedKeyFile := keyFile + ".new"
+
+	db, err := database.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.RunMigrations(); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	oldIdentity, err := loadCurrentIdentity(cfg.Security)
+	if err != nil {
+		log.Fatalf("Failed to load current encryption key: %v", err)
+	}
+
+	newIdentity, err := loadOrStageIdentity(stagedKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to prepare new encryption key: %v", err)
+	}
+
+	var userID *string
+	if *userEmail != "" {
+		var id string
+		if err := db.QueryRow("SELECT id FROM users WHERE email = ?", *userEmail).Scan(&id); err != nil {
+			log.Fatalf("Failed to find user %s: %v", *userEmail, err)
+		}
+		userID = &id
+	}
+
+	rotationService := services.NewKeyRotationService(db, services.NewAuditService(db))
+	result, err := rotationService.RotateCustomerKeys(oldIdentity, newIdentity, userID)
+	if err != nil {
+		log.Fatalf("Key rotation failed, no data was changed: %v", err)
+	}
+
+	log.Printf("Re-encrypted %d customers (%d were already on the new key)", result.CustomersRotated, result.CustomersSkipped)
+
+	if err := installNewKey(cfg, stagedKeyFile, result.OldVersion); err != nil {
+		log.Fatalf("Data is encrypted with key version %d but installing the key failed: %v", result.NewVersion, err)
+	}
+
+	log.Printf("Encryption key rotated from version %d to %d", result.OldVersion, result.NewVersion)
+}
+
+func loadCurrentIdentity(sec config.SecurityConfig) (*age.X25519Identity, error) {
+	if sec.EncryptionKey != "" {
+		return utils.ParseIdentity(sec.EncryptionKey)
+	}
+	return utils.LoadIdentityFile(sec.EncryptionKeyFile)
+}
+
+func loadOrStageIdentity(path string) (*age.X25519Identity, error) {
+	identity, err := utils.LoadIdentityFile(path)
+	if err == nil {
+		log.Printf("Resuming rotation with staged key %s", path)
+		return identity, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	identity, err = age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate encryption identity: %v", err)
+	}
+
+	if err := utils.WriteIdentityFile(path, identity); err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// installNewKey moves the staged key into place. The old key file is kept as
+// <key file>.v<version>.retired so older database backups stay readable.
+func installNewKey(cfg *config.Config, stagedKeyFile string, oldVersion int) error {
+	keyFile := cfg.Security.EncryptionKeyFile
+
+	if cfg.Security.EncryptionKey != "" {
+		// Move away from the inline key to the key file
+		cfg.Security.EncryptionKey = ""
+		if err := cfg.Save(); err != nil {
+			return fmt.Errorf("failed to clear inline encryption key: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(keyFile); err == nil {
+		retiredKeyFile := fmt.Sprintf("%s.v%d.retired", keyFile, oldVersion)
+		if err := os.Rename(keyFile, retiredKeyFile); err != nil {
+			return fmt.Errorf("failed to retire old key file: %v", err)
+		}
+		log.Printf("Old key saved as %s; delete it once backups made with it are no longer needed", retiredKeyFile)
+	}
+
+	return os.Rename(stagedKeyFile, keyFile)
+}
@@ -7,7 +7,7 @@ import { Badge } from "@/components/ui/badge"
 import { Tabs, TabsContent, TabsList, TabsTrigger } from "@/components/ui/tabs"
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from "@/components/ui/card"
 import { CalendarIcon, DollarSign, User, FileText, Calculator, CheckCircle, Clock, AlertTriangle } from "lucide-react"
-import { formatCurrency } from "@/lib/utils"
+import { formatCurrency, formatTerm, type RepaymentFrequency } from "@/lib/utils"
 import { services } from "../../wailsjs/go/models"
 
 interface Loan {
@@ -16,9 +16,14 @@ interface Loan {
   contract_number: string
   amount: number
   interest_rate: number
+  interest_method: InterestMethod
   term: number
+  repayment_frequency?: RepaymentFrequency
   monthly_payment: number
-  status: 'pending' | 'approved' | 'disbursed' | 'active' | 'completed' | 'defaulted' | 'cancelled'
+  product_id?: string
+  admin_fee: number
+  provision_fee: number
+  status: 'pending' | 'approved' | 'disbursed' | 'active' | 'completed' | 'defaulted' | 'cancelled' | 'written_off'
   disbursed_at?: string
   due_date: string
   created_at: string
@@ -31,7 +36,98 @@ interface Loan {
     address?: string
     identity_number?: string
   }
+  product?: {
+    id: string
+    code: string
+    name: string
+  }
   installments?: LoanInstallment[]
+  credit_score?: {
+    score: number
+    grade: string
+    reasons: string[]
+  }
+}
+
+export interface LoanProduct {
+  id: string
+  code: string
+  name: string
+  description: string
+  min_amount: number
+  max_amount: number
+  allowed_terms: number[]
+  default_interest_rate: number
+  min_interest_rate: number
+  max_interest_rate: number
+  interest_method: InterestMethod
+  repayment_frequency: RepaymentFrequency
+  admin_fee: number
+  provision_fee_rate: number
+}
+
+type InterestMethod = 'flat' | 'annuity' | 'declining_balance'
+
+const interestMethodLabels: Record<InterestMethod, string> = {
+  flat: 'Flat',
+  annuity: 'Efektif (Anuitas)',
+  declining_balance: 'Menurun',
+}
+
+const periodsPerYear: Record<RepaymentFrequency, number> = {
+  weekly: 52,
+  biweekly: 26,
+  monthly: 12,
+}
+
+const frequencyLabels: Record<RepaymentFrequency, string> = {
+  weekly: 'Mingguan',
+  biweekly: '2 Mingguan',
+  monthly: 'Bulanan',
+}
+
+const installmentLabels: Record<RepaymentFrequency, string> = {
+  weekly: 'Angsuran per minggu:',
+  biweekly: 'Angsuran per 2 minggu:',
+  monthly: 'Angsuran per bulan:',
+}
+
+// Installment per method and frequency; mirrors the schedule generators in utils/amortization.go
+function firstInstallment(method: InterestMethod, frequency: RepaymentFrequency, amount: number, annualRate: number, term: number) {
+  const periodRate = annualRate / 100 / periodsPerYear[frequency]
+  switch (method) {
+    case 'flat':
+    case 'declining_balance':
+      // Equal principal; the first declining balance installment is the largest
+      return amount / term + amount * periodRate
+    default:
+      if (periodRate === 0) return amount / term
+      return (amount * periodRate * Math.pow(1 + periodRate, term)) /
+             (Math.pow(1 + periodRate, term) - 1)
+  }
+}
+
+function totalPayment(method: InterestMethod, frequency: RepaymentFrequency, amount: number, annualRate: number, term: number) {
+  if (method === 'declining_balance') {
+    // Interest on balances amount, amount*(term-1)/term, ... amount/term
+    return amount + amount * (annualRate / 100 / periodsPerYear[frequency]) * (term + 1) / 2
+  }
+  return firstInstallment(method, frequency, amount, annualRate, term) * term
+}
+
+// Effective annual rate (%) giving the same installment as a flat rate; mirrors utils.FlatToEffectiveRate
+function flatToEffectiveRate(flatAnnualRate: number, frequency: RepaymentFrequency, term: number) {
+  if (flatAnnualRate <= 0 || term <= 0) return 0
+  const periods = periodsPerYear[frequency]
+  const target = 1 / term + flatAnnualRate / 100 / periods
+  let low = 0, high = 1
+  for (let i = 0; i < 100; i++) {
+    const mid = (low + high) / 2
+    const growth = Math.pow(1 + mid, term)
+    if (mid * growth / (growth - 1) < target) low = mid
+    else high = mid
+  }
+  return Math.round((low + high) / 2 * periods * 100 * 100) / 100
 }
 
 interface LoanInstallment {
@@ -41,6 +137,8 @@ interface LoanInstallment {
   due_date: string
   amount_due: number
   amount_paid: number
+  penalty_due: number
+  penalty_paid: number
   status: 'pending' | 'paid' | 'overdue' | 'partial'
   paid_at?: string
   dpd: number
@@ -55,58 +153,102 @@ interface LoanModalProps {
   onOpenChange: (open: boolean) => void
   onSubmit?: (data: any) => Promise<void>
   customers?: Array<{ id: string; name: string; phone: string }>
+  products?: LoanProduct[]
 }
 
-export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers = [] }: LoanModalProps) {
+export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers = [], products = [] }: LoanModalProps) {
   const [formData, setFormData] = useState({
     customer_id: "",
+    product_id: "",
     amount: 0,
     interest_rate: 12,
+    interest_method: "annuity" as InterestMethod,
+    repayment_frequency: "monthly" as RepaymentFrequency,
     term: 12,
-    status: "pending"
+    status: "pending",
+    status_reason: "",
+    other_obligations: 0,
+    dsr_override_reason: ""
   })
   
   const [loading, setLoading] = useState(false)
+  const [submitError, setSubmitError] = useState("")
   const [calculatedPayment, setCalculatedPayment] = useState(0)
 
   useEffect(() => {
     if (loan && (mode === 'edit' || mode === 'view')) {
       setFormData({
         customer_id: loan.customer_id,
+        product_id: loan.product_id || "",
         amount: loan.amount,
         interest_rate: loan.interest_rate,
+        interest_method: loan.interest_method || "annuity",
+        repayment_frequency: loan.repayment_frequency || "monthly",
         term: loan.term,
-        status: loan.status
+        status: loan.status,
+        status_reason: "",
+        other_obligations: 0,
+        dsr_override_reason: ""
       })
     } else if (mode === 'create') {
       setFormData({
         customer_id: "",
+        product_id: "",
         amount: 0,
         interest_rate: 12,
+        interest_method: "annuity",
+        repayment_frequency: "monthly",
         term: 12,
-        status: "pending"
+        status: "pending",
+        status_reason: "",
+        other_obligations: 0,
+        dsr_override_reason: ""
       })
     }
+    setSubmitError("")
   }, [loan, mode, open])
 
   useEffect(() => {
     if (formData.amount > 0 && formData.interest_rate > 0 && formData.term > 0) {
-      const monthlyRate = formData.interest_rate / 100 / 12
-      const payment = (formData.amount * monthlyRate * Math.pow(1 + monthlyRate, formData.term)) / 
-                     (Math.pow(1 + monthlyRate, formData.term) - 1)
-      setCalculatedPayment(payment)
+      setCalculatedPayment(firstInstallment(formData.interest_method, formData.repayment_frequency, formData.amount, formData.interest_rate, formData.term))
     }
-  }, [formData.amount, formData.interest_rate, formData.term])
+  }, [formData.amount, formData.interest_rate, formData.interest_method, formData.repayment_frequency, formData.term])
+
+  const selectedProduct = products.find((product) => product.id === formData.product_id)
+
+  // Rate, method, frequency and terms follow the chosen product
+  const handleProductChange = (productId: string) => {
+    const product = products.find((p) => p.id === productId)
+    if (!product) {
+      setFormData({ ...formData, product_id: productId })
+      return
+    }
+    setFormData({
+      ...formData,
+      product_id: productId,
+      interest_rate: product.default_interest_rate,
+      interest_method: product.interest_method,
+      repayment_frequency: product.repayment_frequency || "monthly",
+      term: product.allowed_terms.includes(formData.term) ? formData.term : product.allowed_terms[0],
+    })
+  }
+
+  const adminFee = selectedProduct ? selectedProduct.admin_fee : (loan?.admin_fee || 0)
+  const provisionFee = selectedProduct
+    ? Math.round(formData.amount * selectedProduct.provision_fee_rate / 100)
+    : (loan?.provision_fee || 0)
 
   const handleSubmit = async () => {
     if (!onSubmit) return
     
     setLoading(true)
+    setSubmitError("")
     try {
       await onSubmit(formData)
       onOpenChange(false)
     } catch (error) {
       console.error("Error submitting loan:", error)
+      setSubmitError(error instanceof Error ? error.message : String(error))
     } finally {
       setLoading(false)
     }
@@ -126,6 +268,8 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
         return <Badge variant="destructive">Bermasalah</Badge>
       case 'cancelled':
         return <Badge variant="outline">Dibatalkan</Badge>
+      case 'written_off':
+        return <Badge variant="outline" className="bg-gray-100 text-gray-800">Hapus Buku</Badge>
       case 'pending':
       default:
         return <Badge variant="secondary">Pending</Badge>
@@ -230,6 +374,12 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
                       <span className="text-sm text-muted-foreground">Kontrak:</span>
                       <span className="text-sm font-medium">{loan.contract_number}</span>
                     </div>
+                    {loan.product && (
+                      <div className="flex justify-between">
+                        <span className="text-sm text-muted-foreground">Produk:</span>
+                        <span className="text-sm font-medium">{loan.product.name}</span>
+                      </div>
+                    )}
                     <div className="flex justify-between">
                       <span className="text-sm text-muted-foreground">Jumlah:</span>
                       <span className="text-sm font-medium">{formatCurrency(loan.amount)}</span>
@@ -238,9 +388,17 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
                       <span className="text-sm text-muted-foreground">Bunga:</span>
                       <span className="text-sm font-medium">{loan.interest_rate}% / tahun</span>
                     </div>
+                    <div className="flex justify-between">
+                      <span className="text-sm text-muted-foreground">Metode Bunga:</span>
+                      <span className="text-sm font-medium">{interestMethodLabels[loan.interest_method || 'annuity']}</span>
+                    </div>
                     <div className="flex justify-between">
                       <span className="text-sm text-muted-foreground">Tenor:</span>
-                      <span className="text-sm font-medium">{loan.term} bulan</span>
+                      <span className="text-sm font-medium">{formatTerm(loan.term, loan.repayment_frequency)}</span>
+                    </div>
+                    <div className="flex justify-between">
+                      <span className="text-sm text-muted-foreground">Frekuensi Angsuran:</span>
+                      <span className="text-sm font-medium">{frequencyLabels[loan.repayment_frequency || 'monthly']}</span>
                     </div>
                     <div className="flex justify-between">
                       <span className="text-sm text-muted-foreground">Angsuran:</span>
@@ -250,6 +408,14 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
                       <span className="text-sm text-muted-foreground">Status:</span>
                       {getStatusBadge(loan.status)}
                     </div>
+                    {loan.credit_score && (
+                      <div className="flex justify-between">
+                        <span className="text-sm text-muted-foreground">Skor Kredit:</span>
+                        <span className="text-sm font-medium" title={loan.credit_score.reasons.join(', ')}>
+                          {loan.credit_score.score} ({loan.credit_score.grade})
+                        </span>
+                      </div>
+                    )}
                   </CardContent>
                 </Card>
               </div>
@@ -334,6 +500,11 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
                             <div className="text-sm text-muted-foreground">
                               Terbayar: {formatCurrency(installment.amount_paid)}
                             </div>
+                            {installment.penalty_due > 0 && (
+                              <div className="text-sm text-red-600">
+                                Denda: {formatCurrency(installment.penalty_due - installment.penalty_paid)}
+                              </div>
+                            )}
                             {getInstallmentStatusBadge(installment.status)}
                           </div>
                         </div>
@@ -393,6 +564,31 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
                 )}
               </div>
               
+              <div>
+                <Label htmlFor="product_id">Produk Pinjaman *</Label>
+                <select
+                  id="product_id"
+                  value={formData.product_id}
+                  onChange={(e) => handleProductChange(e.target.value)}
+                  className="w-full h-10 px-3 rounded-md border border-input bg-background"
+                  disabled={mode !== 'create'}
+                  required
+                >
+                  <option value="">Pilih Produk</option>
+                  {products.map((product) => (
+                    <option key={product.id} value={product.id}>
+                      {product.name}
+                    </option>
+                  ))}
+                </select>
+                {selectedProduct && (
+                  <p className="text-xs text-muted-foreground mt-1">
+                    {formatCurrency(selectedProduct.min_amount)} - {formatCurrency(selectedProduct.max_amount)},
+                    bunga {selectedProduct.min_interest_rate}% - {selectedProduct.max_interest_rate}% / tahun
+                  </p>
+                )}
+              </div>
+
               <div>
                 <Label htmlFor="amount">Jumlah Pinjaman *</Label>
                 <Input
@@ -421,18 +617,72 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
               </div>
 
               <div>
-                <Label htmlFor="term">Jangka Waktu (bulan) *</Label>
-                <Input
-                  id="term"
-                  type="number"
-                  value={formData.term}
-                  onChange={(e) => setFormData({ ...formData, term: parseInt(e.target.value) || 12 })}
-                  placeholder="12"
-                  disabled={mode === 'view'}
-                  required
-                />
+                <Label htmlFor="interest_method">Metode Bunga *</Label>
+                <select
+                  id="interest_method"
+                  value={formData.interest_method}
+                  onChange={(e) => setFormData({ ...formData, interest_method: e.target.value as InterestMethod })}
+                  className="w-full h-10 px-3 rounded-md border border-input bg-background"
+                  disabled={mode === 'view' || !!formData.product_id}
+                >
+                  <option value="flat">{interestMethodLabels.flat}</option>
+                  <option value="annuity">{interestMethodLabels.annuity}</option>
+                  <option value="declining_balance">{interestMethodLabels.declining_balance}</option>
+                </select>
               </div>
 
+              <div>
+                <Label htmlFor="term">Jangka Waktu ({frequencyLabels[formData.repayment_frequency].toLowerCase()}) *</Label>
+                {selectedProduct ? (
+                  <select
+                    id="term"
+                    value={formData.term}
+                    onChange={(e) => setFormData({ ...formData, term: parseInt(e.target.value) })}
+                    className="w-full h-10 px-3 rounded-md border border-input bg-background"
+                    disabled={mode === 'view'}
+                  >
+                    {selectedProduct.allowed_terms.map((term) => (
+                      <option key={term} value={term}>{formatTerm(term, formData.repayment_frequency)}</option>
+                    ))}
+                  </select>
+                ) : (
+                  <Input
+                    id="term"
+                    type="number"
+                    value={formData.term}
+                    onChange={(e) => setFormData({ ...formData, term: parseInt(e.target.value) || 12 })}
+                    placeholder="12"
+                    disabled={mode === 'view'}
+                    required
+                  />
+                )}
+              </div>
+
+              {mode === 'create' && (
+                <>
+                  <div>
+                    <Label htmlFor="other_obligations">Kewajiban Lain per Bulan</Label>
+                    <Input
+                      id="other_obligations"
+                      type="number"
+                      value={formData.other_obligations}
+                      onChange={(e) => setFormData({ ...formData, other_obligations: parseInt(e.target.value) || 0 })}
+                      placeholder="Cicilan di luar koperasi"
+                    />
+                  </div>
+
+                  <div>
+                    <Label htmlFor="dsr_override_reason">Alasan Melebihi Batas DSR</Label>
+                    <Input
+                      id="dsr_override_reason"
+                      value={formData.dsr_override_reason}
+                      onChange={(e) => setFormData({ ...formData, dsr_override_reason: e.target.value })}
+                      placeholder="Wajib jika rasio angsuran terhadap penghasilan melebihi batas"
+                    />
+                  </div>
+                </>
+              )}
+
               {mode === 'edit' && (
                 <div>
                   <Label htmlFor="status">Status</Label>
@@ -443,15 +693,28 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
                     className="w-full h-10 px-3 rounded-md border border-input bg-background"
                   >
                     <option value="pending">Pending</option>
-                    <option value="approved">Disetujui</option>
-                    <option value="disbursed">Dicairkan</option>
+                    <option value="approved" disabled={loan?.status !== 'approved'}>Disetujui</option>
+                    <option value="disbursed" disabled={loan?.status !== 'disbursed'}>Dicairkan</option>
                     <option value="active">Aktif</option>
-                    <option value="completed">Selesai</option>
+                    <option value="completed" disabled={loan?.status !== 'completed'}>Selesai</option>
                     <option value="defaulted">Bermasalah</option>
                     <option value="cancelled">Dibatalkan</option>
+                    <option value="written_off" disabled={loan?.status !== 'written_off'}>Hapus Buku</option>
                   </select>
                 </div>
               )}
+
+              {mode === 'edit' && loan && formData.status !== loan.status && (
+                <div>
+                  <Label htmlFor="status_reason">Alasan Perubahan Status</Label>
+                  <Input
+                    id="status_reason"
+                    value={formData.status_reason}
+                    onChange={(e) => setFormData({ ...formData, status_reason: e.target.value })}
+                    placeholder="Wajib untuk pembatalan atau kredit bermasalah"
+                  />
+                </div>
+              )}
             </div>
 
             {(mode === 'create' || mode === 'edit') && calculatedPayment > 0 && (
@@ -469,21 +732,33 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
                       <span className="font-medium">{formatCurrency(formData.amount)}</span>
                     </div>
                     <div className="flex justify-between">
-                      <span>Bunga per tahun:</span>
+                      <span>Bunga per tahun ({interestMethodLabels[formData.interest_method]}):</span>
                       <span className="font-medium">{formData.interest_rate}%</span>
                     </div>
+                    {formData.interest_method === 'flat' && (
+                      <div className="flex justify-between text-sm text-muted-foreground">
+                        <span>Setara bunga efektif:</span>
+                        <span>{flatToEffectiveRate(formData.interest_rate, formData.repayment_frequency, formData.term)}% / tahun</span>
+                      </div>
+                    )}
                     <div className="flex justify-between">
                       <span>Tenor:</span>
-                      <span className="font-medium">{formData.term} bulan</span>
+                      <span className="font-medium">{formatTerm(formData.term, formData.repayment_frequency)}</span>
                     </div>
                     <hr />
                     <div className="flex justify-between font-semibold">
-                      <span>Angsuran per bulan:</span>
+                      <span>{formData.interest_method === 'declining_balance' ? 'Angsuran pertama:' : installmentLabels[formData.repayment_frequency]}</span>
                       <span className="text-lg">{formatCurrency(calculatedPayment)}</span>
                     </div>
+                    {(adminFee > 0 || provisionFee > 0) && (
+                      <div className="flex justify-between text-sm text-muted-foreground">
+                        <span>Biaya admin + provisi:</span>
+                        <span>{formatCurrency(adminFee + provisionFee)}</span>
+                      </div>
+                    )}
                     <div className="flex justify-between text-sm text-muted-foreground">
                       <span>Total pembayaran:</span>
-                      <span>{formatCurrency(calculatedPayment * formData.term)}</span>
+                      <span>{formatCurrency(totalPayment(formData.interest_method, formData.repayment_frequency, formData.amount, formData.interest_rate, formData.term))}</span>
                     </div>
                   </div>
                 </CardContent>
@@ -492,6 +767,10 @@ export function LoanModal({ mode, loan, open, onOpenChange, onSubmit, customers
           </div>
         )}
 
+        {submitError && (
+          <p className="text-sm text-red-600">{submitError}</p>
+        )}
+
         <DialogFooter>
           <Button variant="outline" onClick={() => onOpenChange(false)}>
             {mode === 'view' ? 'Tutup' : 'Batal'}
@@ -21,6 +21,20 @@ export function formatDate(date: string | Date): string {
   }).format(new Date(date))
 }
 
+export type RepaymentFrequency = 'weekly' | 'biweekly' | 'monthly'
+
+// Term of a loan as shown to users; term counts installments at the loan's frequency
+export function formatTerm(term: number, frequency: RepaymentFrequency = 'monthly'): string {
+  switch (frequency) {
+    case 'weekly':
+      return `${term} minggu`
+    case 'biweekly':
+      return `${term} x 2 minggu`
+    default:
+      return `${term} bulan`
+  }
+}
+
 export function formatDateTime(date: string | Date): string {
   return new Intl.DateTimeFormat('id-ID', {
     year: 'numeric',
@@ -28,7 +28,7 @@ import {
   Target,
   Activity
 } from "lucide-react"
-import { formatCurrency } from "@/lib/utils"
+import { formatCurrency, formatTerm, type RepaymentFrequency } from "@/lib/utils"
 import { CreateLoan, ListLoans, GetLoan, UpdateLoan, DisburseLoan, PayInstallment, GetOverdueInstallments } from "../../wailsjs/go/main/App"
 import { services } from "../../wailsjs/go/models"
 
@@ -39,8 +39,9 @@ interface Loan {
   amount: number
   interest_rate: number
   term: number
+  repayment_frequency?: RepaymentFrequency
   monthly_payment: number
-  status: 'pending' | 'approved' | 'disbursed' | 'active' | 'completed' | 'defaulted' | 'cancelled'
+  status: 'pending' | 'approved' | 'disbursed' | 'active' | 'completed' | 'defaulted' | 'cancelled' | 'written_off'
   disbursed_at?: string
   due_date: string
   created_at: string
@@ -204,6 +205,8 @@ export function LoanManagement() {
         return <Badge variant="destructive">Bermasalah</Badge>
       case 'cancelled':
         return <Badge variant="outline">Dibatalkan</Badge>
+      case 'written_off':
+        return <Badge variant="outline" className="bg-gray-100 text-gray-800">Hapus Buku</Badge>
       case 'pending':
       default:
         return <Badge variant="secondary">Pending</Badge>
@@ -361,6 +364,7 @@ export function LoanManagement() {
                   <option value="completed">Selesai</option>
                   <option value="defaulted">Bermasalah</option>
                   <option value="cancelled">Dibatalkan</option>
+                  <option value="written_off">Hapus Buku</option>
                 </select>
                 <Button 
                   variant="outline" 
@@ -628,7 +632,7 @@ function LoanList({
                       </div>
                       <p className="text-sm text-muted-foreground">
                         {loan.customer?.name} • {formatCurrency(loan.amount)} • 
-                        {loan.term} bulan • {loan.interest_rate}%
+                        {formatTerm(loan.term, loan.repayment_frequency)} • {loan.interest_rate}%
                       </p>
                       <div className="flex items-center space-x-4 mt-2">
                         <div className="flex items-center space-x-1">
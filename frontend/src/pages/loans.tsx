@@ -13,7 +13,8 @@ import {
   DialogHeader,
   DialogTitle,
 } from "@/components/ui/dialog"
-import { LoanModal } from "@/components/LoanModal"
+import { LoanModal, LoanProduct } from "@/components/LoanModal"
+import { useAuth } from "@/contexts/AuthContext"
 import {
   Plus,
   Search,
@@ -32,8 +33,8 @@ import {
   FileText,
   Banknote,
 } from "lucide-react"
-import { formatCurrency } from "@/lib/utils"
-import { CreateLoan, ListLoans, GetLoan, UpdateLoan, DisburseLoan, PayInstallment, GetOverdueInstallments } from "../../wailsjs/go/main/App"
+import { formatCurrency, formatTerm, type RepaymentFrequency } from "@/lib/utils"
+import { CreateLoan, ListLoans, ListLoanProducts, GetLoan, UpdateLoan, DisburseLoan, PayInstallment, GetOverdueInstallments, SubmitLoanForApproval, ApproveLoan, RejectLoan } from "../../wailsjs/go/main/App"
 import { services } from "../../wailsjs/go/models"
 import { usePermissions } from "@/hooks/usePermissions"
 
@@ -44,10 +45,13 @@ interface Loan {
   amount: number
   interest_rate: number
   term: number
+  repayment_frequency?: RepaymentFrequency
   monthly_payment: number
-  status: 'pending' | 'approved' | 'disbursed' | 'active' | 'completed' | 'defaulted' | 'cancelled'
+  status: 'pending' | 'approved' | 'disbursed' | 'active' | 'completed' | 'defaulted' | 'cancelled' | 'written_off'
   disbursed_at?: string
   due_date: string
+  created_by?: string
+  submitted_at?: string
   created_at: string
   updated_at: string
   customer?: {
@@ -65,6 +69,8 @@ interface LoanInstallment {
   due_date: string
   amount_due: number
   amount_paid: number
+  penalty_due: number
+  penalty_paid: number
   status: 'pending' | 'paid' | 'overdue' | 'partial'
   paid_at?: string
   dpd: number
@@ -80,6 +86,8 @@ interface LoanListResponse {
 }
 
 export function Loans() {
+  const { user } = useAuth()
+  const { canApproveLoan } = usePermissions()
   const [loans, setLoans] = useState<Loan[]>([])
   const [overdueInstallments, setOverdueInstallments] = useState<LoanInstallment[]>([])
   const [total, setTotal] = useState(0)
@@ -96,12 +104,19 @@ export function Loans() {
   const [selectedLoan, setSelectedLoan] = useState<Loan | null>(null)
   const [selectedInstallment, setSelectedInstallment] = useState<LoanInstallment | null>(null)
   const [loanToDelete, setLoanToDelete] = useState<string | null>(null)
+  const [loanToReject, setLoanToReject] = useState<string | null>(null)
+  const [rejectReason, setRejectReason] = useState("")
   const [customers, setCustomers] = useState<Array<{ id: string; name: string; phone: string }>>([])
+  const [products, setProducts] = useState<LoanProduct[]>([])
   
 
   const [paymentData, setPaymentData] = useState({
     amount: 0,
     payment_date: new Date().toISOString().split('T')[0],
+    method: 'cash',
+    reference: '',
+    // one key per opened form, so a double submit records a single payment
+    idempotency_key: '',
   })
 
   const loadLoans = async () => {
@@ -127,6 +142,17 @@ export function Loans() {
     }
   }
 
+  const loadProducts = async () => {
+    try {
+      const response = await ListLoanProducts(true)
+      if (response.success) {
+        setProducts(response.data || [])
+      }
+    } catch (error) {
+      console.error("Error loading loan products:", error)
+    }
+  }
+
   const loadOverdueInstallments = async () => {
     try {
       const response = await GetOverdueInstallments()
@@ -143,14 +169,19 @@ export function Loans() {
     loadOverdueInstallments()
   }, [page, customerFilter, statusFilter])
 
+  useEffect(() => {
+    loadProducts()
+  }, [])
+
   const handleCreateLoan = async (data: any) => {
     try {
-      const request = new services.LoanCreateRequest(data)
+      const request = new services.LoanCreateRequest({ ...data, created_by: user?.id ?? "" })
       
       const response = await CreateLoan(request)
-      if (response.success) {
-        loadLoans()
+      if (!response.success) {
+        throw new Error(response.message)
       }
+      loadLoans()
     } catch (error) {
       console.error("Error creating loan:", error)
       throw error
@@ -167,7 +198,7 @@ export function Loans() {
     if (!selectedLoan) return
     
     try {
-      const request = new services.LoanUpdateRequest(data)
+      const request = new services.LoanUpdateRequest({ ...data, updated_by: user?.id ?? "" })
       
       const response = await UpdateLoan(selectedLoan.id, request)
       if (response.success) {
@@ -195,7 +226,7 @@ export function Loans() {
 
   const handleDisburseLoan = async (loanId: string) => {
     try {
-      const response = await DisburseLoan(loanId)
+      const response = await DisburseLoan(loanId, user?.id ?? "")
       if (response.success) {
         loadLoans()
       }
@@ -204,6 +235,45 @@ export function Loans() {
     }
   }
 
+  const handleSubmitLoan = async (loanId: string) => {
+    try {
+      const response = await SubmitLoanForApproval(loanId, user?.id ?? "")
+      if (response.success) {
+        loadLoans()
+      }
+    } catch (error) {
+      console.error("Error submitting loan:", error)
+    }
+  }
+
+  const handleApproveLoan = async (loanId: string) => {
+    try {
+      const request = new services.LoanApprovalRequest({ loan_id: loanId, approver_id: user?.id ?? "", notes: "" })
+      const response = await ApproveLoan(request)
+      if (response.success) {
+        loadLoans()
+      }
+    } catch (error) {
+      console.error("Error approving loan:", error)
+    }
+  }
+
+  const handleRejectLoan = async () => {
+    if (!loanToReject) return
+
+    try {
+      const request = new services.LoanApprovalRequest({ loan_id: loanToReject, approver_id: user?.id ?? "", notes: rejectReason })
+      const response = await RejectLoan(request)
+      if (response.success) {
+        setLoanToReject(null)
+        setRejectReason("")
+        loadLoans()
+      }
+    } catch (error) {
+      console.error("Error rejecting loan:", error)
+    }
+  }
+
   const handlePayInstallment = async () => {
     if (!selectedInstallment) return
     
@@ -212,6 +282,9 @@ export function Loans() {
         installment_id: selectedInstallment.id,
         amount: paymentData.amount,
         payment_date: new Date(paymentData.payment_date),
+        method: paymentData.method,
+        reference: paymentData.reference,
+        idempotency_key: paymentData.idempotency_key,
       })
       
       const response = await PayInstallment(request)
@@ -221,6 +294,9 @@ export function Loans() {
         setPaymentData({
           amount: 0,
           payment_date: new Date().toISOString().split('T')[0],
+          method: 'cash',
+          reference: '',
+          idempotency_key: '',
         })
         loadLoans()
         loadOverdueInstallments()
@@ -257,6 +333,8 @@ export function Loans() {
         return <Badge variant="destructive">Bermasalah</Badge>
       case 'cancelled':
         return <Badge variant="outline">Dibatalkan</Badge>
+      case 'written_off':
+        return <Badge variant="outline" className="bg-gray-100 text-gray-800">Hapus Buku</Badge>
       case 'pending':
       default:
         return <Badge variant="secondary">Pending</Badge>
@@ -421,6 +499,7 @@ export function Loans() {
                     <option value="completed">Selesai</option>
                     <option value="defaulted">Bermasalah</option>
                     <option value="cancelled">Dibatalkan</option>
+                    <option value="written_off">Hapus Buku</option>
                   </select>
                 </div>
                 <div className="flex items-end">
@@ -481,7 +560,7 @@ export function Loans() {
                               </div>
                               <p className="text-sm text-muted-foreground">
                                 {loan.customer?.name} • {formatCurrency(loan.amount)} • 
-                                {loan.term} bulan • {loan.interest_rate}%
+                                {formatTerm(loan.term, loan.repayment_frequency)} • {loan.interest_rate}%
                               </p>
                               <div className="flex items-center space-x-4 mt-2">
                                 <div className="flex items-center space-x-1">
@@ -533,6 +612,33 @@ export function Loans() {
                           >
                             <Edit className="h-4 w-4" />
                           </Button>
+                          {loan.status === 'pending' && !loan.submitted_at && (
+                            <Button
+                              variant="outline"
+                              size="sm"
+                              onClick={() => handleSubmitLoan(loan.id)}
+                            >
+                              Ajukan
+                            </Button>
+                          )}
+                          {loan.status === 'pending' && loan.submitted_at && canApproveLoan() && loan.created_by !== user?.id && (
+                            <>
+                              <Button
+                                variant="outline"
+                                size="sm"
+                                onClick={() => handleApproveLoan(loan.id)}
+                              >
+                                Setujui
+                              </Button>
+                              <Button
+                                variant="outline"
+                                size="sm"
+                                onClick={() => setLoanToReject(loan.id)}
+                              >
+                                Tolak
+                              </Button>
+                            </>
+                          )}
                           {loan.status === 'approved' && (
                             <Button
                               variant="outline"
@@ -622,6 +728,9 @@ export function Loans() {
                           </p>
                           <p className="text-sm font-medium">
                             Jumlah: {formatCurrency(installment.amount_due)}
+                            {installment.penalty_due > 0 && (
+                              <span className="text-red-600"> + denda {formatCurrency(installment.penalty_due - installment.penalty_paid)}</span>
+                            )}
                           </p>
                         </div>
                         <Button
@@ -629,8 +738,11 @@ export function Loans() {
                           onClick={() => {
                             setSelectedInstallment(installment)
                             setPaymentData({
-                              amount: installment.amount_due - installment.amount_paid,
+                              amount: installment.amount_due - installment.amount_paid + installment.penalty_due - installment.penalty_paid,
                               payment_date: new Date().toISOString().split('T')[0],
+                              method: 'cash',
+                              reference: '',
+                              idempotency_key: crypto.randomUUID(),
                             })
                             setShowPaymentModal(true)
                           }}
@@ -701,6 +813,7 @@ export function Loans() {
         onOpenChange={setShowLoanModal}
         onSubmit={modalMode === 'create' ? handleCreateLoan : handleUpdateLoan}
         customers={customers}
+        products={products}
       />
 
       {/* Payment Modal */}
@@ -731,6 +844,29 @@ export function Loans() {
                 onChange={(e) => setPaymentData({ ...paymentData, payment_date: e.target.value })}
               />
             </div>
+            <div>
+              <Label htmlFor="payment_method">Metode Pembayaran</Label>
+              <select
+                id="payment_method"
+                value={paymentData.method}
+                onChange={(e) => setPaymentData({ ...paymentData, method: e.target.value })}
+                className="w-full h-10 px-3 rounded-md border border-input bg-background"
+              >
+                <option value="cash">Tunai</option>
+                <option value="transfer">Transfer</option>
+                <option value="qris">QRIS</option>
+              </select>
+            </div>
+            {paymentData.method !== 'cash' && (
+              <div>
+                <Label htmlFor="payment_reference">No. Referensi</Label>
+                <Input
+                  id="payment_reference"
+                  value={paymentData.reference}
+                  onChange={(e) => setPaymentData({ ...paymentData, reference: e.target.value })}
+                />
+              </div>
+            )}
           </div>
           <DialogFooter>
             <Button variant="outline" onClick={() => setShowPaymentModal(false)}>
@@ -744,6 +880,34 @@ export function Loans() {
         </DialogContent>
       </Dialog>
 
+      {/* Reject Loan Modal */}
+      <Dialog open={loanToReject !== null} onOpenChange={(open) => !open && setLoanToReject(null)}>
+        <DialogContent>
+          <DialogHeader>
+            <DialogTitle>Tolak Pinjaman</DialogTitle>
+            <DialogDescription>
+              Pinjaman yang ditolak akan dibatalkan.
+            </DialogDescription>
+          </DialogHeader>
+          <div>
+            <Label htmlFor="reject_reason">Alasan Penolakan</Label>
+            <Input
+              id="reject_reason"
+              value={rejectReason}
+              onChange={(e) => setRejectReason(e.target.value)}
+            />
+          </div>
+          <DialogFooter>
+            <Button variant="outline" onClick={() => setLoanToReject(null)}>
+              Batal
+            </Button>
+            <Button variant="destructive" onClick={handleRejectLoan} disabled={!rejectReason.trim()}>
+              Tolak
+            </Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+
       {/* Delete Confirmation Modal */}
       <Dialog open={showDeleteConfirm} onOpenChange={setShowDeleteConfirm}>
         <DialogContent>
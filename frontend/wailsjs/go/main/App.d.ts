@@ -2,6 +2,8 @@
 // This file is automatically generated. DO NOT EDIT
 import {services} from '../models';
 
+export function ApproveLoan(arg1:services.LoanApprovalRequest):Promise<services.APIResponse>;
+
 export function ChangePassword(arg1:services.ChangePasswordRequest):Promise<services.APIResponse>;
 
 export function CreateCustomer(arg1:services.CustomerCreateRequest):Promise<services.APIResponse>;
@@ -20,7 +22,7 @@ export function DeleteReferralCode(arg1:string):Promise<services.APIResponse>;
 
 export function DeleteUser(arg1:string):Promise<services.APIResponse>;
 
-export function DisburseLoan(arg1:string):Promise<services.APIResponse>;
+export function DisburseLoan(arg1:string,arg2:string):Promise<services.APIResponse>;
 
 export function GetAuditLog(arg1:string):Promise<services.APIResponse>;
 
@@ -62,6 +64,8 @@ export function ListCustomers(arg1:services.CustomerListRequest):Promise<service
 
 export function ListDocuments(arg1:services.DocumentListRequest):Promise<services.APIResponse>;
 
+export function ListLoanProducts(arg1:boolean):Promise<services.APIResponse>;
+
 export function ListLoans(arg1:services.LoanListRequest):Promise<services.APIResponse>;
 
 export function ListReferralCodes(arg1:services.ReferralCodeListRequest):Promise<services.APIResponse>;
@@ -76,10 +80,14 @@ export function Logout(arg1:string):Promise<services.APIResponse>;
 
 export function PayInstallment(arg1:services.InstallmentPaymentRequest):Promise<services.APIResponse>;
 
+export function RejectLoan(arg1:services.LoanApprovalRequest):Promise<services.APIResponse>;
+
 export function SeedDatabase():Promise<services.APIResponse>;
 
 export function SendNotification(arg1:services.SendNotificationRequest):Promise<services.APIResponse>;
 
+export function SubmitLoanForApproval(arg1:string,arg2:string):Promise<services.APIResponse>;
+
 export function TestNotification(arg1:services.NotificationTestRequest):Promise<services.APIResponse>;
 
 export function TriggerPendingNotifications():Promise<services.APIResponse>;
@@ -2,6 +2,10 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function ApproveLoan(arg1) {
+  return window['go']['main']['App']['ApproveLoan'](arg1);
+}
+
 export function ChangePassword(arg1) {
   return window['go']['main']['App']['ChangePassword'](arg1);
 }
@@ -38,8 +42,8 @@ export function DeleteUser(arg1) {
   return window['go']['main']['App']['DeleteUser'](arg1);
 }
 
-export function DisburseLoan(arg1) {
-  return window['go']['main']['App']['DisburseLoan'](arg1);
+export function DisburseLoan(arg1, arg2) {
+  return window['go']['main']['App']['DisburseLoan'](arg1, arg2);
 }
 
 export function GetAuditLog(arg1) {
@@ -122,6 +126,10 @@ export function ListDocuments(arg1) {
   return window['go']['main']['App']['ListDocuments'](arg1);
 }
 
+export function ListLoanProducts(arg1) {
+  return window['go']['main']['App']['ListLoanProducts'](arg1);
+}
+
 export function ListLoans(arg1) {
   return window['go']['main']['App']['ListLoans'](arg1);
 }
@@ -150,6 +158,10 @@ export function PayInstallment(arg1) {
   return window['go']['main']['App']['PayInstallment'](arg1);
 }
 
+export function RejectLoan(arg1) {
+  return window['go']['main']['App']['RejectLoan'](arg1);
+}
+
 export function SeedDatabase() {
   return window['go']['main']['App']['SeedDatabase']();
 }
@@ -158,6 +170,10 @@ export function SendNotification(arg1) {
   return window['go']['main']['App']['SendNotification'](arg1);
 }
 
+export function SubmitLoanForApproval(arg1, arg2) {
+  return window['go']['main']['App']['SubmitLoanForApproval'](arg1, arg2);
+}
+
 export function TestNotification(arg1) {
   return window['go']['main']['App']['TestNotification'](arg1);
 }
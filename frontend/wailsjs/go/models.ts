@@ -259,6 +259,10 @@ export namespace services {
 	    amount: number;
 	    // Go type: time
 	    payment_date: any;
+	    method: string;
+	    reference: string;
+	    idempotency_key: string;
+	    posted_by: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new InstallmentPaymentRequest(source);
@@ -269,6 +273,10 @@ export namespace services {
 	        this.installment_id = source["installment_id"];
 	        this.amount = source["amount"];
 	        this.payment_date = this.convertValues(source["payment_date"], null);
+	        this.method = source["method"];
+	        this.reference = source["reference"];
+	        this.idempotency_key = source["idempotency_key"];
+	        this.posted_by = source["posted_by"];
 	    }
 	
 		convertValues(a: any, classType: any, asMap: boolean = false): any {
@@ -292,11 +300,31 @@ export namespace services {
 		    return a;
 		}
 	}
+	export class LoanApprovalRequest {
+	    loan_id: string;
+	    approver_id: string;
+	    notes: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LoanApprovalRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.loan_id = source["loan_id"];
+	        this.approver_id = source["approver_id"];
+	        this.notes = source["notes"];
+	    }
+	}
 	export class LoanCreateRequest {
 	    customer_id: string;
+	    product_id: string;
 	    amount: number;
 	    interest_rate: number;
 	    term: number;
+	    created_by: string;
+	    other_obligations: number;
+	    dsr_override_reason: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new LoanCreateRequest(source);
@@ -305,9 +333,13 @@ export namespace services {
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.customer_id = source["customer_id"];
+	        this.product_id = source["product_id"];
 	        this.amount = source["amount"];
 	        this.interest_rate = source["interest_rate"];
 	        this.term = source["term"];
+	        this.created_by = source["created_by"];
+	        this.other_obligations = source["other_obligations"];
+	        this.dsr_override_reason = source["dsr_override_reason"];
 	    }
 	}
 	export class LoanListRequest {
@@ -331,8 +363,11 @@ export namespace services {
 	export class LoanUpdateRequest {
 	    amount: number;
 	    interest_rate: number;
+	    interest_method: string;
 	    term: number;
 	    status: string;
+	    status_reason: string;
+	    updated_by: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new LoanUpdateRequest(source);
@@ -342,8 +377,11 @@ export namespace services {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.amount = source["amount"];
 	        this.interest_rate = source["interest_rate"];
+	        this.interest_method = source["interest_method"];
 	        this.term = source["term"];
 	        this.status = source["status"];
+	        this.status_reason = source["status_reason"];
+	        this.updated_by = source["updated_by"];
 	    }
 	}
 	export class LoginRequest {
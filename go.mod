@@ -4,6 +4,7 @@ go 1.23.5
 
 require (
 	filippo.io/age v1.2.1
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/golang-migrate/migrate/v4 v4.19.0
 	github.com/google/uuid v1.6.0
 	github.com/mattn/go-sqlite3 v1.14.32
@@ -0,0 +1,105 @@
+// Package accounting keeps the koperasi's books as a double-entry general
+// ledger. It knows the system accounts of the chart of accounts, checks that
+// journal entries balance and turns loan and savings events into entries
+// through the posting rules in posting.go.
+package accounting
+
+import (
+	"fmt"
+	"strings"
+
+	"koperasi-app/internal/models"
+)
+
+// System accounts booked to by the posting rules
+const (
+	AccountKas                 = "1101"
+	AccountBank                = "1102"
+	AccountPiutangPinjaman     = "1201"
+	AccountSimpananSukarela    = "2101"
+	AccountTitipanAngsuran     = "2102" // payments held on a loan ahead of its installments
+	AccountUtangPajak          = "2103" // tax withheld from savings interest
+	AccountBungaDitangguhkan   = "2104" // interest and penalties capitalized by restructuring
+	AccountSimpananPokok       = "3101"
+	AccountSimpananWajib       = "3102"
+	AccountPendapatanBunga     = "4101"
+	AccountPendapatanDenda     = "4102"
+	AccountPendapatanAdmin     = "4103"
+	AccountPendapatanProvisi   = "4104"
+	AccountPendapatanPemulihan = "4105"
+	AccountBebanBungaSimpanan  = "5101"
+	AccountBebanPenghapusan    = "5102"
+)
+
+// AccountSHUBelumDibagi is where the surplus of a closed year is kept until
+// the members' meeting distributes it
+const AccountSHUBelumDibagi = "3301"
+
+// accountTypeDigits maps the first digit of an account code to its type
+var accountTypeDigits = map[byte]models.GLAccountType{
+	'1': models.GLAccountAsset,
+	'2': models.GLAccountLiability,
+	'3': models.GLAccountEquity,
+	'4': models.GLAccountIncome,
+	'5': models.GLAccountExpense,
+}
+
+// AccountTypeOf returns the type of an account from the first digit of its
+// code. Codes are four digits.
+func AccountTypeOf(code string) (models.GLAccountType, error) {
+	if len(code) != 4 || strings.Trim(code, "0123456789") != "" {
+		return "", fmt.Errorf("account code must be 4 digits")
+	}
+	accountType, ok := accountTypeDigits[code[0]]
+	if !ok {
+		return "", fmt.Errorf("account code %s does not start with 1-5", code)
+	}
+	return accountType, nil
+}
+
+// DebitNormal reports whether accounts of a type grow with debits. Assets and
+// expenses do; liabilities, equity and income grow with credits.
+func DebitNormal(accountType models.GLAccountType) bool {
+	return accountType == models.GLAccountAsset || accountType == models.GLAccountExpense
+}
+
+// Balance is an account's balance on its normal side given its debits and
+// credits. It is negative when the account runs against its normal side.
+func Balance(accountType models.GLAccountType, debit, credit int64) int64 {
+	if DebitNormal(accountType) {
+		return debit - credit
+	}
+	return credit - debit
+}
+
+// ValidateEntry checks that an entry has at least two lines, that each line
+// is either a debit or a credit of a positive amount and that the debits
+// equal the credits
+func ValidateEntry(entry models.JournalEntry) error {
+	if strings.TrimSpace(entry.Description) == "" {
+		return fmt.Errorf("journal entry description is required")
+	}
+	if len(entry.Lines) < 2 {
+		return fmt.Errorf("journal entry needs at least two lines")
+	}
+
+	var debits, credits int64
+	for i, line := range entry.Lines {
+		if line.AccountCode == "" {
+			return fmt.Errorf("line %d has no account", i+1)
+		}
+		if line.Debit < 0 || line.Credit < 0 {
+			return fmt.Errorf("line %d has a negative amount", i+1)
+		}
+		if (line.Debit > 0) == (line.Credit > 0) {
+			return fmt.Errorf("line %d must be either a debit or a credit", i+1)
+		}
+		debits += line.Debit
+		credits += line.Credit
+	}
+	if debits != credits {
+		return fmt.Errorf("journal entry is not balanced: debits %d, credits %d", debits, credits)
+	}
+
+	return nil
+}
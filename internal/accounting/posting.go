@@ -0,0 +1,237 @@
+package accounting
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/models"
+)
+
+// Interest, penalties and fees are income when they are received, not when
+// they fall due. Each rule returns an entry without lines when nothing moves.
+
+// posting is an amount booked to an account: positive amounts are debits,
+// negative ones credits
+type posting struct {
+	account string
+	amount  int64
+}
+
+// linesOf turns postings into journal lines, leaving out zero amounts
+func linesOf(postings ...posting) []models.JournalLine {
+	lines := make([]models.JournalLine, 0, len(postings))
+	for _, p := range postings {
+		switch {
+		case p.amount > 0:
+			lines = append(lines, models.JournalLine{AccountCode: p.account, Debit: p.amount})
+		case p.amount < 0:
+			lines = append(lines, models.JournalLine{AccountCode: p.account, Credit: -p.amount})
+		}
+	}
+	return lines
+}
+
+// CashAccount is where money paid with a method is kept: cash in the cash
+// box, transfers and QRIS in the bank
+func CashAccount(method models.PaymentMethod) string {
+	if method == models.PaymentMethodCash {
+		return AccountKas
+	}
+	return AccountBank
+}
+
+// SavingsAccount is the account holding a type of member savings. Simpanan
+// pokok and wajib are the members' capital; sukarela is owed to them.
+func SavingsAccount(savingsType models.SavingsType) string {
+	switch savingsType {
+	case models.SavingsTypePokok:
+		return AccountSimpananPokok
+	case models.SavingsTypeWajib:
+		return AccountSimpananWajib
+	}
+	return AccountSimpananSukarela
+}
+
+// Disbursement books paying out a loan. The whole amount becomes a
+// receivable, the admin and provision fees deducted from it are earned and
+// the rest is paid out in cash.
+func Disbursement(loan models.Loan, disbursedAt time.Time, disbursedBy *uuid.UUID) models.JournalEntry {
+	return models.JournalEntry{
+		EntryDate:   disbursedAt,
+		Description: fmt.Sprintf("Disbursement of loan %s", loan.ContractNumber),
+		Source:      models.JournalSourceDisbursement,
+		SourceID:    &loan.ID,
+		PostedBy:    disbursedBy,
+		Lines: linesOf(
+			posting{AccountPiutangPinjaman, loan.Amount},
+			posting{AccountKas, -(loan.Amount - loan.AdminFee - loan.ProvisionFee)},
+			posting{AccountPendapatanAdmin, -loan.AdminFee},
+			posting{AccountPendapatanProvisi, -loan.ProvisionFee},
+		),
+	}
+}
+
+// Payment books a ledger entry of a loan payment. Cash received and credit
+// drawn from the loan settle principal, interest, penalty and the early
+// settlement fee; cash beyond that is held as credit. Capitalized arrears
+// earned by the principal paid move from Bunga dan Denda Ditangguhkan to
+// income. A reversal carries negated amounts, so its entry mirrors the
+// original.
+func Payment(payment models.Payment, contractNumber string) models.JournalEntry {
+	description := fmt.Sprintf("Payment %s on loan %s", payment.ReceiptNumber, contractNumber)
+	if payment.ReversalOf != nil {
+		description = fmt.Sprintf("Reversal %s on loan %s", payment.ReceiptNumber, contractNumber)
+	}
+
+	return models.JournalEntry{
+		EntryDate:   payment.PaymentDate,
+		Description: description,
+		Source:      models.JournalSourcePayment,
+		SourceID:    &payment.ID,
+		PostedBy:    payment.PostedBy,
+		Lines: linesOf(
+			posting{CashAccount(payment.Method), payment.Amount},
+			posting{AccountTitipanAngsuran, -payment.CreditAmount},
+			posting{AccountPiutangPinjaman, -payment.PrincipalAmount},
+			posting{AccountBungaDitangguhkan, payment.CapitalizedInterest + payment.CapitalizedPenalty},
+			posting{AccountPendapatanBunga, -(payment.InterestAmount + payment.CapitalizedInterest)},
+			posting{AccountPendapatanDenda, -(payment.PenaltyAmount + payment.CapitalizedPenalty)},
+			posting{AccountPendapatanAdmin, -payment.FeeAmount},
+		),
+	}
+}
+
+// SavingsTransaction books a line of a member's savings ledger. Credited
+// interest is an expense added to the savings and the tax withheld from it is
+// owed to the tax office. A member's share of the SHU is paid out of SHU
+// Belum Dibagi.
+func SavingsTransaction(transaction models.SavingsTransaction, account models.SavingsAccount) models.JournalEntry {
+	savings := SavingsAccount(account.Type)
+
+	var postings []posting
+	switch transaction.Type {
+	case models.SavingsTransactionDeposit:
+		postings = []posting{{CashAccount(transaction.Method), transaction.Amount}, {savings, -transaction.Amount}}
+	case models.SavingsTransactionWithdrawal:
+		postings = []posting{{savings, transaction.Amount}, {CashAccount(transaction.Method), -transaction.Amount}}
+	case models.SavingsTransactionInterest:
+		postings = []posting{{AccountBebanBungaSimpanan, transaction.Amount}, {savings, -transaction.Amount}}
+	case models.SavingsTransactionTax:
+		postings = []posting{{savings, transaction.Amount}, {AccountUtangPajak, -transaction.Amount}}
+	case models.SavingsTransactionSHU:
+		postings = []posting{{AccountSHUBelumDibagi, transaction.Amount}, {savings, -transaction.Amount}}
+	}
+
+	return models.JournalEntry{
+		EntryDate:   transaction.TransactionDate,
+		Description: fmt.Sprintf("Savings %s %s on %s", transaction.Type, transaction.ReceiptNumber, account.AccountNumber),
+		Source:      models.JournalSourceSavings,
+		SourceID:    &transaction.ID,
+		PostedBy:    transaction.PostedBy,
+		Lines:       linesOf(postings...),
+	}
+}
+
+// Capitalization books the interest and penalties a restructuring adds to
+// the principal. They become receivable but are not earned until paid.
+func Capitalization(restructuring models.LoanRestructuring, contractNumber string) models.JournalEntry {
+	return models.JournalEntry{
+		EntryDate:   restructuring.CreatedAt,
+		Description: fmt.Sprintf("Arrears capitalized by restructuring loan %s", contractNumber),
+		Source:      models.JournalSourceRestructuring,
+		SourceID:    &restructuring.ID,
+		PostedBy:    &restructuring.ApprovedBy,
+		Lines: linesOf(
+			posting{AccountPiutangPinjaman, restructuring.CapitalizedAmount},
+			posting{AccountBungaDitangguhkan, -restructuring.CapitalizedAmount},
+		),
+	}
+}
+
+// WriteOff books taking a loan's outstanding principal off the books.
+// Unpaid interest and penalties were never booked, so they need no entry.
+// Capitalized arrears still deferred in the principal were never earned
+// either; they are reversed out of Bunga dan Denda Ditangguhkan and only the
+// rest is an expense.
+func WriteOff(loanID uuid.UUID, contractNumber string, principal, deferred int64, writtenOffAt time.Time, writtenOffBy *uuid.UUID) models.JournalEntry {
+	return models.JournalEntry{
+		EntryDate:   writtenOffAt,
+		Description: fmt.Sprintf("Write-off of loan %s", contractNumber),
+		Source:      models.JournalSourceWriteOff,
+		SourceID:    &loanID,
+		PostedBy:    writtenOffBy,
+		Lines: linesOf(
+			posting{AccountBungaDitangguhkan, deferred},
+			posting{AccountBebanPenghapusan, principal - deferred},
+			posting{AccountPiutangPinjaman, -principal},
+		),
+	}
+}
+
+// Recovery books an amount collected on a written-off loan as income
+func Recovery(recovery models.LoanRecovery, contractNumber string) models.JournalEntry {
+	return models.JournalEntry{
+		EntryDate:   recovery.RecoveryDate,
+		Description: fmt.Sprintf("Recovery %s on written-off loan %s", recovery.ReceiptNumber, contractNumber),
+		Source:      models.JournalSourceRecovery,
+		SourceID:    &recovery.ID,
+		PostedBy:    recovery.PostedBy,
+		Lines: linesOf(
+			posting{CashAccount(recovery.Method), recovery.Amount},
+			posting{AccountPendapatanPemulihan, -recovery.Amount},
+		),
+	}
+}
+
+// Closing books closing a financial year on its last day: each income and
+// expense account is emptied into SHU Belum Dibagi, which is then credited
+// with the year's surplus. balances are each account's debits less credits
+// for the year.
+func Closing(year int, balances map[string]int64, closedBy *uuid.UUID) models.JournalEntry {
+	codes := make([]string, 0, len(balances))
+	for code := range balances {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var total int64
+	postings := make([]posting, 0, len(codes)+1)
+	for _, code := range codes {
+		total += balances[code]
+		postings = append(postings, posting{code, -balances[code]})
+	}
+
+	return models.JournalEntry{
+		EntryDate:   time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC),
+		Description: fmt.Sprintf("Closing of financial year %d", year),
+		Source:      models.JournalSourceClosing,
+		PostedBy:    closedBy,
+		Lines:       linesOf(append(postings, posting{AccountSHUBelumDibagi, total})...),
+	}
+}
+
+// SHUFunds books setting aside the funds' shares of a year's SHU, such as
+// cadangan and dana pengurus, out of SHU Belum Dibagi. The members' shares
+// are booked as lines of their savings.
+func SHUFunds(distribution models.SHUDistribution) models.JournalEntry {
+	var total int64
+	credits := make([]posting, 0, len(distribution.Funds))
+	for _, fund := range distribution.Funds {
+		total += fund.Amount
+		credits = append(credits, posting{fund.AccountCode, -fund.Amount})
+	}
+
+	entry := models.JournalEntry{
+		Description: fmt.Sprintf("SHU %d set aside for funds", distribution.Year),
+		Source:      models.JournalSourceSHU,
+		SourceID:    distribution.ID,
+		PostedBy:    distribution.PostedBy,
+		Lines:       linesOf(append([]posting{{AccountSHUBelumDibagi, total}}, credits...)...),
+	}
+	if distribution.PostedAt != nil {
+		entry.EntryDate = *distribution.PostedAt
+	}
+	return entry
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 )
@@ -13,7 +14,17 @@ type Config struct {
 	WhatsApp WhatsAppConfig `json:"whatsapp"`
 	Storage  StorageConfig  `json:"storage"`
 	Security SecurityConfig `json:"security"`
+	Penalty  PenaltyConfig  `json:"penalty"`
 	App      AppConfig      `json:"app"`
+
+	EarlySettlement EarlySettlementConfig `json:"early_settlement"`
+	Approval        ApprovalConfig        `json:"approval"`
+	Delinquency     DelinquencyConfig     `json:"delinquency"`
+	Affordability   AffordabilityConfig   `json:"affordability"`
+	Scoring         ScoringConfig         `json:"scoring"`
+	Savings         SavingsConfig         `json:"savings"`
+	SavingsInterest SavingsInterestConfig `json:"savings_interest"`
+	SHU             SHUConfig             `json:"shu"`
 }
 
 type DatabaseConfig struct {
@@ -37,13 +48,242 @@ type WhatsAppConfig struct {
 
 type StorageConfig struct {
 	DocumentsPath string `json:"documents_path"`
+	ReportsPath   string `json:"reports_path"` // where exported financial reports are written
 	UseS3         bool   `json:"use_s3"`
 	S3Bucket      string `json:"s3_bucket"`
 	S3Region      string `json:"s3_region"`
 }
 
 type SecurityConfig struct {
-	EncryptionKey string `json:"encryption_key"`
+	// EncryptionKey is an inline age secret key (AGE-SECRET-KEY-1...). When
+	// empty the key is read from EncryptionKeyFile instead.
+	EncryptionKey     string `json:"encryption_key"`
+	EncryptionKeyFile string `json:"encryption_key_file"`
+}
+
+// legacyPlaceholderKey is the dummy value older versions wrote as the default
+// encryption key. It is not a usable age identity and is ignored on load.
+const legacyPlaceholderKey = "age1q0z0x0y0w0v0u0t0s0r0q0p0o0n0m0l0k0j0i0h0g0f0e0d0c0b0a09"
+
+// PenaltyConfig defines the late payment penalty (denda) charged on overdue
+// installments. An empty Type disables penalties.
+type PenaltyConfig struct {
+	Type              string  `json:"type"`                // "percentage" or "fixed"
+	DailyRate         float64 `json:"daily_rate"`          // percent of the overdue amount per day
+	DailyAmount       int64   `json:"daily_amount"`        // rupiah per day
+	GraceDays         int     `json:"grace_days"`          // days after the due date before penalties start
+	MaxPenaltyPercent float64 `json:"max_penalty_percent"` // cap per installment as percent of its amount due; 0 means no cap
+}
+
+const (
+	PenaltyTypePercentage = "percentage"
+	PenaltyTypeFixed      = "fixed"
+)
+
+func (p PenaltyConfig) Validate() error {
+	switch p.Type {
+	case "", PenaltyTypePercentage, PenaltyTypeFixed:
+	default:
+		return fmt.Errorf("unknown penalty type: %s", p.Type)
+	}
+	if p.DailyRate < 0 || p.DailyAmount < 0 || p.GraceDays < 0 || p.MaxPenaltyPercent < 0 {
+		return fmt.Errorf("penalty settings must not be negative")
+	}
+	return nil
+}
+
+// EarlySettlementConfig prices paying a loan off before the end of its term
+// (pelunasan dipercepat).
+type EarlySettlementConfig struct {
+	FeePercent              float64 `json:"fee_percent"`               // fee as percent of the outstanding principal
+	InterestDiscountPercent float64 `json:"interest_discount_percent"` // percent of the accrued interest waived
+}
+
+func (e EarlySettlementConfig) Validate() error {
+	if e.FeePercent < 0 || e.FeePercent > 100 {
+		return fmt.Errorf("early settlement fee must be between 0 and 100 percent")
+	}
+	if e.InterestDiscountPercent < 0 || e.InterestDiscountPercent > 100 {
+		return fmt.Errorf("early settlement interest discount must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+// ApprovalConfig sets who may approve loans (maker-checker). Limits maps a
+// user role to the largest loan amount it may approve: a role with a limit of
+// 0, or missing from the map, cannot approve and a negative limit means no
+// limit. Loans above MultiLevelThreshold need MultiLevelApprovals different
+// approvers.
+type ApprovalConfig struct {
+	Limits              map[string]int64 `json:"limits"`
+	MultiLevelThreshold int64            `json:"multi_level_threshold"` // 0 disables multi-level approval
+	MultiLevelApprovals int              `json:"multi_level_approvals"`
+}
+
+func (a ApprovalConfig) Validate() error {
+	if a.Limits == nil {
+		return fmt.Errorf("approval limits are required")
+	}
+	if a.MultiLevelThreshold < 0 {
+		return fmt.Errorf("multi-level approval threshold must not be negative")
+	}
+	if a.MultiLevelThreshold > 0 && a.MultiLevelApprovals < 2 {
+		return fmt.Errorf("multi-level approval needs at least 2 approvers")
+	}
+	return nil
+}
+
+// DelinquencyConfig controls when the scheduler marks a running loan as
+// defaulted.
+type DelinquencyConfig struct {
+	DefaultDPD int `json:"default_dpd"` // days past due before a loan is defaulted; 0 disables
+}
+
+func (d DelinquencyConfig) Validate() error {
+	if d.DefaultDPD < 0 {
+		return fmt.Errorf("default DPD threshold must not be negative")
+	}
+	return nil
+}
+
+// AffordabilityConfig caps a member's repayment-to-income ratio (debt service
+// ratio): all monthly installments, including the new loan, as percent of
+// monthly income.
+type AffordabilityConfig struct {
+	MaxDSRPercent float64 `json:"max_dsr_percent"` // 0 disables the check
+}
+
+func (a AffordabilityConfig) Validate() error {
+	if a.MaxDSRPercent < 0 || a.MaxDSRPercent > 100 {
+		return fmt.Errorf("maximum debt service ratio must be between 0 and 100 percent")
+	}
+	return nil
+}
+
+// ScoringConfig weighs the factors of the applicant credit score. Only the
+// ratio between the weights matters; the score is always 0-100.
+type ScoringConfig struct {
+	PaymentHistory float64 `json:"payment_history"` // days past due and late payments on earlier loans
+	KTPVerified    float64 `json:"ktp_verified"`
+	Documents      float64 `json:"documents"` // KTP and KK on file
+	Age            float64 `json:"age"`
+	Income         float64 `json:"income"`
+	Membership     float64 `json:"membership"` // months since joining the koperasi
+}
+
+func (s ScoringConfig) Validate() error {
+	weights := []float64{s.PaymentHistory, s.KTPVerified, s.Documents, s.Age, s.Income, s.Membership}
+	var total float64
+	for _, weight := range weights {
+		if weight < 0 {
+			return fmt.Errorf("scoring weights must not be negative")
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("at least one scoring weight must be greater than 0")
+	}
+	return nil
+}
+
+// SavingsConfig sets the member savings every member must hold. Changes only
+// apply to accounts opened afterwards.
+type SavingsConfig struct {
+	PokokAmount  int64 `json:"pokok_amount"`  // simpanan pokok paid once on joining; 0 accepts any amount
+	WajibMonthly int64 `json:"wajib_monthly"` // simpanan wajib owed each month
+}
+
+func (s SavingsConfig) Validate() error {
+	if s.PokokAmount < 0 || s.WajibMonthly < 0 {
+		return fmt.Errorf("savings amounts must not be negative")
+	}
+	return nil
+}
+
+// SavingsInterestConfig prices the interest on simpanan sukarela. Interest
+// accrues daily on the end-of-day balance at AnnualRate, or at the rate of
+// the highest tier the balance reaches, and is credited monthly. Tax is
+// withheld from a month's interest once it is above TaxThreshold (bunga
+// simpanan anggota koperasi up to Rp 240.000 a month is not taxed).
+type SavingsInterestConfig struct {
+	AnnualRate   float64               `json:"annual_rate"` // percent per year on balances below the first tier
+	Tiers        []SavingsInterestTier `json:"tiers"`       // optional, by ascending minimum balance
+	TaxRate      float64               `json:"tax_rate"`    // percent of the month's interest
+	TaxThreshold int64                 `json:"tax_threshold"`
+}
+
+// SavingsInterestTier applies its rate to the whole balance once the balance
+// reaches MinBalance
+type SavingsInterestTier struct {
+	MinBalance int64   `json:"min_balance"`
+	AnnualRate float64 `json:"annual_rate"`
+}
+
+func (s SavingsInterestConfig) Validate() error {
+	if s.AnnualRate < 0 || s.AnnualRate > 100 {
+		return fmt.Errorf("savings interest rate must be between 0 and 100 percent")
+	}
+	for i, tier := range s.Tiers {
+		if tier.AnnualRate < 0 || tier.AnnualRate > 100 {
+			return fmt.Errorf("savings interest tier rates must be between 0 and 100 percent")
+		}
+		if tier.MinBalance < 0 || (i > 0 && tier.MinBalance <= s.Tiers[i-1].MinBalance) {
+			return fmt.Errorf("savings interest tiers must have ascending minimum balances")
+		}
+	}
+	if s.TaxRate < 0 || s.TaxRate > 100 {
+		return fmt.Errorf("savings interest tax rate must be between 0 and 100 percent")
+	}
+	if s.TaxThreshold < 0 {
+		return fmt.Errorf("savings interest tax threshold must not be negative")
+	}
+	return nil
+}
+
+// SHUConfig divides the year's surplus (SHU) as the AD/ART sets out. Jasa
+// modal is shared by the members' simpanan pokok and wajib, jasa usaha by the
+// loan interest they paid; the funds are kept in ledger accounts. The
+// percentages add up to 100.
+type SHUConfig struct {
+	JasaModal float64   `json:"jasa_modal"` // percent of the surplus
+	JasaUsaha float64   `json:"jasa_usaha"`
+	Funds     []SHUFund `json:"funds"`
+}
+
+// SHUFund is a share of the surplus set aside in a liability or equity
+// account, such as cadangan or dana pengurus
+type SHUFund struct {
+	Name        string  `json:"name"`
+	Percent     float64 `json:"percent"`
+	AccountCode string  `json:"account_code"`
+}
+
+func (s SHUConfig) Validate() error {
+	if s.JasaModal < 0 || s.JasaUsaha < 0 {
+		return fmt.Errorf("SHU percentages must not be negative")
+	}
+	total := s.JasaModal + s.JasaUsaha
+	names := make(map[string]bool, len(s.Funds))
+	for _, fund := range s.Funds {
+		if fund.Name == "" {
+			return fmt.Errorf("SHU funds need a name")
+		}
+		if names[fund.Name] {
+			return fmt.Errorf("SHU fund %s is listed twice", fund.Name)
+		}
+		names[fund.Name] = true
+		if fund.Percent < 0 {
+			return fmt.Errorf("SHU percentages must not be negative")
+		}
+		if len(fund.AccountCode) != 4 || (fund.AccountCode[0] != '2' && fund.AccountCode[0] != '3') {
+			return fmt.Errorf("SHU fund %s must be kept in a liability or equity account", fund.Name)
+		}
+		total += fund.Percent
+	}
+	if math.Abs(total-100) > 0.001 {
+		return fmt.Errorf("SHU percentages add up to %v, not 100", total)
+	}
+	return nil
 }
 
 type AppConfig struct {
@@ -75,6 +315,49 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
 
+	if config.Security.EncryptionKey == legacyPlaceholderKey {
+		config.Security.EncryptionKey = ""
+	}
+	if config.Security.EncryptionKeyFile == "" {
+		config.Security.EncryptionKeyFile = defaultKeyFilePath(homeDir)
+	}
+
+	// Configs written before approval limits, credit scoring, savings,
+	// savings interest or SHU existed get the defaults
+	if config.Approval.Limits == nil {
+		config.Approval = defaultApprovalConfig()
+	}
+	if config.Scoring == (ScoringConfig{}) {
+		config.Scoring = defaultScoringConfig()
+	}
+	if config.Savings == (SavingsConfig{}) {
+		config.Savings = defaultSavingsConfig()
+	}
+	if interest := config.SavingsInterest; interest.Tiers == nil && interest.AnnualRate == 0 &&
+		interest.TaxRate == 0 && interest.TaxThreshold == 0 {
+		config.SavingsInterest = defaultSavingsInterestConfig()
+	}
+	if shu := config.SHU; shu.Funds == nil && shu.JasaModal == 0 && shu.JasaUsaha == 0 {
+		config.SHU = defaultSHUConfig()
+	}
+
+	// A zero penalty, delinquency or affordability config disables that
+	// feature, so they are only defaulted when the file has no such section
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+	defaults := getDefaultConfig()
+	if _, ok := sections["penalty"]; !ok {
+		config.Penalty = defaults.Penalty
+	}
+	if _, ok := sections["delinquency"]; !ok {
+		config.Delinquency = defaults.Delinquency
+	}
+	if _, ok := sections["affordability"]; !ok {
+		config.Affordability = defaults.Affordability
+	}
+
 	return &config, nil
 }
 
@@ -95,7 +378,8 @@ func (c *Config) Save() error {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	return os.WriteFile(configPath, data, 0644)
+	// The config may hold an inline encryption key
+	return os.WriteFile(configPath, data, 0600)
 }
 
 func getDefaultConfig() *Config {
@@ -107,11 +391,32 @@ func getDefaultConfig() *Config {
 		},
 		Storage: StorageConfig{
 			DocumentsPath: filepath.Join(homeDir, ".koperasi", "documents"),
+			ReportsPath:   filepath.Join(homeDir, ".koperasi", "reports"),
 			UseS3:         false,
 		},
 		Security: SecurityConfig{
-			EncryptionKey: generateEncryptionKey(),
+			EncryptionKeyFile: defaultKeyFilePath(homeDir),
+		},
+		Penalty: PenaltyConfig{
+			Type:              PenaltyTypePercentage,
+			DailyRate:         0.1,
+			GraceDays:         3,
+			MaxPenaltyPercent: 50,
+		},
+		EarlySettlement: EarlySettlementConfig{
+			FeePercent: 1,
+		},
+		Approval: defaultApprovalConfig(),
+		Delinquency: DelinquencyConfig{
+			DefaultDPD: 90,
 		},
+		Affordability: AffordabilityConfig{
+			MaxDSRPercent: 30,
+		},
+		Scoring:         defaultScoringConfig(),
+		Savings:         defaultSavingsConfig(),
+		SavingsInterest: defaultSavingsInterestConfig(),
+		SHU:             defaultSHUConfig(),
 		App: AppConfig{
 			Name:            "Koperasi App",
 			Version:         "1.0.0",
@@ -121,6 +426,58 @@ func getDefaultConfig() *Config {
 	}
 }
 
-func generateEncryptionKey() string {
-	return "age1q0z0x0y0w0v0u0t0s0r0q0p0o0n0m0l0k0j0i0h0g0f0e0d0c0b0a09"
+func defaultApprovalConfig() ApprovalConfig {
+	return ApprovalConfig{
+		Limits: map[string]int64{
+			"karyawan":   0,
+			"admin":      10000000,
+			"superadmin": -1,
+		},
+		MultiLevelThreshold: 50000000,
+		MultiLevelApprovals: 2,
+	}
+}
+
+func defaultScoringConfig() ScoringConfig {
+	return ScoringConfig{
+		PaymentHistory: 35,
+		KTPVerified:    10,
+		Documents:      10,
+		Age:            10,
+		Income:         20,
+		Membership:     15,
+	}
+}
+
+func defaultSavingsConfig() SavingsConfig {
+	return SavingsConfig{
+		PokokAmount:  100000,
+		WajibMonthly: 50000,
+	}
+}
+
+func defaultSavingsInterestConfig() SavingsInterestConfig {
+	return SavingsInterestConfig{
+		AnnualRate:   3,
+		TaxRate:      10,
+		TaxThreshold: 240000,
+	}
+}
+
+func defaultSHUConfig() SHUConfig {
+	return SHUConfig{
+		JasaModal: 20,
+		JasaUsaha: 25,
+		Funds: []SHUFund{
+			{Name: "Cadangan", Percent: 40, AccountCode: "3201"},
+			{Name: "Dana Pengurus", Percent: 5, AccountCode: "2201"},
+			{Name: "Dana Karyawan", Percent: 5, AccountCode: "2202"},
+			{Name: "Dana Pendidikan", Percent: 2.5, AccountCode: "2203"},
+			{Name: "Dana Sosial", Percent: 2.5, AccountCode: "2204"},
+		},
+	}
+}
+
+func defaultKeyFilePath(homeDir string) string {
+	return filepath.Join(homeDir, ".koperasi", "encryption.key")
 }
\ No newline at end of file
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/utils"
+)
+
+// LoadEncryptor loads the PII encryption key configured in cfg and returns an
+// encryptor for the services. On first run a new key file is created. It
+// refuses to create a new key when customer rows are already encrypted, and
+// refuses a key that cannot decrypt the existing data.
+func (db *DB) LoadEncryptor(cfg *config.Config) (*utils.Encryptor, error) {
+	identity, err := db.loadIdentity(cfg.Security)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.verifyIdentity(identity); err != nil {
+		return nil, err
+	}
+
+	var version int
+	err = db.WithTx(func(tx *sql.Tx) error {
+		var err error
+		version, err = RegisterKeyVersion(tx, identity)
+		if err != nil {
+			return err
+		}
+
+		// Rows written before key versioning use the verified current key
+		_, err = tx.Exec("UPDATE customers SET key_version = ? WHERE key_version IS NULL", version)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register encryption key version: %v", err)
+	}
+
+	encryptor := utils.NewEncryptor(identity, version)
+	if err := db.backfillBlindIndexes(encryptor); err != nil {
+		return nil, err
+	}
+
+	return encryptor, nil
+}
+
+// RegisterKeyVersion returns the key version of identity, adding it to the
+// encryption_keys table if it has not been seen before.
+func RegisterKeyVersion(tx *sql.Tx, identity *age.X25519Identity) (int, error) {
+	publicKey := identity.Recipient().String()
+
+	var version int
+	err := tx.QueryRow("SELECT version FROM encryption_keys WHERE public_key = ?", publicKey).Scan(&version)
+	if err == nil {
+		return version, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	err = tx.QueryRow("SELECT COALESCE(MAX(version), 0) + 1 FROM encryption_keys").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("INSERT INTO encryption_keys (version, public_key) VALUES (?, ?)", version, publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+func (db *DB) loadIdentity(sec config.SecurityConfig) (*age.X25519Identity, error) {
+	if sec.EncryptionKey != "" {
+		return utils.ParseIdentity(sec.EncryptionKey)
+	}
+
+	if sec.EncryptionKeyFile == "" {
+		return nil, fmt.Errorf("no encryption key or key file configured")
+	}
+
+	identity, err := utils.LoadIdentityFile(sec.EncryptionKeyFile)
+	if err == nil {
+		return identity, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// No key yet: only safe to create one if nothing is encrypted
+	count, err := db.countEncryptedCustomers()
+	if err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("encryption key file %s not found but %d customers hold encrypted data; restore the key before starting", sec.EncryptionKeyFile, count)
+	}
+
+	identity, err = age.GenerateX25519Identity()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate encryption identity: %v", err)
+	}
+
+	if err := utils.WriteIdentityFile(sec.EncryptionKeyFile, identity); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Created new encryption key file at %s; back it up, customer data cannot be read without it", sec.EncryptionKeyFile)
+	return identity, nil
+}
+
+// verifyIdentity checks the key against one encrypted NIK so a wrong key is
+// caught at startup instead of silently showing blank fields.
+func (db *DB) verifyIdentity(identity *age.X25519Identity) error {
+	var encryptedNIK string
+	err := db.QueryRow(`
+		SELECT nik FROM customers
+		WHERE nik != ''
+		ORDER BY key_version DESC
+		LIMIT 1`).Scan(&encryptedNIK)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read customer data: %v", err)
+	}
+
+	if _, err := utils.NewEncryptor(identity, 0).Decrypt(encryptedNIK); err != nil {
+		return fmt.Errorf("encryption key does not match existing customer data: %v", err)
+	}
+
+	return nil
+}
+
+func (db *DB) countEncryptedCustomers() (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM customers WHERE nik != ''").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count customers: %v", err)
+	}
+	return count, nil
+}
+
+// backfillBlindIndexes fills nik_hash, phone_hash and email_hash for rows
+// written before blind indexes existed. Duplicate NIKs or phones already in
+// the data cannot get the unique hash; they are logged for manual cleanup.
+func (db *DB) backfillBlindIndexes(encryptor *utils.Encryptor) error {
+	rows, err := db.Query(`
+		SELECT id, nik, COALESCE(email, ''), COALESCE(phone, '')
+		FROM customers
+		WHERE nik_hash IS NULL`)
+	if err != nil {
+		return fmt.Errorf("failed to query customers for blind index backfill: %v", err)
+	}
+
+	type pendingRow struct {
+		id, nik, email, phone string
+	}
+	pending := make([]pendingRow, 0)
+	for rows.Next() {
+		var row pendingRow
+		if err := rows.Scan(&row.id, &row.nik, &row.email, &row.phone); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan customer: %v", err)
+		}
+		pending = append(pending, row)
+	}
+	rows.Close()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	backfilled := 0
+	for _, row := range pending {
+		nik, err := encryptor.Decrypt(row.nik)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt NIK of customer %s: %v", row.id, err)
+		}
+		email, err := encryptor.Decrypt(row.email)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt email of customer %s: %v", row.id, err)
+		}
+		phone, err := encryptor.Decrypt(row.phone)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt phone of customer %s: %v", row.id, err)
+		}
+
+		_, err = db.Exec(`
+			UPDATE customers
+			SET nik_hash = NULLIF(?, ''), phone_hash = NULLIF(?, ''), email_hash = NULLIF(?, '')
+			WHERE id = ?`,
+			encryptor.BlindIndex(utils.NormalizeNIK(nik)),
+			encryptor.BlindIndex(utils.NormalizeIndonesianPhone(phone)),
+			encryptor.BlindIndex(utils.NormalizeEmail(email)),
+			row.id)
+		if err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				log.Printf("Customer %s duplicates the NIK, phone or email of another customer; blind index left empty: %v", row.id, err)
+				continue
+			}
+			return fmt.Errorf("failed to backfill blind index for customer %s: %v", row.id, err)
+		}
+		backfilled++
+	}
+
+	log.Printf("Backfilled blind indexes for %d of %d customers", backfilled, len(pending))
+	return nil
+}
@@ -14,11 +14,12 @@ import (
 )
 
 type Seeder struct {
-	db *DB
+	db        *DB
+	encryptor *utils.Encryptor
 }
 
-func NewSeeder(db *DB) *Seeder {
-	return &Seeder{db: db}
+func NewSeeder(db *DB, encryptor *utils.Encryptor) *Seeder {
+	return &Seeder{db: db, encryptor: encryptor}
 }
 
 func (s *Seeder) SeedAll() error {
@@ -48,6 +49,10 @@ func (s *Seeder) SeedAll() error {
 		return fmt.Errorf("failed to seed customers: %v", err)
 	}
 
+	if err := s.seedLoanProducts(); err != nil {
+		return fmt.Errorf("failed to seed loan products: %v", err)
+	}
+
 	if err := s.seedLoans(); err != nil {
 		return fmt.Errorf("failed to seed loans: %v", err)
 	}
@@ -245,9 +250,9 @@ func (s *Seeder) seedCustomers() error {
 		}
 
 		// Encrypt sensitive data
-		encryptedNIK, _ := utils.Encrypt(customer.NIK)
-		encryptedEmail, _ := utils.Encrypt(customer.Email)
-		encryptedPhone, _ := utils.Encrypt(customer.Phone)
+		encryptedNIK, _ := s.encryptor.Encrypt(customer.NIK)
+		encryptedEmail, _ := s.encryptor.Encrypt(customer.Email)
+		encryptedPhone, _ := s.encryptor.Encrypt(customer.Phone)
 
 		var referralCodeIDStr *string
 		if customer.ReferralCodeID != nil {
@@ -256,11 +261,16 @@ func (s *Seeder) seedCustomers() error {
 		}
 
 		_, err := s.db.Exec(`
-			INSERT INTO customers (id, nik, name, email, phone, date_of_birth, address, city, province, postal_code, occupation, monthly_income, referral_code_id, status, ktp_verified)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			INSERT INTO customers (id, nik, name, email, phone, date_of_birth, address, city, province, postal_code, occupation, monthly_income, referral_code_id, status, ktp_verified, key_version,
+			                       nik_hash, phone_hash, email_hash)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 			customer.ID.String(), encryptedNIK, customer.Name, encryptedEmail, encryptedPhone,
 			customer.DateOfBirth, customer.Address, customer.City, customer.Province, customer.PostalCode,
-			customer.Occupation, customer.MonthlyIncome, referralCodeIDStr, string(customer.Status), customer.KTPVerified)
+			customer.Occupation, customer.MonthlyIncome, referralCodeIDStr, string(customer.Status), customer.KTPVerified,
+			s.encryptor.Version(),
+			s.encryptor.BlindIndex(utils.NormalizeNIK(customer.NIK)),
+			s.encryptor.BlindIndex(utils.NormalizeIndonesianPhone(customer.Phone)),
+			s.encryptor.BlindIndex(utils.NormalizeEmail(customer.Email)))
 		if err != nil {
 			return err
 		}
@@ -284,7 +294,51 @@ func (s *Seeder) seedCustomers() error {
 	return nil
 }
 
+func (s *Seeder) seedLoanProducts() error {
+	products := []struct {
+		code, name, description       string
+		minAmount, maxAmount          int64
+		allowedTerms                  string
+		defaultRate, minRate, maxRate float64
+		interestMethod                models.InterestMethod
+		adminFee                      int64
+		provisionFeeRate              float64
+		minMembershipMonths           int
+		requireKTPVerified            bool
+	}{
+		{"PRODUKTIF", "Pinjaman Produktif", "Modal usaha untuk anggota", 5000000, 100000000, "[6,12,18,24,36]",
+			14, 10, 24, models.InterestMethodAnnuity, 50000, 1, 3, true},
+		{"DARURAT", "Pinjaman Darurat", "Kebutuhan mendesak, bunga flat 1.5% per bulan", 500000, 10000000, "[3,6,12]",
+			18, 12, 24, models.InterestMethodFlat, 25000, 0, 0, false},
+	}
+
+	for _, product := range products {
+		_, err := s.db.Exec(`
+			INSERT INTO loan_products (id, code, name, description, min_amount, max_amount, allowed_terms,
+				default_interest_rate, min_interest_rate, max_interest_rate, interest_method,
+				admin_fee, provision_fee_rate, max_active_loans, min_membership_months, require_ktp_verified)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)`,
+			uuid.New().String(), product.code, product.name, product.description,
+			product.minAmount, product.maxAmount, product.allowedTerms,
+			product.defaultRate, product.minRate, product.maxRate, string(product.interestMethod),
+			product.adminFee, product.provisionFeeRate, product.minMembershipMonths, product.requireKTPVerified)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Println("Seeded loan products")
+	return nil
+}
+
 func (s *Seeder) seedLoans() error {
+	var productID string
+	if err := s.db.QueryRow("SELECT id FROM loan_products WHERE code = 'PRODUKTIF'").Scan(&productID); err != nil {
+		return err
+	}
+	productUUID := uuid.MustParse(productID)
+	terms := []int{6, 12, 18, 24, 36}
+
 	// Get customers
 	rows, err := s.db.Query("SELECT id FROM customers LIMIT 150")
 	if err != nil {
@@ -310,27 +364,42 @@ func (s *Seeder) seedLoans() error {
 				ContractNumber: fmt.Sprintf("KOP-%d-%04d", time.Now().Year(), i+1),
 				Amount:         int64(randomInt(5000000, 100000000)),
 				InterestRate:   float64(randomInt(10, 24)),
-				Term:           randomInt(6, 36),
+				Term:           terms[randomInt(0, len(terms))],
 				Status:         models.LoanStatusActive,
 				DisbursedAt:    timePtr(generateRandomDate(time.Now().AddDate(-2, 0, 0), time.Now().AddDate(-1, 0, 0))),
 				DueDate:        generateRandomDate(time.Now().AddDate(0, 6, 0), time.Now().AddDate(3, 0, 0)),
+				ProductID:      &productUUID,
 			}
+			loan.AdminFee = 50000
+			loan.ProvisionFee = loan.Amount / 100
 
-			// Calculate monthly payment (simple calculation)
-			monthlyInterest := loan.InterestRate / 100 / 12
-			loan.MonthlyPayment = int64(float64(loan.Amount) * monthlyInterest / (1 - 1/float64(1+monthlyInterest)*float64(loan.Term)))
+			schedule, err := utils.AmortizationSchedule(loan.Amount, loan.InterestRate, loan.Term, *loan.DisbursedAt, models.RepaymentFrequencyMonthly)
+			if err != nil {
+				return err
+			}
+			loan.MonthlyPayment = schedule[0].AmountDue
 
-			_, err := s.db.Exec(`
-				INSERT INTO loans (id, customer_id, contract_number, amount, interest_rate, term, monthly_payment, status, disbursed_at, due_date)
-				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			_, err = s.db.Exec(`
+				INSERT INTO loans (id, customer_id, contract_number, amount, interest_rate, term, monthly_payment,
+					product_id, admin_fee, provision_fee, status, disbursed_at, due_date)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 				loan.ID.String(), loan.CustomerID.String(), loan.ContractNumber, loan.Amount, loan.InterestRate,
-				loan.Term, loan.MonthlyPayment, string(loan.Status), loan.DisbursedAt, loan.DueDate)
+				loan.Term, loan.MonthlyPayment, productID, loan.AdminFee, loan.ProvisionFee,
+				string(loan.Status), loan.DisbursedAt, loan.DueDate)
+			if err != nil {
+				return err
+			}
+
+			_, err = s.db.Exec(`
+				INSERT INTO loan_status_history (id, loan_id, to_status, reason, created_at)
+				VALUES (?, ?, ?, ?, ?)`,
+				uuid.New().String(), loan.ID.String(), string(loan.Status), "seeded", loan.DisbursedAt)
 			if err != nil {
 				return err
 			}
 
 			// Create installments for this loan
-			if err := s.seedInstallments(loan); err != nil {
+			if err := s.seedInstallments(loan, schedule); err != nil {
 				return err
 			}
 		}
@@ -340,15 +409,9 @@ func (s *Seeder) seedLoans() error {
 	return nil
 }
 
-func (s *Seeder) seedInstallments(loan models.Loan) error {
-	startDate := loan.DisbursedAt
-	if startDate == nil {
-		now := time.Now()
-		startDate = &now
-	}
-
-	for i := 1; i <= loan.Term; i++ {
-		dueDate := startDate.AddDate(0, i, 0)
+func (s *Seeder) seedInstallments(loan models.Loan, schedule []utils.AmortizationLine) error {
+	for _, line := range schedule {
+		dueDate := line.DueDate
 		status := models.InstallmentStatusPending
 		var paidAt *time.Time
 		amountPaid := int64(0)
@@ -360,29 +423,33 @@ func (s *Seeder) seedInstallments(loan models.Loan) error {
 			status = models.InstallmentStatusPaid
 			paidDate := generateRandomDate(dueDate.AddDate(0, 0, -5), dueDate.AddDate(0, 0, 2))
 			paidAt = &paidDate
-			amountPaid = loan.MonthlyPayment
+			amountPaid = line.AmountDue
 		} else if rand <= 90 && dueDate.Before(time.Now()) {
 			status = models.InstallmentStatusOverdue
 			dpd = int(time.Since(dueDate).Hours() / 24)
 		}
 
 		installment := models.LoanInstallment{
-			ID:         uuid.New(),
-			LoanID:     loan.ID,
-			Number:     i,
-			DueDate:    dueDate,
-			AmountDue:  loan.MonthlyPayment,
-			AmountPaid: amountPaid,
-			Status:     status,
-			PaidAt:     paidAt,
-			DPD:        dpd,
+			ID:                 uuid.New(),
+			LoanID:             loan.ID,
+			Number:             line.Number,
+			DueDate:            dueDate,
+			PrincipalDue:       line.PrincipalDue,
+			InterestDue:        line.InterestDue,
+			AmountDue:          line.AmountDue,
+			AmountPaid:         amountPaid,
+			OutstandingBalance: line.OutstandingBalance,
+			Status:             status,
+			PaidAt:             paidAt,
+			DPD:                dpd,
 		}
 
 		_, err := s.db.Exec(`
-			INSERT INTO loan_installments (id, loan_id, number, due_date, amount_due, amount_paid, status, paid_at, dpd)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			INSERT INTO loan_installments (id, loan_id, number, due_date, principal_due, interest_due, amount_due, amount_paid, outstanding_balance, status, paid_at, dpd)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 			installment.ID.String(), installment.LoanID.String(), installment.Number, installment.DueDate,
-			installment.AmountDue, installment.AmountPaid, string(installment.Status), installment.PaidAt, installment.DPD)
+			installment.PrincipalDue, installment.InterestDue, installment.AmountDue, installment.AmountPaid,
+			installment.OutstandingBalance, string(installment.Status), installment.PaidAt, installment.DPD)
 		if err != nil {
 			return err
 		}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"math"
 	"time"
 
 	"github.com/google/uuid"
@@ -45,6 +46,7 @@ type Customer struct {
 	ReferralCode    *ReferralCode     `json:"referral_code,omitempty"`
 	Documents       []Document        `json:"documents,omitempty"`
 	Loans           []Loan            `json:"loans,omitempty"`
+	Savings         []SavingsAccount  `json:"savings,omitempty"`
 }
 
 type CustomerStatus string
@@ -107,20 +109,63 @@ const (
 )
 
 type Loan struct {
-	ID                uuid.UUID         `json:"id" db:"id"`
-	CustomerID        uuid.UUID         `json:"customer_id" db:"customer_id"`
-	ContractNumber    string            `json:"contract_number" db:"contract_number"`
-	Amount            int64             `json:"amount" db:"amount"`
-	InterestRate      float64           `json:"interest_rate" db:"interest_rate"`
-	Term              int               `json:"term" db:"term"`
-	MonthlyPayment    int64             `json:"monthly_payment" db:"monthly_payment"`
-	Status            LoanStatus        `json:"status" db:"status"`
-	DisbursedAt       *time.Time        `json:"disbursed_at" db:"disbursed_at"`
-	DueDate           time.Time         `json:"due_date" db:"due_date"`
-	CreatedAt         time.Time         `json:"created_at" db:"created_at"`
-	UpdatedAt         time.Time         `json:"updated_at" db:"updated_at"`
-	Customer          *Customer         `json:"customer,omitempty"`
-	Installments      []LoanInstallment `json:"installments,omitempty"`
+	ID                 uuid.UUID                `json:"id" db:"id"`
+	CustomerID         uuid.UUID                `json:"customer_id" db:"customer_id"`
+	ContractNumber     string                   `json:"contract_number" db:"contract_number"`
+	Amount             int64                    `json:"amount" db:"amount"`
+	InterestRate       float64                  `json:"interest_rate" db:"interest_rate"`
+	InterestMethod     InterestMethod           `json:"interest_method" db:"interest_method"`
+	Term               int                      `json:"term" db:"term"` // number of installments
+	RepaymentFrequency RepaymentFrequency       `json:"repayment_frequency" db:"repayment_frequency"`
+	MonthlyPayment     int64                    `json:"monthly_payment" db:"monthly_payment"` // amount of each installment, whatever the frequency
+	ProductID          *uuid.UUID               `json:"product_id" db:"product_id"`
+	AdminFee           int64                    `json:"admin_fee" db:"admin_fee"`
+	ProvisionFee       int64                    `json:"provision_fee" db:"provision_fee"`
+	CreditBalance      int64                    `json:"credit_balance"` // overpayments kept on the loan
+	ScheduleVersion    int                      `json:"schedule_version" db:"schedule_version"`
+	Restructured       bool                     `json:"restructured" db:"restructured"`
+	Status             LoanStatus               `json:"status" db:"status"`
+	DisbursedAt        *time.Time               `json:"disbursed_at" db:"disbursed_at"`
+	SettledAt          *time.Time               `json:"settled_at" db:"settled_at"`
+	SettlementReason   string                   `json:"settlement_reason" db:"settlement_reason"`
+	DueDate            time.Time                `json:"due_date" db:"due_date"`
+	CreatedBy          *uuid.UUID               `json:"created_by" db:"created_by"`
+	SubmittedBy        *uuid.UUID               `json:"submitted_by" db:"submitted_by"`
+	SubmittedAt        *time.Time               `json:"submitted_at" db:"submitted_at"`           // nil while the loan is a draft
+	OtherObligations   int64                    `json:"other_obligations" db:"other_obligations"` // monthly, outside the koperasi
+	DSROverrideReason  string                   `json:"dsr_override_reason" db:"dsr_override_reason"`
+	CreditScore        *CreditScore             `json:"credit_score,omitempty"` // applicant score when the loan was created
+	WriteOff           *LoanWriteOff            `json:"write_off,omitempty"`
+	CreatedAt          time.Time                `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time                `json:"updated_at" db:"updated_at"`
+	Customer           *Customer                `json:"customer,omitempty"`
+	Product            *LoanProduct             `json:"product,omitempty"`
+	Installments       []LoanInstallment        `json:"installments,omitempty"`
+	Approvals          []LoanApproval           `json:"approvals,omitempty"`
+	Affordability      *AffordabilityAssessment `json:"affordability,omitempty"` // only while the loan awaits a decision
+}
+
+// AffordabilityAssessment is the repayment-to-income (debt service ratio)
+// check of a loan application. Amounts are monthly, in rupiah.
+type AffordabilityAssessment struct {
+	MonthlyIncome        int64   `json:"monthly_income"`
+	MonthlyPayment       int64   `json:"monthly_payment"`       // installment of this loan, per month
+	ExistingInstallments int64   `json:"existing_installments"` // the member's other loans at the koperasi
+	OtherObligations     int64   `json:"other_obligations"`     // declared obligations elsewhere
+	TotalObligations     int64   `json:"total_obligations"`
+	DSRPercent           float64 `json:"dsr_percent"`
+	MaxDSRPercent        float64 `json:"max_dsr_percent"` // 0 when the check is disabled
+	Exceeded             bool    `json:"exceeded"`
+	OverrideReason       string  `json:"override_reason,omitempty"`
+}
+
+// CreditScore is the internal credit score of a loan applicant: 0-100, graded
+// A (best) to E, with reason codes for the factors that lowered it.
+type CreditScore struct {
+	Score    int       `json:"score"`
+	Grade    string    `json:"grade"`
+	Reasons  []string  `json:"reasons"`
+	ScoredAt time.Time `json:"scored_at"`
 }
 
 type LoanStatus string
@@ -133,24 +178,284 @@ const (
 	LoanStatusCompleted  LoanStatus = "completed"
 	LoanStatusDefaulted  LoanStatus = "defaulted"
 	LoanStatusCancelled  LoanStatus = "cancelled"
+	LoanStatusWrittenOff LoanStatus = "written_off"
+)
+
+// InterestMethod determines how a loan's installments are computed.
+// InterestRate is always expressed in percent per year.
+type InterestMethod string
+
+const (
+	InterestMethodFlat             InterestMethod = "flat"
+	InterestMethodAnnuity          InterestMethod = "annuity"
+	InterestMethodDecliningBalance InterestMethod = "declining_balance"
+)
+
+// RepaymentFrequency is how often a loan's installments fall due. A loan's
+// term counts installments, and its annual interest rate is spread over the
+// periods of a year.
+type RepaymentFrequency string
+
+const (
+	RepaymentFrequencyWeekly   RepaymentFrequency = "weekly"
+	RepaymentFrequencyBiweekly RepaymentFrequency = "biweekly"
+	RepaymentFrequencyMonthly  RepaymentFrequency = "monthly"
 )
 
+// PeriodsPerYear is the number of installments a year
+func (f RepaymentFrequency) PeriodsPerYear() int {
+	switch f {
+	case RepaymentFrequencyWeekly:
+		return 52
+	case RepaymentFrequencyBiweekly:
+		return 26
+	}
+	return 12
+}
+
+// DueDate is when installment n of a schedule starting at start falls due
+func (f RepaymentFrequency) DueDate(start time.Time, n int) time.Time {
+	switch f {
+	case RepaymentFrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case RepaymentFrequencyBiweekly:
+		return start.AddDate(0, 0, 14*n)
+	}
+	return start.AddDate(0, n, 0)
+}
+
+// MonthlyEquivalent converts an installment amount into what it costs per
+// month
+func (f RepaymentFrequency) MonthlyEquivalent(amount int64) int64 {
+	return int64(math.Round(float64(amount) * float64(f.PeriodsPerYear()) / 12))
+}
+
+type LoanProduct struct {
+	ID                  uuid.UUID          `json:"id" db:"id"`
+	Code                string             `json:"code" db:"code"`
+	Name                string             `json:"name" db:"name"`
+	Description         string             `json:"description" db:"description"`
+	MinAmount           int64              `json:"min_amount" db:"min_amount"`
+	MaxAmount           int64              `json:"max_amount" db:"max_amount"`
+	AllowedTerms        []int              `json:"allowed_terms" db:"allowed_terms"`
+	DefaultInterestRate float64            `json:"default_interest_rate" db:"default_interest_rate"`
+	MinInterestRate     float64            `json:"min_interest_rate" db:"min_interest_rate"`
+	MaxInterestRate     float64            `json:"max_interest_rate" db:"max_interest_rate"`
+	InterestMethod      InterestMethod     `json:"interest_method" db:"interest_method"`
+	RepaymentFrequency  RepaymentFrequency `json:"repayment_frequency" db:"repayment_frequency"`
+	AdminFee            int64              `json:"admin_fee" db:"admin_fee"`
+	ProvisionFeeRate    float64            `json:"provision_fee_rate" db:"provision_fee_rate"`
+	MaxActiveLoans      int                `json:"max_active_loans" db:"max_active_loans"`
+	MinMembershipMonths int                `json:"min_membership_months" db:"min_membership_months"`
+	MinMonthlyIncome    int64              `json:"min_monthly_income" db:"min_monthly_income"`
+	RequireKTPVerified  bool               `json:"require_ktp_verified" db:"require_ktp_verified"`
+	Active              bool               `json:"active" db:"active"`
+	CreatedAt           time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time          `json:"updated_at" db:"updated_at"`
+}
+
 type LoanInstallment struct {
-	ID         uuid.UUID               `json:"id" db:"id"`
-	LoanID     uuid.UUID               `json:"loan_id" db:"loan_id"`
-	Number     int                     `json:"number" db:"number"`
-	DueDate    time.Time               `json:"due_date" db:"due_date"`
-	AmountDue  int64                   `json:"amount_due" db:"amount_due"`
-	AmountPaid int64                   `json:"amount_paid" db:"amount_paid"`
-	Status     LoanInstallmentStatus   `json:"status" db:"status"`
-	PaidAt     *time.Time              `json:"paid_at" db:"paid_at"`
-	DPD        int                     `json:"dpd" db:"dpd"`
-	CreatedAt  time.Time               `json:"created_at" db:"created_at"`
-	UpdatedAt  time.Time               `json:"updated_at" db:"updated_at"`
-	Loan       *Loan                   `json:"loan,omitempty"`
-	Notifications []NotificationLog    `json:"notifications,omitempty"`
+	ID                 uuid.UUID             `json:"id" db:"id"`
+	LoanID             uuid.UUID             `json:"loan_id" db:"loan_id"`
+	Number             int                   `json:"number" db:"number"`
+	DueDate            time.Time             `json:"due_date" db:"due_date"`
+	PrincipalDue       int64                 `json:"principal_due" db:"principal_due"`
+	InterestDue        int64                 `json:"interest_due" db:"interest_due"`
+	ArrearsInterest    int64                 `json:"arrears_interest" db:"arrears_interest"` // part of interest_due carried over by a restructuring
+	AmountDue          int64                 `json:"amount_due" db:"amount_due"`
+	AmountPaid         int64                 `json:"amount_paid" db:"amount_paid"`
+	OutstandingBalance int64                 `json:"outstanding_balance" db:"outstanding_balance"`
+	PenaltyDue         int64                 `json:"penalty_due" db:"penalty_due"`
+	PenaltyPaid        int64                 `json:"penalty_paid" db:"penalty_paid"`
+	Status             LoanInstallmentStatus `json:"status" db:"status"`
+	PaidAt             *time.Time            `json:"paid_at" db:"paid_at"`
+	SettledAt          *time.Time            `json:"settled_at" db:"settled_at"` // closed by early settlement
+	ScheduleVersion    int                   `json:"schedule_version" db:"schedule_version"`
+	SupersededAt       *time.Time            `json:"superseded_at" db:"superseded_at"` // replaced by a restructured schedule
+	DPD                int                   `json:"dpd" db:"dpd"`
+	CreatedAt          time.Time             `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time             `json:"updated_at" db:"updated_at"`
+	Loan               *Loan                 `json:"loan,omitempty"`
+	Notifications      []NotificationLog     `json:"notifications,omitempty"`
+}
+
+// LoanApproval is one checker's decision on a loan submitted for approval
+type LoanApproval struct {
+	ID           uuid.UUID        `json:"id" db:"id"`
+	LoanID       uuid.UUID        `json:"loan_id" db:"loan_id"`
+	Level        int              `json:"level" db:"level"`
+	ApproverID   uuid.UUID        `json:"approver_id" db:"approver_id"`
+	ApproverName string           `json:"approver_name"`
+	Decision     ApprovalDecision `json:"decision" db:"decision"`
+	Notes        string           `json:"notes" db:"notes"`
+	CreatedAt    time.Time        `json:"created_at" db:"created_at"`
+}
+
+type ApprovalDecision string
+
+const (
+	ApprovalDecisionApproved ApprovalDecision = "approved"
+	ApprovalDecisionRejected ApprovalDecision = "rejected"
+)
+
+// LoanStatusChange is one entry of a loan's status history
+type LoanStatusChange struct {
+	ID            uuid.UUID   `json:"id" db:"id"`
+	LoanID        uuid.UUID   `json:"loan_id" db:"loan_id"`
+	FromStatus    *LoanStatus `json:"from_status" db:"from_status"`
+	ToStatus      LoanStatus  `json:"to_status" db:"to_status"`
+	ChangedBy     *uuid.UUID  `json:"changed_by" db:"changed_by"`
+	ChangedByName string      `json:"changed_by_name"`
+	Reason        string      `json:"reason" db:"reason"`
+	CreatedAt     time.Time   `json:"created_at" db:"created_at"`
+}
+
+type LoanRestructuring struct {
+	ID                   uuid.UUID         `json:"id" db:"id"`
+	LoanID               uuid.UUID         `json:"loan_id" db:"loan_id"`
+	PreviousVersion      int               `json:"previous_version" db:"previous_version"`
+	ScheduleVersion      int               `json:"schedule_version" db:"schedule_version"`
+	OutstandingPrincipal int64             `json:"outstanding_principal" db:"outstanding_principal"`
+	CapitalizedAmount    int64             `json:"capitalized_amount" db:"capitalized_amount"`
+	CapitalizedPenalty   int64             `json:"capitalized_penalty" db:"capitalized_penalty"` // part of capitalized_amount that was penalties
+	PreviousInterestRate float64           `json:"previous_interest_rate" db:"previous_interest_rate"`
+	InterestRate         float64           `json:"interest_rate" db:"interest_rate"`
+	PreviousTerm         int               `json:"previous_term" db:"previous_term"`
+	Term                 int               `json:"term" db:"term"`
+	StartDate            time.Time         `json:"start_date" db:"start_date"`
+	Reason               string            `json:"reason" db:"reason"`
+	ApprovedBy           uuid.UUID         `json:"approved_by" db:"approved_by"`
+	CreatedAt            time.Time         `json:"created_at" db:"created_at"`
+	ApprovedByUser       *User             `json:"approved_by_user,omitempty"`
+	SupersededSchedule   []LoanInstallment `json:"superseded_schedule,omitempty"`
+}
+
+// LoanWriteOff is what a loan still owed when it was written off (hapus
+// buku) and what has been recovered on it since
+type LoanWriteOff struct {
+	WrittenOffAt time.Time  `json:"written_off_at"`
+	WrittenOffBy *uuid.UUID `json:"written_off_by"`
+	Reason       string     `json:"reason"`
+	Principal    int64      `json:"principal"`
+	Interest     int64      `json:"interest"` // accrued up to the write-off
+	Penalty      int64      `json:"penalty"`
+	Total        int64      `json:"total"`
+	Recovered    int64      `json:"recovered"`
+}
+
+// LoanRecovery is an amount collected on a written-off loan. Recoveries are
+// kept out of the payment ledger and never touch the installments.
+type LoanRecovery struct {
+	ID            uuid.UUID     `json:"id" db:"id"`
+	LoanID        uuid.UUID     `json:"loan_id" db:"loan_id"`
+	ReceiptNumber string        `json:"receipt_number" db:"receipt_number"`
+	Amount        int64         `json:"amount" db:"amount"`
+	Method        PaymentMethod `json:"method" db:"method"`
+	Reference     string        `json:"reference" db:"reference"`
+	RecoveryDate  time.Time     `json:"recovery_date" db:"recovery_date"`
+	PostedBy      *uuid.UUID    `json:"posted_by" db:"posted_by"`
+	Notes         string        `json:"notes" db:"notes"`
+	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
+}
+
+// Collectibility is the OJK loan quality class (kolektibilitas), from 1
+// (Lancar) to 5 (Macet)
+type Collectibility int
+
+const (
+	CollectibilityLancar       Collectibility = 1
+	CollectibilityDPK          Collectibility = 2 // Dalam Perhatian Khusus
+	CollectibilityKurangLancar Collectibility = 3
+	CollectibilityDiragukan    Collectibility = 4
+	CollectibilityMacet        Collectibility = 5
+)
+
+func (c Collectibility) Name() string {
+	switch c {
+	case CollectibilityLancar:
+		return "Lancar"
+	case CollectibilityDPK:
+		return "Dalam Perhatian Khusus"
+	case CollectibilityKurangLancar:
+		return "Kurang Lancar"
+	case CollectibilityDiragukan:
+		return "Diragukan"
+	case CollectibilityMacet:
+		return "Macet"
+	}
+	return ""
+}
+
+// LoanCollectibility is the classification of a loan on one day
+type LoanCollectibility struct {
+	ID                   uuid.UUID      `json:"id" db:"id"`
+	LoanID               uuid.UUID      `json:"loan_id" db:"loan_id"`
+	AsOf                 time.Time      `json:"as_of" db:"as_of"`
+	DPD                  int            `json:"dpd" db:"dpd"`
+	Collectibility       Collectibility `json:"collectibility" db:"collectibility"`
+	OutstandingPrincipal int64          `json:"outstanding_principal" db:"outstanding_principal"`
+	ProvisionRate        float64        `json:"provision_rate" db:"provision_rate"` // percent of the outstanding principal
+	Provision            int64          `json:"provision" db:"provision"`
+	CreatedAt            time.Time      `json:"created_at" db:"created_at"`
+}
+
+// CollectibilityClassSummary totals the loans of one class in a
+// collectibility report
+type CollectibilityClassSummary struct {
+	Collectibility       Collectibility `json:"collectibility"`
+	Name                 string         `json:"name"`
+	Loans                int            `json:"loans"`
+	OutstandingPrincipal int64          `json:"outstanding_principal"`
+	ProvisionRate        float64        `json:"provision_rate"`
+	Provision            int64          `json:"provision"` // required allowance (PPAP)
+}
+
+// CollectibilityReport is the portfolio by collectibility class as of a date.
+// ClassifiedAsOf is the classification run the figures come from: the latest
+// one on or before AsOf, nil if there is none.
+type CollectibilityReport struct {
+	AsOf             time.Time                    `json:"as_of"`
+	ClassifiedAsOf   *time.Time                   `json:"classified_as_of"`
+	Classes          []CollectibilityClassSummary `json:"classes"`
+	TotalLoans       int                          `json:"total_loans"`
+	TotalOutstanding int64                        `json:"total_outstanding"`
+	TotalProvision   int64                        `json:"total_provision"`
+}
+
+type Payment struct {
+	ID                  uuid.UUID        `json:"id" db:"id"`
+	LoanID              uuid.UUID        `json:"loan_id" db:"loan_id"`
+	InstallmentID       uuid.UUID        `json:"installment_id" db:"installment_id"`
+	ReceiptNumber       string           `json:"receipt_number" db:"receipt_number"`
+	Amount              int64            `json:"amount" db:"amount"`
+	PenaltyAmount       int64            `json:"penalty_amount" db:"penalty_amount"`
+	InterestAmount      int64            `json:"interest_amount" db:"interest_amount"`
+	PrincipalAmount     int64            `json:"principal_amount" db:"principal_amount"`
+	FeeAmount           int64            `json:"fee_amount" db:"fee_amount"`
+	CreditAmount        int64            `json:"credit_amount" db:"credit_amount"`               // added to (+) or drawn from (-) the loan credit
+	CapitalizedInterest int64            `json:"capitalized_interest" db:"capitalized_interest"` // capitalized arrears earned by the principal paid
+	CapitalizedPenalty  int64            `json:"capitalized_penalty" db:"capitalized_penalty"`
+	Method              PaymentMethod    `json:"method" db:"method"`
+	Reference           string           `json:"reference" db:"reference"`
+	PaymentDate         time.Time        `json:"payment_date" db:"payment_date"`
+	PostedBy            *uuid.UUID       `json:"posted_by" db:"posted_by"`
+	IdempotencyKey      *string          `json:"idempotency_key" db:"idempotency_key"`
+	BatchID             *uuid.UUID       `json:"batch_id" db:"batch_id"`
+	ReversalOf          *uuid.UUID       `json:"reversal_of" db:"reversal_of"`
+	ReversedBy          *uuid.UUID       `json:"reversed_by"` // the reversal entry, if this payment was reversed
+	Notes               string           `json:"notes" db:"notes"`
+	CreatedAt           time.Time        `json:"created_at" db:"created_at"`
+	Installment         *LoanInstallment `json:"installment,omitempty"`
 }
 
+type PaymentMethod string
+
+const (
+	PaymentMethodCash     PaymentMethod = "cash"
+	PaymentMethodTransfer PaymentMethod = "transfer"
+	PaymentMethodQRIS     PaymentMethod = "qris"
+)
+
 type LoanInstallmentStatus string
 
 const (
@@ -160,6 +465,168 @@ const (
 	InstallmentStatusPartial   LoanInstallmentStatus = "partial"
 )
 
+// SavingsType is the kind of member savings (simpanan) an account holds
+type SavingsType string
+
+const (
+	SavingsTypePokok    SavingsType = "pokok"    // paid once on joining
+	SavingsTypeWajib    SavingsType = "wajib"    // owed every month
+	SavingsTypeSukarela SavingsType = "sukarela" // voluntary
+)
+
+type SavingsAccountStatus string
+
+const (
+	SavingsAccountStatusActive SavingsAccountStatus = "active"
+	SavingsAccountStatusClosed SavingsAccountStatus = "closed"
+)
+
+type SavingsAccount struct {
+	ID                uuid.UUID            `json:"id" db:"id"`
+	CustomerID        uuid.UUID            `json:"customer_id" db:"customer_id"`
+	AccountNumber     string               `json:"account_number" db:"account_number"`
+	Type              SavingsType          `json:"type" db:"type"`
+	Status            SavingsAccountStatus `json:"status" db:"status"`
+	Balance           int64                `json:"balance" db:"balance"`
+	MonthlyObligation int64                `json:"monthly_obligation" db:"monthly_obligation"` // simpanan wajib owed per month
+	OpenedBy          *uuid.UUID           `json:"opened_by" db:"opened_by"`
+	OpenedAt          time.Time            `json:"opened_at" db:"opened_at"`
+	ClosedAt          *time.Time           `json:"closed_at" db:"closed_at"`
+	UpdatedAt         time.Time            `json:"updated_at" db:"updated_at"`
+	AccruedInterest   int64                `json:"accrued_interest"` // sukarela interest accrued since it was last credited, rounded down
+	Wajib             *WajibObligation     `json:"wajib,omitempty"`
+}
+
+// WajibObligation compares the simpanan wajib owed since an account was
+// opened with what has been deposited
+type WajibObligation struct {
+	MonthlyAmount int64 `json:"monthly_amount"`
+	MonthsDue     int   `json:"months_due"` // from the month of opening up to this month
+	AmountDue     int64 `json:"amount_due"`
+	Deposited     int64 `json:"deposited"`
+	MonthsPaid    int   `json:"months_paid"` // whole months covered by the deposits
+	Arrears       int64 `json:"arrears"`
+}
+
+type SavingsTransactionType string
+
+const (
+	SavingsTransactionDeposit    SavingsTransactionType = "deposit"
+	SavingsTransactionWithdrawal SavingsTransactionType = "withdrawal"
+	SavingsTransactionInterest   SavingsTransactionType = "interest" // monthly interest credited on sukarela
+	SavingsTransactionTax        SavingsTransactionType = "tax"      // tax withheld from the interest
+	SavingsTransactionSHU        SavingsTransactionType = "shu"      // the member's share of the year's surplus
+)
+
+type SavingsTransaction struct {
+	ID              uuid.UUID              `json:"id" db:"id"`
+	AccountID       uuid.UUID              `json:"account_id" db:"account_id"`
+	ReceiptNumber   string                 `json:"receipt_number" db:"receipt_number"`
+	Type            SavingsTransactionType `json:"type" db:"type"`
+	Amount          int64                  `json:"amount" db:"amount"`
+	BalanceAfter    int64                  `json:"balance_after" db:"balance_after"`
+	Method          PaymentMethod          `json:"method" db:"method"`
+	Reference       string                 `json:"reference" db:"reference"`
+	TransactionDate time.Time              `json:"transaction_date" db:"transaction_date"`
+	PostedBy        *uuid.UUID             `json:"posted_by" db:"posted_by"`
+	Notes           string                 `json:"notes" db:"notes"`
+}
+
+type GLAccountType string
+
+const (
+	GLAccountAsset     GLAccountType = "asset"
+	GLAccountLiability GLAccountType = "liability"
+	GLAccountEquity    GLAccountType = "equity"
+	GLAccountIncome    GLAccountType = "income"
+	GLAccountExpense   GLAccountType = "expense"
+)
+
+// GLAccount is an account of the chart of accounts
+type GLAccount struct {
+	Code      string        `json:"code" db:"code"`
+	Name      string        `json:"name" db:"name"`
+	Type      GLAccountType `json:"type" db:"type"`
+	System    bool          `json:"system" db:"system"` // booked to by the posting rules
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
+
+type JournalSource string
+
+const (
+	JournalSourceManual        JournalSource = "manual"
+	JournalSourceDisbursement  JournalSource = "disbursement"
+	JournalSourcePayment       JournalSource = "payment"
+	JournalSourceSavings       JournalSource = "savings"
+	JournalSourceRestructuring JournalSource = "restructuring"
+	JournalSourceWriteOff      JournalSource = "write_off"
+	JournalSourceRecovery      JournalSource = "recovery"
+	JournalSourceSHU           JournalSource = "shu"
+	JournalSourceClosing       JournalSource = "closing" // year-end closing into SHU Belum Dibagi
+)
+
+type JournalEntry struct {
+	ID          uuid.UUID     `json:"id" db:"id"`
+	EntryNumber string        `json:"entry_number" db:"entry_number"`
+	EntryDate   time.Time     `json:"entry_date" db:"entry_date"`
+	Description string        `json:"description" db:"description"`
+	Source      JournalSource `json:"source" db:"source"`
+	SourceID    *uuid.UUID    `json:"source_id" db:"source_id"` // what an automatic entry was posted for
+	PostedBy    *uuid.UUID    `json:"posted_by" db:"posted_by"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+	Lines       []JournalLine `json:"lines"`
+}
+
+// JournalLine is either a debit or a credit to one account
+type JournalLine struct {
+	AccountCode string `json:"account_code" db:"account_code"`
+	AccountName string `json:"account_name,omitempty"`
+	Debit       int64  `json:"debit" db:"debit"`
+	Credit      int64  `json:"credit" db:"credit"`
+	Memo        string `json:"memo" db:"memo"`
+}
+
+// SHUDistribution divides a year's surplus (SHU) between the funds and the
+// members. A calculated distribution has no ID until it is posted.
+type SHUDistribution struct {
+	ID               *uuid.UUID            `json:"id" db:"id"`
+	Year             int                   `json:"year" db:"year"`
+	NetSurplus       int64                 `json:"net_surplus" db:"net_surplus"`
+	JasaModalPercent float64               `json:"jasa_modal_percent" db:"jasa_modal_percent"`
+	JasaUsahaPercent float64               `json:"jasa_usaha_percent" db:"jasa_usaha_percent"`
+	JasaModal        int64                 `json:"jasa_modal" db:"jasa_modal"` // shared by simpanan pokok and wajib
+	JasaUsaha        int64                 `json:"jasa_usaha" db:"jasa_usaha"` // shared by loan interest paid
+	TotalSavings     int64                 `json:"total_savings" db:"total_savings"`
+	TotalInterest    int64                 `json:"total_interest" db:"total_interest"`
+	Funds            []SHUFundAllocation   `json:"funds"`
+	Members          []SHUMemberAllocation `json:"members"`
+	Distributed      int64                 `json:"distributed"`   // to the members
+	Undistributed    int64                 `json:"undistributed"` // rounding left in SHU Belum Dibagi
+	PostedBy         *uuid.UUID            `json:"posted_by" db:"posted_by"`
+	PostedAt         *time.Time            `json:"posted_at" db:"posted_at"`
+}
+
+type SHUFundAllocation struct {
+	Name        string  `json:"name" db:"name"`
+	Percent     float64 `json:"percent" db:"percent"`
+	AccountCode string  `json:"account_code" db:"account_code"`
+	Amount      int64   `json:"amount" db:"amount"`
+}
+
+// SHUMemberAllocation is a member's share of the SHU: jasa modal for their
+// simpanan pokok and wajib at the end of the year and jasa usaha for the
+// loan interest they paid in it
+type SHUMemberAllocation struct {
+	CustomerID    uuid.UUID  `json:"customer_id" db:"customer_id"`
+	CustomerName  string     `json:"customer_name"`
+	Savings       int64      `json:"savings" db:"savings"`
+	InterestPaid  int64      `json:"interest_paid" db:"interest_paid"`
+	JasaModal     int64      `json:"jasa_modal" db:"jasa_modal"`
+	JasaUsaha     int64      `json:"jasa_usaha" db:"jasa_usaha"`
+	Total         int64      `json:"total"`
+	TransactionID *uuid.UUID `json:"transaction_id" db:"transaction_id"` // the simpanan sukarela line it was credited with
+}
+
 type NotificationTemplate struct {
 	ID           uuid.UUID             `json:"id" db:"id"`
 	Name         string                `json:"name" db:"name"`
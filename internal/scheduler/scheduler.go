@@ -19,9 +19,13 @@ type Scheduler struct {
 	cfg               *config.Config
 	notificationSvc   *services.NotificationService
 	loanSvc           *services.LoanService
+	auditSvc          *services.AuditService
+	collectibilitySvc *services.CollectibilityService
+	savingsSvc        *services.SavingsService
+	encryptor         *utils.Encryptor
 }
 
-func NewScheduler(db *database.DB, cfg *config.Config, notificationSvc *services.NotificationService, loanSvc *services.LoanService) *Scheduler {
+func NewScheduler(db *database.DB, cfg *config.Config, encryptor *utils.Encryptor, notificationSvc *services.NotificationService, loanSvc *services.LoanService, auditSvc *services.AuditService, collectibilitySvc *services.CollectibilityService, savingsSvc *services.SavingsService) *Scheduler {
 	// Create cron with Asia/Jakarta timezone
 	jakartaLocation, err := time.LoadLocation("Asia/Jakarta")
 	if err != nil {
@@ -32,11 +36,15 @@ func NewScheduler(db *database.DB, cfg *config.Config, notificationSvc *services
 	c := cron.New(cron.WithLocation(jakartaLocation))
 
 	return &Scheduler{
-		cron:            c,
-		db:              db,
-		cfg:             cfg,
-		notificationSvc: notificationSvc,
-		loanSvc:         loanSvc,
+		cron:              c,
+		db:                db,
+		cfg:               cfg,
+		notificationSvc:   notificationSvc,
+		loanSvc:           loanSvc,
+		auditSvc:          auditSvc,
+		collectibilitySvc: collectibilitySvc,
+		savingsSvc:        savingsSvc,
+		encryptor:         encryptor,
 	}
 }
 
@@ -59,6 +67,33 @@ func (s *Scheduler) Start() error {
 		return fmt.Errorf("failed to schedule DPD updates: %v", err)
 	}
 
+	// Schedule loan status updates every hour, after the DPD update
+	_, err = s.cron.AddFunc("5 * * * *", s.updateLoanStatuses)
+	if err != nil {
+		return fmt.Errorf("failed to schedule loan status updates: %v", err)
+	}
+
+	// Schedule collectibility classification every hour, after the status
+	// updates; each run replaces the day's classification
+	_, err = s.cron.AddFunc("10 * * * *", s.classifyCollectibility)
+	if err != nil {
+		return fmt.Errorf("failed to schedule collectibility classification: %v", err)
+	}
+
+	// Schedule savings interest accrual at 00:15 WIB daily for the day that
+	// has just ended
+	_, err = s.cron.AddFunc("15 0 * * *", s.accrueSavingsInterest)
+	if err != nil {
+		return fmt.Errorf("failed to schedule savings interest accrual: %v", err)
+	}
+
+	// Schedule savings interest crediting on the first of every month, after
+	// the last day of the previous month has been accrued
+	_, err = s.cron.AddFunc("30 0 1 * *", s.creditSavingsInterest)
+	if err != nil {
+		return fmt.Errorf("failed to schedule savings interest crediting: %v", err)
+	}
+
 	s.cron.Start()
 	log.Println("Scheduler started with Asia/Jakarta timezone")
 	return nil
@@ -128,6 +163,7 @@ func (s *Scheduler) processTemplateReminders(template models.NotificationTemplat
 		JOIN customers c ON l.customer_id = c.id
 		WHERE date(li.due_date) %s date(?)
 		AND li.status != 'paid'
+		AND li.superseded_at IS NULL
 		AND l.status = 'active'`, comparison)
 
 	rows, err := s.db.Query(query, targetDate.Format("2006-01-02"))
@@ -151,8 +187,8 @@ func (s *Scheduler) processTemplateReminders(template models.NotificationTemplat
 		}
 
 		// Decrypt customer contact info
-		email, _ := utils.Decrypt(encryptedEmail)
-		phone, _ := utils.Decrypt(encryptedPhone)
+		email, _ := s.encryptor.Decrypt(encryptedEmail)
+		phone, _ := s.encryptor.Decrypt(encryptedPhone)
 
 		// Check if notification already sent today for this installment and template
 		if s.isNotificationSentToday(installmentID, template.ID.String()) {
@@ -205,7 +241,8 @@ func (s *Scheduler) processPendingNotifications() {
 func (s *Scheduler) updateDaysPastDue() {
 	log.Println("Updating days past due...")
 
-	// Update DPD for overdue installments
+	// Update DPD for overdue installments of running loans; completed,
+	// cancelled and written off loans keep the DPD they closed with
 	_, err := s.db.Exec(`
 		UPDATE loan_installments 
 		SET dpd = CAST((julianday('now') - julianday(due_date)) AS INTEGER),
@@ -213,13 +250,92 @@ func (s *Scheduler) updateDaysPastDue() {
 		        WHEN status = 'pending' AND due_date < date('now') THEN 'overdue'
 		        ELSE status
 		    END
-		WHERE due_date < date('now') AND status != 'paid'`)
+		WHERE due_date < date('now') AND status != 'paid' AND superseded_at IS NULL
+		AND loan_id IN (SELECT id FROM loans WHERE status IN ('disbursed', 'active', 'defaulted'))`)
 	if err != nil {
 		log.Printf("Failed to update DPD: %v", err)
 		return
 	}
 
-	log.Println("DPD update completed")
+	// Charge late payment penalties for the days since the last run
+	accrued, err := s.loanSvc.AccruePenalties(s.cfg.Penalty, time.Now().In(s.cron.Location()))
+	if err != nil {
+		log.Printf("Failed to accrue penalties: %v", err)
+		return
+	}
+
+	log.Printf("DPD update completed, penalties accrued on %d installments", accrued)
+}
+
+// updateLoanStatuses activates loans whose repayment has started and defaults
+// loans past the configured DPD threshold. Each change is written to the
+// audit log as a system action.
+func (s *Scheduler) updateLoanStatuses() {
+	log.Println("Updating loan statuses...")
+
+	now := time.Now().In(s.cron.Location())
+	activated, err := s.loanSvc.ActivateDisbursedLoans(now)
+	if err != nil {
+		log.Printf("Failed to activate disbursed loans: %v", err)
+		return
+	}
+	s.logStatusUpdates(activated)
+
+	defaulted, err := s.loanSvc.DefaultDelinquentLoans(s.cfg.Delinquency.DefaultDPD, now)
+	if err != nil {
+		log.Printf("Failed to default delinquent loans: %v", err)
+		return
+	}
+	s.logStatusUpdates(defaulted)
+
+	log.Printf("Loan status update completed: %d activated, %d defaulted", len(activated), len(defaulted))
+}
+
+// classifyCollectibility stores today's OJK collectibility of running loans
+func (s *Scheduler) classifyCollectibility() {
+	log.Println("Classifying loan collectibility...")
+
+	classified, err := s.collectibilitySvc.ClassifyLoans(time.Now().In(s.cron.Location()))
+	if err != nil {
+		log.Printf("Failed to classify loan collectibility: %v", err)
+		return
+	}
+
+	log.Printf("Collectibility classification completed for %d loans", classified)
+}
+
+func (s *Scheduler) accrueSavingsInterest() {
+	log.Println("Accruing savings interest...")
+
+	yesterday := time.Now().In(s.cron.Location()).AddDate(0, 0, -1)
+	accrued, err := s.savingsSvc.AccrueInterest(yesterday)
+	if err != nil {
+		log.Printf("Failed to accrue savings interest: %v", err)
+		return
+	}
+
+	log.Printf("Savings interest accrued for %d account days", accrued)
+}
+
+func (s *Scheduler) creditSavingsInterest() {
+	log.Println("Crediting savings interest...")
+
+	credited, err := s.savingsSvc.CreditInterest(time.Now().In(s.cron.Location()))
+	if err != nil {
+		log.Printf("Failed to credit savings interest: %v", err)
+		return
+	}
+
+	log.Printf("Savings interest crediting completed with %d interest lines posted", credited)
+}
+
+func (s *Scheduler) logStatusUpdates(updates []services.LoanStatusUpdate) {
+	for _, update := range updates {
+		err := s.auditSvc.LogLoanStatusChange(nil, update.LoanID, update.From, update.To, update.Reason)
+		if err != nil {
+			log.Printf("Failed to write audit log for loan %s: %v", update.ContractNumber, err)
+		}
+	}
 }
 
 func (s *Scheduler) getActiveTemplates() ([]models.NotificationTemplate, error) {
@@ -283,6 +399,26 @@ func (s *Scheduler) TriggerDPDUpdate() error {
 	return nil
 }
 
+func (s *Scheduler) TriggerLoanStatusUpdate() error {
+	s.updateLoanStatuses()
+	return nil
+}
+
+func (s *Scheduler) TriggerCollectibilityClassification() error {
+	s.classifyCollectibility()
+	return nil
+}
+
+func (s *Scheduler) TriggerSavingsInterestAccrual() error {
+	s.accrueSavingsInterest()
+	return nil
+}
+
+func (s *Scheduler) TriggerSavingsInterestCrediting() error {
+	s.creditSavingsInterest()
+	return nil
+}
+
 // Get scheduler status
 func (s *Scheduler) GetStatus() map[string]interface{} {
 	entries := s.cron.Entries()
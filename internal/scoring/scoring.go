@@ -0,0 +1,220 @@
+// Package scoring rates loan applicants with a rule-based credit score. Each
+// factor earns a fraction of its configured weight and the weighted total is
+// scaled to 0-100.
+package scoring
+
+import (
+	"math"
+	"time"
+
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/utils"
+)
+
+// Reason codes explain which factors lowered a score
+const (
+	ReasonNoCreditHistory     = "NO_CREDIT_HISTORY"
+	ReasonLatePayments        = "LATE_PAYMENTS"
+	ReasonSeriousDelinquency  = "SERIOUS_DELINQUENCY"
+	ReasonKTPNotVerified      = "KTP_NOT_VERIFIED"
+	ReasonDocumentsIncomplete = "DOCUMENTS_INCOMPLETE"
+	ReasonAgeUnknown          = "AGE_UNKNOWN"
+	ReasonAgeOutsideRange     = "AGE_OUTSIDE_RANGE"
+	ReasonNoIncome            = "NO_INCOME"
+	ReasonLowIncome           = "LOW_INCOME"
+	ReasonShortMembership     = "SHORT_MEMBERSHIP"
+)
+
+// RequiredDocuments are the documents a complete application has on file
+var RequiredDocuments = []models.DocumentType{models.DocumentTypeKTP, models.DocumentTypeKK}
+
+// Applicant holds what the score is computed from
+type Applicant struct {
+	Installments  int // installments due so far on the member's loans; 0 means no credit history
+	LatePayments  int // of those, paid or still unpaid after the due date
+	MaxDPD        int // worst days past due among them
+	KTPVerified   bool
+	Documents     []models.DocumentType // documents on file that were not rejected
+	DateOfBirth   time.Time
+	NIK           string // used for the age when no date of birth is recorded
+	MonthlyIncome int64
+	MemberSince   time.Time
+}
+
+// Score computes the credit score of an applicant as of asOf
+func Score(applicant Applicant, weights config.ScoringConfig, asOf time.Time) models.CreditScore {
+	factors := []struct {
+		weight float64
+		rate   func(Applicant, time.Time) (float64, []string)
+	}{
+		{weights.PaymentHistory, ratePaymentHistory},
+		{weights.KTPVerified, rateKTP},
+		{weights.Documents, rateDocuments},
+		{weights.Age, rateAge},
+		{weights.Income, rateIncome},
+		{weights.Membership, rateMembership},
+	}
+
+	var earned, total float64
+	reasons := make([]string, 0)
+	for _, factor := range factors {
+		fraction, codes := factor.rate(applicant, asOf)
+		earned += factor.weight * fraction
+		total += factor.weight
+		if factor.weight > 0 {
+			reasons = append(reasons, codes...)
+		}
+	}
+
+	score := 0
+	if total > 0 {
+		score = int(math.Round(earned / total * 100))
+	}
+
+	return models.CreditScore{
+		Score:    score,
+		Grade:    Grade(score),
+		Reasons:  reasons,
+		ScoredAt: asOf,
+	}
+}
+
+// Grade maps a 0-100 score to a letter grade, A being the best
+func Grade(score int) string {
+	switch {
+	case score >= 80:
+		return "A"
+	case score >= 65:
+		return "B"
+	case score >= 50:
+		return "C"
+	case score >= 35:
+		return "D"
+	default:
+		return "E"
+	}
+}
+
+// Age returns the age in whole years on asOf, from the date of birth or, when
+// none is recorded, from the birth date encoded in the NIK
+func Age(dateOfBirth time.Time, nik string, asOf time.Time) (int, bool) {
+	if dateOfBirth.IsZero() || dateOfBirth.Year() < 1900 {
+		if nik == "" {
+			return 0, false
+		}
+		day, month, year, _, err := utils.ExtractDateFromNIK(nik)
+		if err != nil || month < 1 || month > 12 || day < 1 || day > 31 {
+			return 0, false
+		}
+		dateOfBirth = time.Date(year, time.Month(month), day, 0, 0, 0, 0, asOf.Location())
+	}
+
+	age := asOf.Year() - dateOfBirth.Year()
+	if asOf.Month() < dateOfBirth.Month() || asOf.Month() == dateOfBirth.Month() && asOf.Day() < dateOfBirth.Day() {
+		age--
+	}
+	if age < 0 {
+		return 0, false
+	}
+	return age, true
+}
+
+func ratePaymentHistory(a Applicant, asOf time.Time) (float64, []string) {
+	if a.Installments == 0 {
+		return 0.5, []string{ReasonNoCreditHistory}
+	}
+
+	var fraction float64
+	reasons := make([]string, 0)
+	switch {
+	case a.MaxDPD <= 0:
+		fraction = 1
+	case a.MaxDPD <= 30:
+		fraction = 0.7
+	case a.MaxDPD <= 60:
+		fraction = 0.4
+	case a.MaxDPD <= 90:
+		fraction = 0.2
+	default:
+		fraction = 0
+		reasons = append(reasons, ReasonSeriousDelinquency)
+	}
+
+	// Repeated lateness costs more than a single slip
+	if a.LatePayments > 0 {
+		fraction = math.Max(0, fraction-0.05*float64(a.LatePayments-1))
+		reasons = append(reasons, ReasonLatePayments)
+	}
+	return fraction, reasons
+}
+
+func rateKTP(a Applicant, asOf time.Time) (float64, []string) {
+	if !a.KTPVerified {
+		return 0, []string{ReasonKTPNotVerified}
+	}
+	return 1, nil
+}
+
+func rateDocuments(a Applicant, asOf time.Time) (float64, []string) {
+	present := 0
+	for _, required := range RequiredDocuments {
+		for _, document := range a.Documents {
+			if document == required {
+				present++
+				break
+			}
+		}
+	}
+	if present < len(RequiredDocuments) {
+		return float64(present) / float64(len(RequiredDocuments)), []string{ReasonDocumentsIncomplete}
+	}
+	return 1, nil
+}
+
+func rateAge(a Applicant, asOf time.Time) (float64, []string) {
+	age, ok := Age(a.DateOfBirth, a.NIK, asOf)
+	switch {
+	case !ok:
+		return 0, []string{ReasonAgeUnknown}
+	case age >= 21 && age <= 55:
+		return 1, nil
+	case age >= 18 && age <= 65:
+		return 0.5, []string{ReasonAgeOutsideRange}
+	default:
+		return 0, []string{ReasonAgeOutsideRange}
+	}
+}
+
+func rateIncome(a Applicant, asOf time.Time) (float64, []string) {
+	switch {
+	case a.MonthlyIncome <= 0:
+		return 0, []string{ReasonNoIncome}
+	case a.MonthlyIncome >= 10000000:
+		return 1, nil
+	case a.MonthlyIncome >= 5000000:
+		return 0.75, nil
+	case a.MonthlyIncome >= 3000000:
+		return 0.5, nil
+	default:
+		return 0.25, []string{ReasonLowIncome}
+	}
+}
+
+func rateMembership(a Applicant, asOf time.Time) (float64, []string) {
+	months := (asOf.Year()-a.MemberSince.Year())*12 + int(asOf.Month()) - int(a.MemberSince.Month())
+	if asOf.Day() < a.MemberSince.Day() {
+		months--
+	}
+
+	switch {
+	case months >= 24:
+		return 1, nil
+	case months >= 12:
+		return 0.75, nil
+	case months >= 6:
+		return 0.5, []string{ReasonShortMembership}
+	default:
+		return 0.25, []string{ReasonShortMembership}
+	}
+}
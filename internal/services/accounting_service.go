@@ -0,0 +1,332 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+)
+
+// AccountingService reads the general ledger and takes manual journal
+// entries. Loan and savings services post their entries through
+// postJournalEntry in their own transactions.
+type AccountingService struct {
+	db *database.DB
+}
+
+func NewAccountingService(db *database.DB) *AccountingService {
+	return &AccountingService{db: db}
+}
+
+type GLAccountCreateRequest struct {
+	Code string `json:"code"` // 4 digits, the first giving the type
+	Name string `json:"name"`
+}
+
+type JournalEntryRequest struct {
+	EntryDate   time.Time            `json:"entry_date"` // defaults to now
+	Description string               `json:"description"`
+	Lines       []models.JournalLine `json:"lines"`
+	PostedBy    string               `json:"posted_by"`
+}
+
+type JournalEntryListRequest struct {
+	PeriodStart time.Time            `json:"period_start"` // defaults to the start of the current month
+	PeriodEnd   time.Time            `json:"period_end"`   // defaults to today
+	Source      models.JournalSource `json:"source"`       // optional
+	AccountCode string               `json:"account_code"` // optional: entries with a line on this account
+}
+
+const journalEntryColumns = `
+	id, entry_number, entry_date, description, source, source_id, posted_by, created_at`
+
+func (s *AccountingService) ListGLAccounts() ([]models.GLAccount, error) {
+	rows, err := s.db.Query("SELECT code, name, type, system, created_at FROM gl_accounts ORDER BY code")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %v", err)
+	}
+	defer rows.Close()
+
+	accounts := make([]models.GLAccount, 0)
+	for rows.Next() {
+		var account models.GLAccount
+		err := rows.Scan(&account.Code, &account.Name, &account.Type, &account.System, &account.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan account: %v", err)
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, rows.Err()
+}
+
+// CreateGLAccount adds an account to the chart of accounts. Its type follows
+// from the first digit of the code.
+func (s *AccountingService) CreateGLAccount(req GLAccountCreateRequest) (*models.GLAccount, error) {
+	code := strings.TrimSpace(req.Code)
+	accountType, err := accounting.AccountTypeOf(code)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("account name is required")
+	}
+
+	var existing int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM gl_accounts WHERE code = ?", code).Scan(&existing); err != nil {
+		return nil, fmt.Errorf("failed to check account code: %v", err)
+	}
+	if existing > 0 {
+		return nil, fmt.Errorf("account code %s already exists", code)
+	}
+
+	account := &models.GLAccount{Code: code, Name: name, Type: accountType, CreatedAt: time.Now()}
+	_, err = s.db.Exec("INSERT INTO gl_accounts (code, name, type, system, created_at) VALUES (?, ?, ?, FALSE, ?)",
+		account.Code, account.Name, string(account.Type), account.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create account: %v", err)
+	}
+
+	return account, nil
+}
+
+// PostJournalEntry books a manual entry, such as opening balances or
+// operating expenses. Unbalanced entries are refused.
+func (s *AccountingService) PostJournalEntry(req JournalEntryRequest) (*models.JournalEntry, error) {
+	postedBy, err := parseOptionalUUID(req.PostedBy, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
+	if req.EntryDate.IsZero() {
+		req.EntryDate = time.Now()
+	}
+
+	entry := &models.JournalEntry{
+		EntryDate:   req.EntryDate,
+		Description: strings.TrimSpace(req.Description),
+		Source:      models.JournalSourceManual,
+		PostedBy:    postedBy,
+		Lines:       make([]models.JournalLine, len(req.Lines)),
+	}
+	for i, line := range req.Lines {
+		line.AccountCode = strings.TrimSpace(line.AccountCode)
+		line.AccountName = ""
+		line.Memo = strings.TrimSpace(line.Memo)
+		entry.Lines[i] = line
+	}
+	if err := accounting.ValidateEntry(*entry); err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		for _, line := range entry.Lines {
+			var existing int
+			err := tx.QueryRow("SELECT COUNT(*) FROM gl_accounts WHERE code = ?", line.AccountCode).Scan(&existing)
+			if err != nil {
+				return fmt.Errorf("failed to check account: %v", err)
+			}
+			if existing == 0 {
+				return fmt.Errorf("account %s not found", line.AccountCode)
+			}
+		}
+		return postJournalEntry(tx, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetJournalEntry(entry.ID.String())
+}
+
+func (s *AccountingService) GetJournalEntry(id string) (*models.JournalEntry, error) {
+	entryID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid journal entry ID")
+	}
+
+	entries, err := s.queryJournalEntries("id = ?", entryID.String())
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("journal entry not found")
+	}
+
+	return &entries[0], nil
+}
+
+// ListJournalEntries lists the entries dated in a period with their lines,
+// oldest first
+func (s *AccountingService) ListJournalEntries(req JournalEntryListRequest) ([]models.JournalEntry, error) {
+	start, end, err := reportPeriod(req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	condition := "date(entry_date) BETWEEN date(?) AND date(?)"
+	args := []interface{}{start.Format("2006-01-02"), end.Format("2006-01-02")}
+	if req.Source != "" {
+		condition += " AND source = ?"
+		args = append(args, string(req.Source))
+	}
+	if code := strings.TrimSpace(req.AccountCode); code != "" {
+		condition += " AND id IN (SELECT entry_id FROM journal_lines WHERE account_code = ?)"
+		args = append(args, code)
+	}
+
+	return s.queryJournalEntries(condition, args...)
+}
+
+func (s *AccountingService) queryJournalEntries(condition string, args ...interface{}) ([]models.JournalEntry, error) {
+	rows, err := s.db.Query("SELECT "+journalEntryColumns+" FROM journal_entries WHERE "+condition+
+		" ORDER BY entry_date, entry_number", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query journal entries: %v", err)
+	}
+	defer rows.Close()
+
+	entries := make([]models.JournalEntry, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		var entry models.JournalEntry
+		var sourceID, postedBy sql.NullString
+		err := rows.Scan(&entry.ID, &entry.EntryNumber, &entry.EntryDate, &entry.Description, &entry.Source,
+			&sourceID, &postedBy, &entry.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan journal entry: %v", err)
+		}
+		entry.SourceID = uuidFromNull(sourceID)
+		entry.PostedBy = uuidFromNull(postedBy)
+		entry.Lines = make([]models.JournalLine, 0, 2)
+		index[entry.ID.String()] = len(entries)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	lines, err := s.db.Query(`
+		SELECT jl.entry_id, jl.account_code, a.name, jl.debit, jl.credit, COALESCE(jl.memo, '')
+		FROM journal_lines jl
+		JOIN gl_accounts a ON a.code = jl.account_code
+		WHERE jl.entry_id IN (SELECT id FROM journal_entries WHERE `+condition+`)
+		ORDER BY jl.entry_id, jl.line_number`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query journal lines: %v", err)
+	}
+	defer lines.Close()
+
+	for lines.Next() {
+		var entryID string
+		var line models.JournalLine
+		err := lines.Scan(&entryID, &line.AccountCode, &line.AccountName, &line.Debit, &line.Credit, &line.Memo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan journal line: %v", err)
+		}
+		if i, ok := index[entryID]; ok {
+			entries[i].Lines = append(entries[i].Lines, line)
+		}
+	}
+
+	return entries, lines.Err()
+}
+
+// postJournalEntry numbers and records an entry in the caller's transaction,
+// so the entry is kept only if what it books is. An entry without lines has
+// nothing to book and is skipped; an unbalanced one fails the transaction.
+func postJournalEntry(tx *sql.Tx, entry *models.JournalEntry) error {
+	if len(entry.Lines) == 0 {
+		return nil
+	}
+	if err := accounting.ValidateEntry(*entry); err != nil {
+		return err
+	}
+
+	entry.ID = uuid.New()
+	entry.CreatedAt = time.Now()
+	var err error
+	entry.EntryNumber, err = nextJournalEntryNumber(tx, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO journal_entries (id, entry_number, entry_date, description, source, source_id, posted_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID.String(), entry.EntryNumber, entry.EntryDate, entry.Description, string(entry.Source),
+		nullableUUID(entry.SourceID), nullableUUID(entry.PostedBy), entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record journal entry: %v", err)
+	}
+
+	for i, line := range entry.Lines {
+		_, err := tx.Exec(`
+			INSERT INTO journal_lines (entry_id, line_number, account_code, debit, credit, memo)
+			VALUES (?, ?, ?, ?, ?, NULLIF(?, ''))`,
+			entry.ID.String(), i+1, line.AccountCode, line.Debit, line.Credit, line.Memo)
+		if err != nil {
+			return fmt.Errorf("failed to record journal line %d: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// nextJournalEntryNumber numbers journal entries per day as JU-YYYYMMDD-NNNN
+func nextJournalEntryNumber(tx *sql.Tx, at time.Time) (string, error) {
+	prefix := fmt.Sprintf("JU-%s-", at.Format("20060102"))
+
+	var last int
+	err := tx.QueryRow(`
+		SELECT COALESCE(MAX(CAST(substr(entry_number, ?) AS INTEGER)), 0)
+		FROM journal_entries
+		WHERE entry_number LIKE ?`, len(prefix)+1, prefix+"%").Scan(&last)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate entry number: %v", err)
+	}
+
+	return fmt.Sprintf("%s%04d", prefix, last+1), nil
+}
+
+// closeYear posts the closing entry of a financial year, moving what its
+// income and expense accounts still hold to SHU Belum Dibagi. Closing a year
+// again only picks up entries booked to it since.
+func closeYear(tx *sql.Tx, year int, closedBy *uuid.UUID) error {
+	rows, err := tx.Query(`
+		SELECT jl.account_code, COALESCE(SUM(jl.debit - jl.credit), 0)
+		FROM journal_lines jl
+		JOIN journal_entries je ON je.id = jl.entry_id
+		JOIN gl_accounts a ON a.code = jl.account_code
+		WHERE a.type IN ('income', 'expense') AND date(je.entry_date) BETWEEN date(?) AND date(?)
+		GROUP BY jl.account_code`, fmt.Sprintf("%04d-01-01", year), fmt.Sprintf("%04d-12-31", year))
+	if err != nil {
+		return fmt.Errorf("failed to query year balances: %v", err)
+	}
+	defer rows.Close()
+
+	balances := make(map[string]int64)
+	for rows.Next() {
+		var code string
+		var balance int64
+		if err := rows.Scan(&code, &balance); err != nil {
+			return fmt.Errorf("failed to scan year balance: %v", err)
+		}
+		balances[code] = balance
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	entry := accounting.Closing(year, balances, closedBy)
+	return postJournalEntry(tx, &entry)
+}
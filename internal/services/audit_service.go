@@ -427,6 +427,19 @@ func (s *AuditService) LogLoanDisburse(userID *string, loan *models.Loan, ipAddr
 	return err
 }
 
+func (s *AuditService) LogLoanStatusChange(userID *string, loanID string, from, to models.LoanStatus, reason string) error {
+	req := AuditLogRequest{
+		UserID:   userID,
+		Action:   "status_change",
+		Entity:   "loan",
+		EntityID: loanID,
+		Before:   map[string]interface{}{"status": from},
+		After:    map[string]interface{}{"status": to, "reason": reason},
+	}
+	_, err := s.LogAction(req)
+	return err
+}
+
 func (s *AuditService) LogInstallmentPayment(userID *string, installment *models.LoanInstallment, ipAddress, userAgent string) error {
 	req := AuditLogRequest{
 		UserID:    userID,
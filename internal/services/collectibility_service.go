@@ -0,0 +1,238 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+)
+
+// CollectibilityService classifies running loans into the OJK collectibility
+// classes and reports the loan-loss provisions (PPAP) they require
+type CollectibilityService struct {
+	db *database.DB
+}
+
+func NewCollectibilityService(db *database.DB) *CollectibilityService {
+	return &CollectibilityService{db: db}
+}
+
+// restructuredTimelyPayments is how many installments of a restructured
+// schedule must be paid on time before the loan is classified by DPD again
+const restructuredTimelyPayments = 3
+
+// ClassifyCollectibility returns the class of a loan from its days past due.
+// A restructured loan is at best Kurang Lancar until it has paid
+// restructuredTimelyPayments installments of the new schedule on time.
+func ClassifyCollectibility(dpd int, restructured bool, timelyPayments int) models.Collectibility {
+	var class models.Collectibility
+	switch {
+	case dpd <= 0:
+		class = models.CollectibilityLancar
+	case dpd <= 90:
+		class = models.CollectibilityDPK
+	case dpd <= 120:
+		class = models.CollectibilityKurangLancar
+	case dpd <= 180:
+		class = models.CollectibilityDiragukan
+	default:
+		class = models.CollectibilityMacet
+	}
+
+	if restructured && timelyPayments < restructuredTimelyPayments && class < models.CollectibilityKurangLancar {
+		class = models.CollectibilityKurangLancar
+	}
+	return class
+}
+
+// ProvisionRate is the PPAP required for a class, in percent of the
+// outstanding principal: the general 0.5% for performing loans and the
+// special provisions for the others. Collateral is not deducted.
+func ProvisionRate(class models.Collectibility) float64 {
+	switch class {
+	case models.CollectibilityLancar:
+		return 0.5
+	case models.CollectibilityDPK:
+		return 3
+	case models.CollectibilityKurangLancar:
+		return 10
+	case models.CollectibilityDiragukan:
+		return 50
+	case models.CollectibilityMacet:
+		return 100
+	}
+	return 0
+}
+
+// ClassifyLoans stores the collectibility of every running loan for asOf's
+// date, replacing an earlier run on the same day. It returns the number of
+// loans classified.
+func (s *CollectibilityService) ClassifyLoans(asOf time.Time) (int, error) {
+	today := dateOf(asOf)
+	exposures, err := runningLoanExposures(s.db, today, "")
+	if err != nil {
+		return 0, err
+	}
+
+	classifications := make([]models.LoanCollectibility, 0, len(exposures))
+	for _, exposure := range exposures {
+		class := ClassifyCollectibility(exposure.dpd, exposure.restructured, exposure.timelyPayments)
+		rate := ProvisionRate(class)
+		classifications = append(classifications, models.LoanCollectibility{
+			ID:                   uuid.New(),
+			LoanID:               exposure.loanID,
+			AsOf:                 today,
+			DPD:                  exposure.dpd,
+			Collectibility:       class,
+			OutstandingPrincipal: exposure.outstandingPrincipal,
+			ProvisionRate:        rate,
+			Provision:            int64(math.Round(float64(exposure.outstandingPrincipal) * rate / 100)),
+		})
+	}
+
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM loan_collectibility WHERE as_of = ?", today.Format("2006-01-02"))
+		if err != nil {
+			return fmt.Errorf("failed to clear collectibility: %v", err)
+		}
+
+		for _, loan := range classifications {
+			_, err := tx.Exec(`
+				INSERT INTO loan_collectibility (id, loan_id, as_of, dpd, collectibility, outstanding_principal,
+					provision_rate, provision)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				loan.ID.String(), loan.LoanID.String(), today.Format("2006-01-02"), loan.DPD, int(loan.Collectibility),
+				loan.OutstandingPrincipal, loan.ProvisionRate, loan.Provision)
+			if err != nil {
+				return fmt.Errorf("failed to insert collectibility: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(classifications), nil
+}
+
+// loanExposure is a running loan's outstanding principal and arrears
+type loanExposure struct {
+	loanID               uuid.UUID
+	restructured         bool
+	dpd                  int // days since the oldest unpaid installment of the current schedule fell due
+	outstandingPrincipal int64
+	timelyPayments       int // installments of the current schedule paid by their due date
+}
+
+// runningLoanExposures returns the exposure of every disbursed, active or
+// defaulted loan as of asOf. filter is appended to the WHERE clause and may
+// refer to the loan (l), its customer (c) and the customer's referral code
+// (rc).
+func runningLoanExposures(q querier, asOf time.Time, filter string, args ...interface{}) ([]loanExposure, error) {
+	today := dateOf(asOf)
+	rows, err := q.Query(`
+		SELECT l.id, l.restructured,
+		       (SELECT MIN(due_date) FROM loan_installments
+		        WHERE loan_id = l.id AND status != 'paid' AND settled_at IS NULL AND superseded_at IS NULL),
+		       (SELECT COALESCE(SUM(MAX(principal_due - MAX(amount_paid - interest_due, 0), 0)), 0) FROM loan_installments
+		        WHERE loan_id = l.id AND status != 'paid' AND superseded_at IS NULL),
+		       (SELECT COUNT(*) FROM loan_installments
+		        WHERE loan_id = l.id AND superseded_at IS NULL AND status = 'paid' AND date(paid_at) <= date(due_date))
+		FROM loans l
+		JOIN customers c ON l.customer_id = c.id
+		LEFT JOIN referral_codes rc ON c.referral_code_id = rc.id
+		WHERE l.status IN ('disbursed', 'active', 'defaulted')`+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loans: %v", err)
+	}
+	defer rows.Close()
+
+	exposures := make([]loanExposure, 0)
+	for rows.Next() {
+		var exposure loanExposure
+		var oldestDue sql.NullString
+		err := rows.Scan(&exposure.loanID, &exposure.restructured, &oldestDue, &exposure.outstandingPrincipal,
+			&exposure.timelyPayments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan loan: %v", err)
+		}
+
+		// MIN() loses the column's date type, so the value comes back as text
+		if oldestDue.Valid {
+			due, err := time.Parse("2006-01-02", oldestDue.String[:10])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse due date %q: %v", oldestDue.String, err)
+			}
+			exposure.dpd = max(int(today.Sub(dateOf(due)).Hours()/24), 0)
+		}
+		exposures = append(exposures, exposure)
+	}
+
+	return exposures, rows.Err()
+}
+
+// GetCollectibilityReport totals outstanding principal and required
+// provisions per class from the latest classification on or before date
+func (s *CollectibilityService) GetCollectibilityReport(date time.Time) (*models.CollectibilityReport, error) {
+	asOf := dateOf(date)
+	report := &models.CollectibilityReport{AsOf: asOf, Classes: make([]models.CollectibilityClassSummary, 0, 5)}
+	for class := models.CollectibilityLancar; class <= models.CollectibilityMacet; class++ {
+		report.Classes = append(report.Classes, models.CollectibilityClassSummary{
+			Collectibility: class,
+			Name:           class.Name(),
+			ProvisionRate:  ProvisionRate(class),
+		})
+	}
+
+	var classifiedAsOf sql.NullString
+	err := s.db.QueryRow("SELECT MAX(as_of) FROM loan_collectibility WHERE as_of <= ?",
+		asOf.Format("2006-01-02")).Scan(&classifiedAsOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get classification date: %v", err)
+	}
+	if !classifiedAsOf.Valid {
+		return report, nil
+	}
+	classified, err := time.Parse("2006-01-02", classifiedAsOf.String[:10])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse classification date %q: %v", classifiedAsOf.String, err)
+	}
+	report.ClassifiedAsOf = &classified
+
+	rows, err := s.db.Query(`
+		SELECT collectibility, COUNT(*), SUM(outstanding_principal), SUM(provision)
+		FROM loan_collectibility
+		WHERE as_of = ?
+		GROUP BY collectibility`, classifiedAsOf.String)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query collectibility: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var class models.Collectibility
+		var loans int
+		var outstanding, provision int64
+		if err := rows.Scan(&class, &loans, &outstanding, &provision); err != nil {
+			return nil, fmt.Errorf("failed to scan collectibility: %v", err)
+		}
+		if class < models.CollectibilityLancar || class > models.CollectibilityMacet {
+			continue
+		}
+
+		summary := &report.Classes[class-1]
+		summary.Loans = loans
+		summary.OutstandingPrincipal = outstanding
+		summary.Provision = provision
+		report.TotalLoans += loans
+		report.TotalOutstanding += outstanding
+		report.TotalProvision += provision
+	}
+
+	return report, rows.Err()
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/scoring"
+	"koperasi-app/internal/utils"
+)
+
+// CreditScoringService gathers a member's data for the credit score in the
+// scoring package
+type CreditScoringService struct {
+	db        *database.DB
+	cfg       *config.Config
+	encryptor *utils.Encryptor
+}
+
+func NewCreditScoringService(db *database.DB, cfg *config.Config, encryptor *utils.Encryptor) *CreditScoringService {
+	return &CreditScoringService{db: db, cfg: cfg, encryptor: encryptor}
+}
+
+// ScoreCustomer scores a member as a loan applicant today. The score is not
+// stored; CreateLoan stores the score on the new loan.
+func (s *CreditScoringService) ScoreCustomer(customerID string) (*models.CreditScore, error) {
+	id, err := uuid.Parse(customerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid customer ID")
+	}
+
+	asOf := time.Now()
+	applicant, err := s.loadApplicant(id.String(), asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	score := scoring.Score(applicant, s.cfg.Scoring, asOf)
+	return &score, nil
+}
+
+func (s *CreditScoringService) loadApplicant(customerID string, asOf time.Time) (scoring.Applicant, error) {
+	var applicant scoring.Applicant
+	var encryptedNIK string
+	err := s.db.QueryRow(`
+		SELECT nik, date_of_birth, monthly_income, ktp_verified, created_at
+		FROM customers WHERE id = ?`, customerID).Scan(
+		&encryptedNIK, &applicant.DateOfBirth, &applicant.MonthlyIncome, &applicant.KTPVerified, &applicant.MemberSince)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return applicant, fmt.Errorf("customer not found")
+		}
+		return applicant, fmt.Errorf("failed to get customer: %v", err)
+	}
+
+	// The NIK is only needed when no date of birth is recorded
+	if applicant.DateOfBirth.Year() < 1900 && s.encryptor != nil {
+		applicant.NIK, _ = s.encryptor.Decrypt(encryptedNIK)
+	}
+
+	rows, err := s.db.Query("SELECT DISTINCT type FROM documents WHERE customer_id = ? AND status != 'rejected'", customerID)
+	if err != nil {
+		return applicant, fmt.Errorf("failed to query documents: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var documentType models.DocumentType
+		if err := rows.Scan(&documentType); err != nil {
+			return applicant, fmt.Errorf("failed to scan document: %v", err)
+		}
+		applicant.Documents = append(applicant.Documents, documentType)
+	}
+	if err := rows.Err(); err != nil {
+		return applicant, err
+	}
+
+	if err := s.loadPaymentHistory(&applicant, customerID, asOf); err != nil {
+		return applicant, err
+	}
+	return applicant, nil
+}
+
+// loadPaymentHistory looks at every installment of the member that has fallen
+// due. An installment is late from its due date until it was paid, settled or
+// replaced by a restructured schedule, or until asOf while still open.
+func (s *CreditScoringService) loadPaymentHistory(applicant *scoring.Applicant, customerID string, asOf time.Time) error {
+	today := dateOf(asOf)
+	rows, err := s.db.Query(`
+		SELECT li.due_date, li.status, li.paid_at, li.settled_at, li.superseded_at
+		FROM loan_installments li
+		JOIN loans l ON li.loan_id = l.id
+		WHERE l.customer_id = ? AND date(li.due_date) < date(?)`, customerID, today.Format("2006-01-02"))
+	if err != nil {
+		return fmt.Errorf("failed to query payment history: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dueDate time.Time
+		var status models.LoanInstallmentStatus
+		var paidAt, settledAt, supersededAt *time.Time
+		if err := rows.Scan(&dueDate, &status, &paidAt, &settledAt, &supersededAt); err != nil {
+			return fmt.Errorf("failed to scan installment: %v", err)
+		}
+
+		end := today
+		switch {
+		case status == models.InstallmentStatusPaid && paidAt != nil:
+			end = dateOf(*paidAt)
+		case settledAt != nil:
+			end = dateOf(*settledAt)
+		case supersededAt != nil:
+			end = dateOf(*supersededAt)
+		}
+
+		applicant.Installments++
+		dpd := int(end.Sub(dateOf(dueDate)).Hours() / 24)
+		if dpd > 0 {
+			applicant.LatePayments++
+			if dpd > applicant.MaxDPD {
+				applicant.MaxDPD = dpd
+			}
+		}
+	}
+
+	return rows.Err()
+}
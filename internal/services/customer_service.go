@@ -13,11 +13,12 @@ import (
 )
 
 type CustomerService struct {
-	db *database.DB
+	db        *database.DB
+	encryptor *utils.Encryptor
 }
 
-func NewCustomerService(db *database.DB) *CustomerService {
-	return &CustomerService{db: db}
+func NewCustomerService(db *database.DB, encryptor *utils.Encryptor) *CustomerService {
+	return &CustomerService{db: db, encryptor: encryptor}
 }
 
 type CustomerCreateRequest struct {
@@ -113,17 +114,14 @@ func (s *CustomerService) CreateCustomer(req CustomerCreateRequest) (*models.Cus
 		}
 	}
 
-	// Check if NIK already exists
-	utils.LogDebug("Encrypting NIK...")
-	encryptedNIK, err := utils.Encrypt(req.NIK)
-	if err != nil {
-		utils.LogError("Failed to encrypt NIK: %v", err)
-		return nil, fmt.Errorf("failed to encrypt NIK: %v", err)
-	}
+	// Check if NIK already exists (via blind index, ciphertexts never match)
+	nikHash := s.encryptor.BlindIndex(utils.NormalizeNIK(req.NIK))
+	phoneHash := s.encryptor.BlindIndex(utils.NormalizeIndonesianPhone(req.Phone))
+	emailHash := s.encryptor.BlindIndex(utils.NormalizeEmail(req.Email))
 
 	utils.LogDebug("Checking if NIK already exists...")
 	var existingID string
-	err = s.db.QueryRow("SELECT id FROM customers WHERE nik = ?", encryptedNIK).Scan(&existingID)
+	err := s.db.QueryRow("SELECT id FROM customers WHERE nik_hash = ?", nikHash).Scan(&existingID)
 	if err != sql.ErrNoRows {
 		if err == nil {
 			utils.LogError("NIK already registered")
@@ -134,12 +132,7 @@ func (s *CustomerService) CreateCustomer(req CustomerCreateRequest) (*models.Cus
 	}
 
 	// Check if phone already exists
-	encryptedPhone, err := utils.Encrypt(req.Phone)
-	if err != nil {
-		return nil, fmt.Errorf("failed to encrypt phone: %v", err)
-	}
-
-	err = s.db.QueryRow("SELECT id FROM customers WHERE phone = ?", encryptedPhone).Scan(&existingID)
+	err = s.db.QueryRow("SELECT id FROM customers WHERE phone_hash = ?", phoneHash).Scan(&existingID)
 	if err != sql.ErrNoRows {
 		if err == nil {
 			return nil, fmt.Errorf("nomor telepon sudah terdaftar")
@@ -147,6 +140,17 @@ func (s *CustomerService) CreateCustomer(req CustomerCreateRequest) (*models.Cus
 		return nil, fmt.Errorf("failed to check existing phone: %v", err)
 	}
 
+	// Check if email already exists
+	if emailHash != "" {
+		err = s.db.QueryRow("SELECT id FROM customers WHERE email_hash = ?", emailHash).Scan(&existingID)
+		if err != sql.ErrNoRows {
+			if err == nil {
+				return nil, fmt.Errorf("email sudah terdaftar")
+			}
+			return nil, fmt.Errorf("failed to check existing email: %v", err)
+		}
+	}
+
 	// Get referral code if provided
 	var referralCodeID *uuid.UUID
 	if req.ReferralCode != "" {
@@ -183,7 +187,19 @@ func (s *CustomerService) CreateCustomer(req CustomerCreateRequest) (*models.Cus
 	}
 
 	// Encrypt sensitive data
-	encryptedEmail, err := utils.Encrypt(req.Email)
+	utils.LogDebug("Encrypting NIK...")
+	encryptedNIK, err := s.encryptor.Encrypt(req.NIK)
+	if err != nil {
+		utils.LogError("Failed to encrypt NIK: %v", err)
+		return nil, fmt.Errorf("failed to encrypt NIK: %v", err)
+	}
+
+	encryptedPhone, err := s.encryptor.Encrypt(req.Phone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt phone: %v", err)
+	}
+
+	encryptedEmail, err := s.encryptor.Encrypt(req.Email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt email: %v", err)
 	}
@@ -218,16 +234,21 @@ func (s *CustomerService) CreateCustomer(req CustomerCreateRequest) (*models.Cus
 
 	insertQuery := `
 		INSERT INTO customers 
-		(id, nik, name, email, phone, date_of_birth, address, city, province, postal_code, occupation, monthly_income, referral_code_id, status, ktp_verified)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		(id, nik, name, email, phone, date_of_birth, address, city, province, postal_code, occupation, monthly_income, referral_code_id, status, ktp_verified, key_version,
+		 nik_hash, phone_hash, email_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))`
 	
 	utils.LogDebug("Insert query: %s", insertQuery)
 	_, err = s.db.Exec(insertQuery,
 		customer.ID.String(), encryptedNIK, customer.Name, encryptedEmail, encryptedPhone,
 		customer.DateOfBirth, customer.Address, customer.City, customer.Province, customer.PostalCode,
-		customer.Occupation, customer.MonthlyIncome, referralCodeIDStr, string(customer.Status), customer.KTPVerified)
+		customer.Occupation, customer.MonthlyIncome, referralCodeIDStr, string(customer.Status), customer.KTPVerified,
+		s.encryptor.Version(), nikHash, phoneHash, emailHash)
 	if err != nil {
 		utils.LogError("Failed to insert customer: %v", err)
+		if dupErr := duplicateCustomerError(err); dupErr != nil {
+			return nil, dupErr
+		}
 		return nil, fmt.Errorf("failed to insert customer: %v", err)
 	}
 	
@@ -271,9 +292,9 @@ func (s *CustomerService) GetCustomer(id string) (*models.Customer, error) {
 	}
 
 	// Decrypt sensitive data
-	customer.NIK, _ = utils.Decrypt(encryptedNIK)
-	customer.Email, _ = utils.Decrypt(encryptedEmail)
-	customer.Phone, _ = utils.Decrypt(encryptedPhone)
+	customer.NIK, _ = s.encryptor.Decrypt(encryptedNIK)
+	customer.Email, _ = s.encryptor.Decrypt(encryptedEmail)
+	customer.Phone, _ = s.encryptor.Decrypt(encryptedPhone)
 
 	// Parse referral code ID
 	if referralCodeIDStr != nil {
@@ -282,6 +303,11 @@ func (s *CustomerService) GetCustomer(id string) (*models.Customer, error) {
 		}
 	}
 
+	customer.Savings, err = memberSavingsAccounts(s.db, customer.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
 	return &customer, nil
 }
 
@@ -321,13 +347,35 @@ func (s *CustomerService) UpdateCustomer(id string, req CustomerUpdateRequest) (
 		return nil, fmt.Errorf("failed to check customer existence: %v", err)
 	}
 
+	// Check phone and email are not used by another customer
+	phoneHash := s.encryptor.BlindIndex(utils.NormalizeIndonesianPhone(req.Phone))
+	emailHash := s.encryptor.BlindIndex(utils.NormalizeEmail(req.Email))
+
+	err = s.db.QueryRow("SELECT id FROM customers WHERE phone_hash = ? AND id != ?", phoneHash, customerID.String()).Scan(&existingID)
+	if err != sql.ErrNoRows {
+		if err == nil {
+			return nil, fmt.Errorf("nomor telepon sudah terdaftar")
+		}
+		return nil, fmt.Errorf("failed to check existing phone: %v", err)
+	}
+
+	if emailHash != "" {
+		err = s.db.QueryRow("SELECT id FROM customers WHERE email_hash = ? AND id != ?", emailHash, customerID.String()).Scan(&existingID)
+		if err != sql.ErrNoRows {
+			if err == nil {
+				return nil, fmt.Errorf("email sudah terdaftar")
+			}
+			return nil, fmt.Errorf("failed to check existing email: %v", err)
+		}
+	}
+
 	// Encrypt sensitive data
-	encryptedEmail, err := utils.Encrypt(req.Email)
+	encryptedEmail, err := s.encryptor.Encrypt(req.Email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt email: %v", err)
 	}
 
-	encryptedPhone, err := utils.Encrypt(req.Phone)
+	encryptedPhone, err := s.encryptor.Encrypt(req.Phone)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt phone: %v", err)
 	}
@@ -336,11 +384,16 @@ func (s *CustomerService) UpdateCustomer(id string, req CustomerUpdateRequest) (
 	_, err = s.db.Exec(`
 		UPDATE customers 
 		SET name = ?, email = ?, phone = ?, date_of_birth = ?, address = ?, city = ?, province = ?, 
-		    postal_code = ?, occupation = ?, monthly_income = ?, status = ?
+		    postal_code = ?, occupation = ?, monthly_income = ?, status = ?,
+		    phone_hash = ?, email_hash = NULLIF(?, '')
 		WHERE id = ?`,
 		req.Name, encryptedEmail, encryptedPhone, req.DateOfBirth, req.Address, req.City,
-		req.Province, req.PostalCode, req.Occupation, req.MonthlyIncome, req.Status, customerID.String())
+		req.Province, req.PostalCode, req.Occupation, req.MonthlyIncome, req.Status,
+		phoneHash, emailHash, customerID.String())
 	if err != nil {
+		if dupErr := duplicateCustomerError(err); dupErr != nil {
+			return nil, dupErr
+		}
 		return nil, fmt.Errorf("failed to update customer: %v", err)
 	}
 
@@ -374,11 +427,9 @@ func (s *CustomerService) ListCustomers(req CustomerListRequest) (*CustomerListR
 		WHERE 1=1`)
 
 	// Add search filter
-	if req.Search != "" {
-		queryBuilder.WriteString(" AND (c.name LIKE ? OR c.address LIKE ? OR c.city LIKE ?)")
-		searchPattern := "%" + req.Search + "%"
-		args = append(args, searchPattern, searchPattern, searchPattern)
-	}
+	searchClause, searchArgs := s.searchFilter(req.Search)
+	queryBuilder.WriteString(searchClause)
+	args = append(args, searchArgs...)
 
 	// Add status filter
 	if req.Status != "" {
@@ -448,13 +499,13 @@ func (s *CustomerService) ListCustomers(req CustomerListRequest) (*CustomerListR
 		}
 
 		// Decrypt and mask sensitive data for list view
-		if decryptedNIK, err := utils.Decrypt(encryptedNIK); err == nil {
+		if decryptedNIK, err := s.encryptor.Decrypt(encryptedNIK); err == nil {
 			customer.NIK = utils.MaskNIK(decryptedNIK)
 		}
-		if decryptedEmail, err := utils.Decrypt(encryptedEmail); err == nil {
+		if decryptedEmail, err := s.encryptor.Decrypt(encryptedEmail); err == nil {
 			customer.Email = utils.MaskEmail(decryptedEmail)
 		}
-		if decryptedPhone, err := utils.Decrypt(encryptedPhone); err == nil {
+		if decryptedPhone, err := s.encryptor.Decrypt(encryptedPhone); err == nil {
 			customer.Phone = utils.MaskPhone(decryptedPhone)
 		}
 
@@ -476,11 +527,8 @@ func (s *CustomerService) ListCustomers(req CustomerListRequest) (*CustomerListR
 
 	countQuery.WriteString("SELECT COUNT(*) FROM customers c LEFT JOIN referral_codes rc ON c.referral_code_id = rc.id WHERE 1=1")
 
-	if req.Search != "" {
-		countQuery.WriteString(" AND (c.name LIKE ? OR c.address LIKE ? OR c.city LIKE ?)")
-		searchPattern := "%" + req.Search + "%"
-		countArgs = append(countArgs, searchPattern, searchPattern, searchPattern)
-	}
+	countQuery.WriteString(searchClause)
+	countArgs = append(countArgs, searchArgs...)
 
 	if req.Status != "" {
 		countQuery.WriteString(" AND c.status = ?")
@@ -518,6 +566,36 @@ func (s *CustomerService) ListCustomers(req CustomerListRequest) (*CustomerListR
 	return response, nil
 }
 
+// searchFilter matches name, address and city by substring, and NIK or phone
+// by exact value through their blind indexes.
+func (s *CustomerService) searchFilter(search string) (string, []interface{}) {
+	search = strings.TrimSpace(search)
+	if search == "" {
+		return "", nil
+	}
+
+	searchPattern := "%" + search + "%"
+	nikHash := s.encryptor.BlindIndex(utils.NormalizeNIK(search))
+	phoneHash := s.encryptor.BlindIndex(utils.NormalizeIndonesianPhone(search))
+
+	clause := " AND (c.name LIKE ? OR c.address LIKE ? OR c.city LIKE ? OR c.nik_hash = ? OR c.phone_hash = ?)"
+	return clause, []interface{}{searchPattern, searchPattern, searchPattern, nikHash, phoneHash}
+}
+
+// duplicateCustomerError maps unique blind index violations to user-facing errors
+func duplicateCustomerError(err error) error {
+	message := err.Error()
+	switch {
+	case strings.Contains(message, "customers.nik_hash"):
+		return fmt.Errorf("NIK sudah terdaftar")
+	case strings.Contains(message, "customers.phone_hash"):
+		return fmt.Errorf("nomor telepon sudah terdaftar")
+	case strings.Contains(message, "customers.email_hash"):
+		return fmt.Errorf("email sudah terdaftar")
+	}
+	return nil
+}
+
 func (s *CustomerService) DeleteCustomer(id string) error {
 	customerID, err := uuid.Parse(id)
 	if err != nil {
@@ -534,6 +612,17 @@ func (s *CustomerService) DeleteCustomer(id string) error {
 		return fmt.Errorf("failed to check customer existence: %v", err)
 	}
 
+	// Savings are owed back to the member, so they must be paid out first
+	var savings int64
+	err = s.db.QueryRow("SELECT COALESCE(SUM(balance), 0) FROM savings_accounts WHERE customer_id = ?",
+		customerID.String()).Scan(&savings)
+	if err != nil {
+		return fmt.Errorf("failed to check member savings: %v", err)
+	}
+	if savings > 0 {
+		return fmt.Errorf("cannot delete a member holding savings of %d; withdraw them first", savings)
+	}
+
 	// Delete customer (cascade will handle related records)
 	_, err = s.db.Exec("DELETE FROM customers WHERE id = ?", customerID.String())
 	if err != nil {
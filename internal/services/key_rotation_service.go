@@ -0,0 +1,167 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"filippo.io/age"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/utils"
+)
+
+type KeyRotationService struct {
+	db           *database.DB
+	auditService *AuditService
+}
+
+func NewKeyRotationService(db *database.DB, auditService *AuditService) *KeyRotationService {
+	return &KeyRotationService{db: db, auditService: auditService}
+}
+
+type KeyRotationResult struct {
+	OldVersion       int       `json:"old_version"`
+	NewVersion       int       `json:"new_version"`
+	CustomersRotated int       `json:"customers_rotated"`
+	CustomersSkipped int       `json:"customers_skipped"`
+	RotatedAt        time.Time `json:"rotated_at"`
+}
+
+type customerCiphertext struct {
+	id    string
+	nik   string
+	email string
+	phone string
+	// indexed is false for duplicate rows the blind index backfill skipped
+	indexed bool
+}
+
+// RotateCustomerKeys re-encrypts customers.nik/email/phone from oldIdentity to
+// newIdentity in a single transaction. Rows already on the new key version are
+// skipped, so an interrupted rotation can be run again with the same keys.
+func (s *KeyRotationService) RotateCustomerKeys(oldIdentity, newIdentity *age.X25519Identity, userID *string) (*KeyRotationResult, error) {
+	if oldIdentity.Recipient().String() == newIdentity.Recipient().String() {
+		return nil, fmt.Errorf("new encryption key must differ from the current key")
+	}
+
+	result := &KeyRotationResult{RotatedAt: time.Now()}
+
+	err := s.db.WithTx(func(tx *sql.Tx) error {
+		oldVersion, err := database.RegisterKeyVersion(tx, oldIdentity)
+		if err != nil {
+			return fmt.Errorf("failed to register old key version: %v", err)
+		}
+		newVersion, err := database.RegisterKeyVersion(tx, newIdentity)
+		if err != nil {
+			return fmt.Errorf("failed to register new key version: %v", err)
+		}
+		result.OldVersion = oldVersion
+		result.NewVersion = newVersion
+
+		oldEncryptor := utils.NewEncryptor(oldIdentity, oldVersion)
+		newEncryptor := utils.NewEncryptor(newIdentity, newVersion)
+
+		err = tx.QueryRow("SELECT COUNT(*) FROM customers WHERE key_version = ?", newVersion).Scan(&result.CustomersSkipped)
+		if err != nil {
+			return fmt.Errorf("failed to count rotated customers: %v", err)
+		}
+
+		pending, err := s.pendingCustomers(tx, newVersion)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range pending {
+			if err := s.rotateCustomer(tx, row, oldEncryptor, newEncryptor); err != nil {
+				return err
+			}
+			result.CustomersRotated++
+		}
+
+		_, err = tx.Exec("UPDATE encryption_keys SET retired_at = ? WHERE version = ? AND retired_at IS NULL",
+			result.RotatedAt, oldVersion)
+		if err != nil {
+			return fmt.Errorf("failed to retire old key version: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.auditService.LogAction(AuditLogRequest{
+		UserID:   userID,
+		Action:   "rotate_key",
+		Entity:   "encryption_key",
+		EntityID: fmt.Sprintf("%d", result.NewVersion),
+		Before:   map[string]interface{}{"key_version": result.OldVersion},
+		After:    result,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("keys rotated but failed to write audit log: %v", err)
+	}
+
+	return result, nil
+}
+
+func (s *KeyRotationService) pendingCustomers(tx *sql.Tx, newVersion int) ([]customerCiphertext, error) {
+	rows, err := tx.Query(`
+		SELECT id, nik, COALESCE(email, ''), COALESCE(phone, ''), nik_hash IS NOT NULL
+		FROM customers
+		WHERE key_version IS NULL OR key_version != ?`, newVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customers: %v", err)
+	}
+	defer rows.Close()
+
+	pending := make([]customerCiphertext, 0)
+	for rows.Next() {
+		var row customerCiphertext
+		if err := rows.Scan(&row.id, &row.nik, &row.email, &row.phone, &row.indexed); err != nil {
+			return nil, fmt.Errorf("failed to scan customer: %v", err)
+		}
+		pending = append(pending, row)
+	}
+
+	return pending, rows.Err()
+}
+
+// rotateCustomer re-encrypts one row and recomputes its blind indexes, whose
+// key is derived from the encryption key.
+func (s *KeyRotationService) rotateCustomer(tx *sql.Tx, row customerCiphertext, oldEncryptor, newEncryptor *utils.Encryptor) error {
+	var plaintexts [3]string
+	var nikHash, emailHash, phoneHash string
+	fields := []*string{&row.nik, &row.email, &row.phone}
+	for i, field := range fields {
+		plaintext, err := oldEncryptor.Decrypt(*field)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt customer %s with the current key: %v", row.id, err)
+		}
+		plaintexts[i] = plaintext
+
+		*field, err = newEncryptor.Encrypt(plaintext)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt customer %s: %v", row.id, err)
+		}
+	}
+
+	if row.indexed {
+		nikHash = newEncryptor.BlindIndex(utils.NormalizeNIK(plaintexts[0]))
+		emailHash = newEncryptor.BlindIndex(utils.NormalizeEmail(plaintexts[1]))
+		phoneHash = newEncryptor.BlindIndex(utils.NormalizeIndonesianPhone(plaintexts[2]))
+	}
+
+	_, err := tx.Exec(`
+		UPDATE customers
+		SET nik = ?, email = ?, phone = ?, key_version = ?,
+		    nik_hash = NULLIF(?, ''), email_hash = NULLIF(?, ''), phone_hash = NULLIF(?, '')
+		WHERE id = ?`,
+		row.nik, row.email, row.phone, newEncryptor.Version(),
+		nikHash, emailHash, phoneHash, row.id)
+	if err != nil {
+		return fmt.Errorf("failed to update customer %s: %v", row.id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+)
+
+// LoanApprovalService runs the maker-checker workflow: the loan's maker
+// submits it, and other users within their approval limits approve or reject
+// it.
+type LoanApprovalService struct {
+	db          *database.DB
+	cfg         *config.Config
+	loanService *LoanService
+}
+
+func NewLoanApprovalService(db *database.DB, cfg *config.Config, loanService *LoanService) *LoanApprovalService {
+	return &LoanApprovalService{db: db, cfg: cfg, loanService: loanService}
+}
+
+type LoanApprovalRequest struct {
+	LoanID     string `json:"loan_id"`
+	ApproverID string `json:"approver_id"`
+	Notes      string `json:"notes"` // required when rejecting
+}
+
+// SubmitLoanForApproval hands a pending loan to the checkers. From then on its
+// terms are fixed and it can only be approved, rejected or cancelled.
+func (s *LoanApprovalService) SubmitLoanForApproval(loanID, userID string) (*models.Loan, error) {
+	id, err := uuid.Parse(loanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+	submitterID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		var status models.LoanStatus
+		var submitted bool
+		err := tx.QueryRow("SELECT status, submitted_at IS NOT NULL FROM loans WHERE id = ?", id.String()).Scan(&status, &submitted)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("loan not found")
+			}
+			return fmt.Errorf("failed to get loan: %v", err)
+		}
+		if status != models.LoanStatusPending {
+			return fmt.Errorf("only pending loans can be submitted for approval")
+		}
+		if submitted {
+			return fmt.Errorf("loan has already been submitted for approval")
+		}
+
+		_, err = tx.Exec("UPDATE loans SET submitted_by = ?, submitted_at = ? WHERE id = ?",
+			submitterID.String(), time.Now(), id.String())
+		if err != nil {
+			return fmt.Errorf("failed to submit loan: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loanService.GetLoan(id.String())
+}
+
+// ApproveLoan records an approval of a submitted loan. The loan becomes
+// approved once it has as many approvals as its amount needs; the approver
+// completing it must have a limit covering the whole amount.
+func (s *LoanApprovalService) ApproveLoan(req LoanApprovalRequest) (*models.Loan, error) {
+	return s.decide(req, models.ApprovalDecisionApproved)
+}
+
+// RejectLoan declines a submitted loan, which cancels it
+func (s *LoanApprovalService) RejectLoan(req LoanApprovalRequest) (*models.Loan, error) {
+	return s.decide(req, models.ApprovalDecisionRejected)
+}
+
+func (s *LoanApprovalService) decide(req LoanApprovalRequest, decision models.ApprovalDecision) (*models.Loan, error) {
+	loanID, err := uuid.Parse(req.LoanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+	approverID, err := uuid.Parse(req.ApproverID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid approver ID")
+	}
+
+	notes := strings.TrimSpace(req.Notes)
+	if decision == models.ApprovalDecisionRejected && notes == "" {
+		return nil, fmt.Errorf("a reason is required to reject a loan")
+	}
+
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		var amount int64
+		var status models.LoanStatus
+		var createdBy, submittedBy sql.NullString
+		var submitted bool
+		err := tx.QueryRow(`
+			SELECT amount, status, created_by, submitted_by, submitted_at IS NOT NULL
+			FROM loans WHERE id = ?`, loanID.String()).Scan(&amount, &status, &createdBy, &submittedBy, &submitted)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("loan not found")
+			}
+			return fmt.Errorf("failed to get loan: %v", err)
+		}
+		if status != models.LoanStatusPending || !submitted {
+			return fmt.Errorf("loan is not awaiting approval")
+		}
+		if approverID.String() == createdBy.String || approverID.String() == submittedBy.String {
+			return fmt.Errorf("a loan cannot be approved or rejected by its maker")
+		}
+
+		var role models.UserRole
+		var active bool
+		err = tx.QueryRow("SELECT role, active FROM users WHERE id = ?", approverID.String()).Scan(&role, &active)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("approver not found")
+			}
+			return fmt.Errorf("failed to get approver: %v", err)
+		}
+		if !active {
+			return fmt.Errorf("approver is not active")
+		}
+
+		var approvals int
+		var alreadyApproved bool
+		err = tx.QueryRow(`
+			SELECT COUNT(*), COALESCE(MAX(approver_id = ?), FALSE)
+			FROM loan_approvals
+			WHERE loan_id = ? AND decision = 'approved'`,
+			approverID.String(), loanID.String()).Scan(&approvals, &alreadyApproved)
+		if err != nil {
+			return fmt.Errorf("failed to get approvals: %v", err)
+		}
+		if alreadyApproved {
+			return fmt.Errorf("approver has already approved this loan")
+		}
+
+		// Earlier levels only need approval rights; the last approver must be
+		// allowed to approve the whole amount
+		rules := s.cfg.Approval
+		level := approvals + 1
+		required := ApprovalsRequired(rules, amount)
+		final := decision == models.ApprovalDecisionApproved && level >= required
+		if !CanApproveAmount(rules, role, 0) {
+			return fmt.Errorf("%s users cannot approve loans", role)
+		}
+		if final && !CanApproveAmount(rules, role, amount) {
+			return fmt.Errorf("loan amount exceeds the approval limit for %s", role)
+		}
+
+		// Income or other loans may have changed since the loan was created
+		if final {
+			var customerID string
+			var monthlyPayment, otherObligations int64
+			var frequency models.RepaymentFrequency
+			var overrideReason sql.NullString
+			err = tx.QueryRow(`
+				SELECT customer_id, monthly_payment, repayment_frequency, other_obligations, dsr_override_reason
+				FROM loans WHERE id = ?`, loanID.String()).Scan(&customerID, &monthlyPayment, &frequency, &otherObligations, &overrideReason)
+			if err != nil {
+				return fmt.Errorf("failed to get loan: %v", err)
+			}
+			assessment, err := assessLoanAffordability(tx, s.cfg.Affordability, customerID,
+				frequency.MonthlyEquivalent(monthlyPayment), otherObligations, loanID.String())
+			if err != nil {
+				return err
+			}
+			if err := checkAffordability(assessment, overrideReason.String); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO loan_approvals (id, loan_id, level, approver_id, decision, notes, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), loanID.String(), level, approverID.String(), string(decision),
+			nullableString(notes), time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to record approval: %v", err)
+		}
+
+		switch {
+		case decision == models.ApprovalDecisionRejected:
+			return transitionLoanStatus(tx, loanID.String(), models.LoanStatusCancelled, &approverID, "rejected: "+notes)
+		case final:
+			return transitionLoanStatus(tx, loanID.String(), models.LoanStatusApproved, &approverID, notes)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loanService.GetLoan(loanID.String())
+}
+
+// ApprovalsRequired returns how many different approvers a loan of amount
+// needs.
+func ApprovalsRequired(rules config.ApprovalConfig, amount int64) int {
+	if rules.MultiLevelThreshold > 0 && amount > rules.MultiLevelThreshold {
+		return rules.MultiLevelApprovals
+	}
+	return 1
+}
+
+// CanApproveAmount reports whether users with role may approve a loan of
+// amount.
+func CanApproveAmount(rules config.ApprovalConfig, role models.UserRole, amount int64) bool {
+	limit := rules.Limits[string(role)]
+	if limit == 0 {
+		return false
+	}
+	return limit < 0 || amount <= limit
+}
+
+// loanApprovals lists the decisions on a loan in the order they were made
+func loanApprovals(q querier, loanID string) ([]models.LoanApproval, error) {
+	rows, err := q.Query(`
+		SELECT a.id, a.loan_id, a.level, a.approver_id, COALESCE(u.name, ''), a.decision,
+		       COALESCE(a.notes, ''), a.created_at
+		FROM loan_approvals a
+		LEFT JOIN users u ON a.approver_id = u.id
+		WHERE a.loan_id = ?
+		ORDER BY a.created_at, a.level`, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loan approvals: %v", err)
+	}
+	defer rows.Close()
+
+	approvals := make([]models.LoanApproval, 0)
+	for rows.Next() {
+		var approval models.LoanApproval
+		err := rows.Scan(&approval.ID, &approval.LoanID, &approval.Level, &approval.ApproverID, &approval.ApproverName,
+			&approval.Decision, &approval.Notes, &approval.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan loan approval: %v", err)
+		}
+		approvals = append(approvals, approval)
+	}
+
+	return approvals, rows.Err()
+}
@@ -0,0 +1,402 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+)
+
+type LoanProductService struct {
+	db *database.DB
+}
+
+func NewLoanProductService(db *database.DB) *LoanProductService {
+	return &LoanProductService{db: db}
+}
+
+type LoanProductCreateRequest struct {
+	Code                string                    `json:"code"`
+	Name                string                    `json:"name"`
+	Description         string                    `json:"description"`
+	MinAmount           int64                     `json:"min_amount"`
+	MaxAmount           int64                     `json:"max_amount"`
+	AllowedTerms        []int                     `json:"allowed_terms"`
+	DefaultInterestRate float64                   `json:"default_interest_rate"`
+	MinInterestRate     float64                   `json:"min_interest_rate"`
+	MaxInterestRate     float64                   `json:"max_interest_rate"`
+	InterestMethod      models.InterestMethod     `json:"interest_method"`
+	RepaymentFrequency  models.RepaymentFrequency `json:"repayment_frequency"` // defaults to monthly
+	AdminFee            int64                     `json:"admin_fee"`
+	ProvisionFeeRate    float64                   `json:"provision_fee_rate"`
+	MaxActiveLoans      int                       `json:"max_active_loans"`
+	MinMembershipMonths int                       `json:"min_membership_months"`
+	MinMonthlyIncome    int64                     `json:"min_monthly_income"`
+	RequireKTPVerified  bool                      `json:"require_ktp_verified"`
+}
+
+type LoanProductUpdateRequest struct {
+	Name                string                    `json:"name"`
+	Description         string                    `json:"description"`
+	MinAmount           int64                     `json:"min_amount"`
+	MaxAmount           int64                     `json:"max_amount"`
+	AllowedTerms        []int                     `json:"allowed_terms"`
+	DefaultInterestRate float64                   `json:"default_interest_rate"`
+	MinInterestRate     float64                   `json:"min_interest_rate"`
+	MaxInterestRate     float64                   `json:"max_interest_rate"`
+	InterestMethod      models.InterestMethod     `json:"interest_method"`
+	RepaymentFrequency  models.RepaymentFrequency `json:"repayment_frequency"` // defaults to monthly
+	AdminFee            int64                     `json:"admin_fee"`
+	ProvisionFeeRate    float64                   `json:"provision_fee_rate"`
+	MaxActiveLoans      int                       `json:"max_active_loans"`
+	MinMembershipMonths int                       `json:"min_membership_months"`
+	MinMonthlyIncome    int64                     `json:"min_monthly_income"`
+	RequireKTPVerified  bool                      `json:"require_ktp_verified"`
+	Active              bool                      `json:"active"`
+}
+
+const loanProductColumns = `
+	id, code, name, COALESCE(description, ''), min_amount, max_amount, allowed_terms,
+	default_interest_rate, min_interest_rate, max_interest_rate, interest_method, repayment_frequency,
+	admin_fee, provision_fee_rate, max_active_loans, min_membership_months,
+	min_monthly_income, require_ktp_verified, active, created_at, updated_at`
+
+func (s *LoanProductService) CreateLoanProduct(req LoanProductCreateRequest) (*models.LoanProduct, error) {
+	product := &models.LoanProduct{
+		ID:                  uuid.New(),
+		Code:                strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:                strings.TrimSpace(req.Name),
+		Description:         req.Description,
+		MinAmount:           req.MinAmount,
+		MaxAmount:           req.MaxAmount,
+		AllowedTerms:        req.AllowedTerms,
+		DefaultInterestRate: req.DefaultInterestRate,
+		MinInterestRate:     req.MinInterestRate,
+		MaxInterestRate:     req.MaxInterestRate,
+		InterestMethod:      req.InterestMethod,
+		RepaymentFrequency:  req.RepaymentFrequency,
+		AdminFee:            req.AdminFee,
+		ProvisionFeeRate:    req.ProvisionFeeRate,
+		MaxActiveLoans:      req.MaxActiveLoans,
+		MinMembershipMonths: req.MinMembershipMonths,
+		MinMonthlyIncome:    req.MinMonthlyIncome,
+		RequireKTPVerified:  req.RequireKTPVerified,
+		Active:              true,
+		CreatedAt:           time.Now(),
+		UpdatedAt:           time.Now(),
+	}
+
+	if product.Code == "" {
+		return nil, fmt.Errorf("product code is required")
+	}
+	if err := validateLoanProduct(product); err != nil {
+		return nil, err
+	}
+
+	termsJSON, err := json.Marshal(product.AllowedTerms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode allowed terms: %v", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO loan_products (id, code, name, description, min_amount, max_amount, allowed_terms,
+			default_interest_rate, min_interest_rate, max_interest_rate, interest_method, repayment_frequency,
+			admin_fee, provision_fee_rate, max_active_loans, min_membership_months,
+			min_monthly_income, require_ktp_verified, active)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		product.ID.String(), product.Code, product.Name, product.Description,
+		product.MinAmount, product.MaxAmount, string(termsJSON),
+		product.DefaultInterestRate, product.MinInterestRate, product.MaxInterestRate, string(product.InterestMethod),
+		string(product.RepaymentFrequency),
+		product.AdminFee, product.ProvisionFeeRate, product.MaxActiveLoans, product.MinMembershipMonths,
+		product.MinMonthlyIncome, product.RequireKTPVerified, product.Active)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return nil, fmt.Errorf("product code %s already exists", product.Code)
+		}
+		return nil, fmt.Errorf("failed to insert loan product: %v", err)
+	}
+
+	return product, nil
+}
+
+func (s *LoanProductService) GetLoanProduct(id string) (*models.LoanProduct, error) {
+	productID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan product ID")
+	}
+
+	row := s.db.QueryRow("SELECT "+loanProductColumns+" FROM loan_products WHERE id = ?", productID.String())
+	product, err := scanLoanProduct(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("loan product not found")
+		}
+		return nil, fmt.Errorf("failed to get loan product: %v", err)
+	}
+
+	return product, nil
+}
+
+func (s *LoanProductService) UpdateLoanProduct(id string, req LoanProductUpdateRequest) (*models.LoanProduct, error) {
+	product, err := s.GetLoanProduct(id)
+	if err != nil {
+		return nil, err
+	}
+
+	product.Name = strings.TrimSpace(req.Name)
+	product.Description = req.Description
+	product.MinAmount = req.MinAmount
+	product.MaxAmount = req.MaxAmount
+	product.AllowedTerms = req.AllowedTerms
+	product.DefaultInterestRate = req.DefaultInterestRate
+	product.MinInterestRate = req.MinInterestRate
+	product.MaxInterestRate = req.MaxInterestRate
+	product.InterestMethod = req.InterestMethod
+	product.RepaymentFrequency = req.RepaymentFrequency
+	product.AdminFee = req.AdminFee
+	product.ProvisionFeeRate = req.ProvisionFeeRate
+	product.MaxActiveLoans = req.MaxActiveLoans
+	product.MinMembershipMonths = req.MinMembershipMonths
+	product.MinMonthlyIncome = req.MinMonthlyIncome
+	product.RequireKTPVerified = req.RequireKTPVerified
+	product.Active = req.Active
+
+	if err := validateLoanProduct(product); err != nil {
+		return nil, err
+	}
+
+	termsJSON, err := json.Marshal(product.AllowedTerms)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode allowed terms: %v", err)
+	}
+
+	// Existing loans keep the terms they were created with
+	_, err = s.db.Exec(`
+		UPDATE loan_products
+		SET name = ?, description = ?, min_amount = ?, max_amount = ?, allowed_terms = ?,
+			default_interest_rate = ?, min_interest_rate = ?, max_interest_rate = ?, interest_method = ?,
+			repayment_frequency = ?,
+			admin_fee = ?, provision_fee_rate = ?, max_active_loans = ?, min_membership_months = ?,
+			min_monthly_income = ?, require_ktp_verified = ?, active = ?
+		WHERE id = ?`,
+		product.Name, product.Description, product.MinAmount, product.MaxAmount, string(termsJSON),
+		product.DefaultInterestRate, product.MinInterestRate, product.MaxInterestRate, string(product.InterestMethod),
+		string(product.RepaymentFrequency),
+		product.AdminFee, product.ProvisionFeeRate, product.MaxActiveLoans, product.MinMembershipMonths,
+		product.MinMonthlyIncome, product.RequireKTPVerified, product.Active, product.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update loan product: %v", err)
+	}
+
+	return s.GetLoanProduct(id)
+}
+
+// ListLoanProducts returns the product catalog ordered by name. The catalog is
+// small, so it is not paginated.
+func (s *LoanProductService) ListLoanProducts(activeOnly bool) ([]models.LoanProduct, error) {
+	query := "SELECT " + loanProductColumns + " FROM loan_products"
+	if activeOnly {
+		query += " WHERE active = TRUE"
+	}
+	query += " ORDER BY name"
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query loan products: %v", err)
+	}
+	defer rows.Close()
+
+	products := make([]models.LoanProduct, 0)
+	for rows.Next() {
+		product, err := scanLoanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan loan product: %v", err)
+		}
+		products = append(products, *product)
+	}
+
+	return products, rows.Err()
+}
+
+func (s *LoanProductService) DeleteLoanProduct(id string) error {
+	product, err := s.GetLoanProduct(id)
+	if err != nil {
+		return err
+	}
+
+	var loanCount int
+	err = s.db.QueryRow("SELECT COUNT(*) FROM loans WHERE product_id = ?", product.ID.String()).Scan(&loanCount)
+	if err != nil {
+		return fmt.Errorf("failed to check loan product usage: %v", err)
+	}
+	if loanCount > 0 {
+		return fmt.Errorf("cannot delete loan product that is used by %d loans; deactivate it instead", loanCount)
+	}
+
+	_, err = s.db.Exec("DELETE FROM loan_products WHERE id = ?", product.ID.String())
+	if err != nil {
+		return fmt.Errorf("failed to delete loan product: %v", err)
+	}
+
+	return nil
+}
+
+// ValidateApplication checks a loan application against the product's limits
+// and eligibility rules. excludeLoanID skips the loan being edited when
+// counting the customer's active loans.
+func (s *LoanProductService) ValidateApplication(product *models.LoanProduct, customerID uuid.UUID, amount int64, interestRate float64, term int, excludeLoanID *uuid.UUID) error {
+	if !product.Active {
+		return fmt.Errorf("loan product %s is no longer offered", product.Name)
+	}
+
+	if amount < product.MinAmount || amount > product.MaxAmount {
+		return fmt.Errorf("loan amount for %s must be between %d and %d", product.Name, product.MinAmount, product.MaxAmount)
+	}
+	if interestRate < product.MinInterestRate || interestRate > product.MaxInterestRate {
+		return fmt.Errorf("interest rate for %s must be between %.2f and %.2f", product.Name, product.MinInterestRate, product.MaxInterestRate)
+	}
+	if !productAllowsTerm(product, term) {
+		return fmt.Errorf("term of %d %s installments is not offered for %s (allowed: %v)",
+			term, product.RepaymentFrequency, product.Name, product.AllowedTerms)
+	}
+
+	var monthlyIncome int64
+	var ktpVerified bool
+	var memberSince time.Time
+	err := s.db.QueryRow("SELECT monthly_income, ktp_verified, created_at FROM customers WHERE id = ?",
+		customerID.String()).Scan(&monthlyIncome, &ktpVerified, &memberSince)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("customer not found")
+		}
+		return fmt.Errorf("failed to check customer: %v", err)
+	}
+
+	if product.RequireKTPVerified && !ktpVerified {
+		return fmt.Errorf("%s requires a verified KTP", product.Name)
+	}
+	if monthlyIncome < product.MinMonthlyIncome {
+		return fmt.Errorf("%s requires a monthly income of at least %d", product.Name, product.MinMonthlyIncome)
+	}
+	if memberSince.AddDate(0, product.MinMembershipMonths, 0).After(time.Now()) {
+		return fmt.Errorf("%s requires at least %d months of membership", product.Name, product.MinMembershipMonths)
+	}
+
+	activeQuery := `
+		SELECT COUNT(*) FROM loans
+		WHERE customer_id = ? AND status IN ('pending', 'approved', 'disbursed', 'active', 'defaulted')`
+	args := []interface{}{customerID.String()}
+	if excludeLoanID != nil {
+		activeQuery += " AND id != ?"
+		args = append(args, excludeLoanID.String())
+	}
+
+	var activeLoanCount int
+	if err := s.db.QueryRow(activeQuery, args...).Scan(&activeLoanCount); err != nil {
+		return fmt.Errorf("failed to check existing loans: %v", err)
+	}
+	if activeLoanCount >= product.MaxActiveLoans {
+		return fmt.Errorf("customer already has %d active loans; %s allows at most %d", activeLoanCount, product.Name, product.MaxActiveLoans)
+	}
+
+	return nil
+}
+
+// LoanFees returns the admin and provision fees charged on a loan amount
+func LoanFees(product *models.LoanProduct, amount int64) (adminFee, provisionFee int64) {
+	provisionFee = int64(math.Round(float64(amount) * product.ProvisionFeeRate / 100))
+	return product.AdminFee, provisionFee
+}
+
+// Helper functions
+
+func productAllowsTerm(product *models.LoanProduct, term int) bool {
+	for _, allowed := range product.AllowedTerms {
+		if allowed == term {
+			return true
+		}
+	}
+	return false
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanLoanProduct(row rowScanner) (*models.LoanProduct, error) {
+	var product models.LoanProduct
+	var termsJSON string
+	err := row.Scan(
+		&product.ID, &product.Code, &product.Name, &product.Description,
+		&product.MinAmount, &product.MaxAmount, &termsJSON,
+		&product.DefaultInterestRate, &product.MinInterestRate, &product.MaxInterestRate, &product.InterestMethod,
+		&product.RepaymentFrequency, &product.AdminFee, &product.ProvisionFeeRate, &product.MaxActiveLoans, &product.MinMembershipMonths,
+		&product.MinMonthlyIncome, &product.RequireKTPVerified, &product.Active,
+		&product.CreatedAt, &product.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(termsJSON), &product.AllowedTerms); err != nil {
+		return nil, fmt.Errorf("invalid allowed terms for product %s: %v", product.Code, err)
+	}
+
+	return &product, nil
+}
+
+func validateLoanProduct(product *models.LoanProduct) error {
+	if product.Name == "" {
+		return fmt.Errorf("product name is required")
+	}
+	if product.MinAmount <= 0 || product.MaxAmount < product.MinAmount {
+		return fmt.Errorf("amount limits must satisfy 0 < min_amount <= max_amount")
+	}
+	frequency, err := parseRepaymentFrequency(product.RepaymentFrequency)
+	if err != nil {
+		return err
+	}
+	product.RepaymentFrequency = frequency
+
+	// Terms count installments, up to five years of them
+	if len(product.AllowedTerms) == 0 {
+		return fmt.Errorf("at least one allowed term is required")
+	}
+	maxTerm := 5 * frequency.PeriodsPerYear()
+	for _, term := range product.AllowedTerms {
+		if term <= 0 || term > maxTerm {
+			return fmt.Errorf("allowed terms must be between 1 and %d %s installments", maxTerm, frequency)
+		}
+	}
+	sort.Ints(product.AllowedTerms)
+
+	if product.MinInterestRate < 0 || product.MaxInterestRate > 100 ||
+		product.DefaultInterestRate < product.MinInterestRate || product.DefaultInterestRate > product.MaxInterestRate {
+		return fmt.Errorf("interest rates must satisfy 0 <= min <= default <= max <= 100")
+	}
+
+	method, err := parseInterestMethod(product.InterestMethod)
+	if err != nil {
+		return err
+	}
+	product.InterestMethod = method
+
+	if product.AdminFee < 0 || product.ProvisionFeeRate < 0 || product.ProvisionFeeRate > 100 {
+		return fmt.Errorf("fees must not be negative")
+	}
+	if product.MaxActiveLoans < 1 {
+		return fmt.Errorf("max active loans must be at least 1")
+	}
+	if product.MinMembershipMonths < 0 || product.MinMonthlyIncome < 0 {
+		return fmt.Errorf("eligibility rules must not be negative")
+	}
+
+	return nil
+}
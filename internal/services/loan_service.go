@@ -2,36 +2,76 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"math"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/config"
 	"koperasi-app/internal/database"
 	"koperasi-app/internal/models"
+	"koperasi-app/internal/utils"
 )
 
 type LoanService struct {
-	db *database.DB
+	db             *database.DB
+	cfg            *config.Config
+	productService *LoanProductService
+	scoringService *CreditScoringService
 }
 
-func NewLoanService(db *database.DB) *LoanService {
-	return &LoanService{db: db}
+func NewLoanService(db *database.DB, cfg *config.Config, scoringService *CreditScoringService) *LoanService {
+	return &LoanService{db: db, cfg: cfg, productService: NewLoanProductService(db), scoringService: scoringService}
 }
 
 type LoanCreateRequest struct {
 	CustomerID   string  `json:"customer_id"`
+	ProductID    string  `json:"product_id"`
 	Amount       int64   `json:"amount"`
-	InterestRate float64 `json:"interest_rate"`
+	InterestRate float64 `json:"interest_rate"` // 0 uses the product's default rate
 	Term         int     `json:"term"`
+	CreatedBy    string  `json:"created_by"`
+
+	OtherObligations  int64  `json:"other_obligations"`   // monthly installments outside the koperasi
+	DSROverrideReason string `json:"dsr_override_reason"` // required when the repayment-to-income ratio is too high
 }
 
 type LoanUpdateRequest struct {
-	Amount       int64              `json:"amount"`
-	InterestRate float64            `json:"interest_rate"`
-	Term         int                `json:"term"`
-	Status       models.LoanStatus  `json:"status"`
+	Amount         int64                 `json:"amount"`
+	InterestRate   float64               `json:"interest_rate"`
+	InterestMethod models.InterestMethod `json:"interest_method"` // only for loans without a product
+	Term           int                   `json:"term"`
+	Status         models.LoanStatus     `json:"status"`
+	StatusReason   string                `json:"status_reason"` // required to cancel or default a loan
+	UpdatedBy      string                `json:"updated_by"`
+
+	DSROverrideReason string `json:"dsr_override_reason"` // replaces the stored reason when set
+}
+
+type LoanSimulationRequest struct {
+	ProductID          string                    `json:"product_id"`
+	Amount             int64                     `json:"amount"`
+	InterestRate       float64                   `json:"interest_rate"`
+	InterestMethod     models.InterestMethod     `json:"interest_method"`
+	RepaymentFrequency models.RepaymentFrequency `json:"repayment_frequency"` // defaults to monthly
+	Term               int                       `json:"term"`
+}
+
+type LoanSimulation struct {
+	InterestMethod     models.InterestMethod     `json:"interest_method"`
+	RepaymentFrequency models.RepaymentFrequency `json:"repayment_frequency"`
+	InterestRate       float64                   `json:"interest_rate"`
+	EffectiveRate      float64                   `json:"effective_rate"`  // annuity-equivalent annual rate
+	MonthlyPayment     int64                     `json:"monthly_payment"` // first installment
+	TotalInterest      int64                     `json:"total_interest"`
+	TotalPayment       int64                     `json:"total_payment"`
+	AdminFee           int64                     `json:"admin_fee"`
+	ProvisionFee       int64                     `json:"provision_fee"`
+	NetDisbursement    int64                     `json:"net_disbursement"` // amount less fees
+	Schedule           []utils.AmortizationLine  `json:"schedule"`
 }
 
 type LoanListRequest struct {
@@ -48,12 +88,6 @@ type LoanListResponse struct {
 	Limit int           `json:"limit"`
 }
 
-type InstallmentPaymentRequest struct {
-	InstallmentID string `json:"installment_id"`
-	Amount        int64  `json:"amount"`
-	PaymentDate   time.Time `json:"payment_date"`
-}
-
 func (s *LoanService) CreateLoan(req LoanCreateRequest) (*models.Loan, error) {
 	// Validate customer exists
 	customerUUID, err := uuid.Parse(req.CustomerID)
@@ -70,29 +104,34 @@ func (s *LoanService) CreateLoan(req LoanCreateRequest) (*models.Loan, error) {
 		return nil, fmt.Errorf("failed to check customer: %v", err)
 	}
 
-	// Validate loan parameters
-	if req.Amount <= 0 {
-		return nil, fmt.Errorf("loan amount must be greater than 0")
-	}
-	if req.InterestRate <= 0 || req.InterestRate > 100 {
-		return nil, fmt.Errorf("interest rate must be between 0 and 100")
+	createdBy, err := parseOptionalUUID(req.CreatedBy, "user ID")
+	if err != nil {
+		return nil, err
 	}
-	if req.Term <= 0 || req.Term > 60 {
-		return nil, fmt.Errorf("loan term must be between 1 and 60 months")
+
+	if req.OtherObligations < 0 {
+		return nil, fmt.Errorf("other obligations must not be negative")
 	}
+	overrideReason := strings.TrimSpace(req.DSROverrideReason)
 
-	// Check if customer already has an active loan
-	var activeLoanCount int
-	err = s.db.QueryRow(`
-		SELECT COUNT(*) FROM loans 
-		WHERE customer_id = ? AND status IN ('pending', 'approved', 'disbursed', 'active')`,
-		customerUUID.String()).Scan(&activeLoanCount)
+	// Limits, pricing and eligibility come from the loan product
+	if req.ProductID == "" {
+		return nil, fmt.Errorf("loan product is required")
+	}
+	product, err := s.productService.GetLoanProduct(req.ProductID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check existing loans: %v", err)
+		return nil, err
 	}
-	if activeLoanCount > 0 {
-		return nil, fmt.Errorf("customer already has an active loan")
+	if req.InterestRate == 0 {
+		req.InterestRate = product.DefaultInterestRate
+	}
+
+	err = s.productService.ValidateApplication(product, customerUUID, req.Amount, req.InterestRate, req.Term, nil)
+	if err != nil {
+		return nil, err
 	}
+	interestMethod, frequency := product.InterestMethod, product.RepaymentFrequency
+	adminFee, provisionFee := LoanFees(product, req.Amount)
 
 	// Generate contract number
 	contractNumber, err := s.generateContractNumber()
@@ -100,36 +139,80 @@ func (s *LoanService) CreateLoan(req LoanCreateRequest) (*models.Loan, error) {
 		return nil, fmt.Errorf("failed to generate contract number: %v", err)
 	}
 
-	// Calculate monthly payment using PMT formula
-	monthlyPayment := s.calculateMonthlyPayment(req.Amount, req.InterestRate, req.Term)
+	// Calculate the installment and due date for the chosen interest method
+	monthlyPayment, dueDate, err := s.calculateInstallment(interestMethod, frequency, req.Amount, req.InterestRate, req.Term)
+	if err != nil {
+		return nil, err
+	}
+
+	// Repayments must stay affordable for the member
+	assessment, err := assessLoanAffordability(s.db, s.cfg.Affordability, customerUUID.String(),
+		frequency.MonthlyEquivalent(monthlyPayment), req.OtherObligations, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAffordability(assessment, overrideReason); err != nil {
+		return nil, err
+	}
 
-	// Calculate due date (term months from now)
-	dueDate := time.Now().AddDate(0, req.Term, 0)
+	creditScore, err := s.scoringService.ScoreCustomer(customerUUID.String())
+	if err != nil {
+		return nil, err
+	}
+	creditReasons, err := json.Marshal(creditScore.Reasons)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode credit score reasons: %v", err)
+	}
 
 	loan := &models.Loan{
-		ID:             uuid.New(),
-		CustomerID:     customerUUID,
-		ContractNumber: contractNumber,
-		Amount:         req.Amount,
-		InterestRate:   req.InterestRate,
-		Term:           req.Term,
-		MonthlyPayment: monthlyPayment,
-		Status:         models.LoanStatusPending,
-		DueDate:        dueDate,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
+		ID:                 uuid.New(),
+		CustomerID:         customerUUID,
+		ContractNumber:     contractNumber,
+		Amount:             req.Amount,
+		InterestRate:       req.InterestRate,
+		InterestMethod:     interestMethod,
+		Term:               req.Term,
+		MonthlyPayment:     monthlyPayment,
+		ProductID:          &product.ID,
+		RepaymentFrequency: frequency,
+		AdminFee:           adminFee,
+		ProvisionFee:       provisionFee,
+		Status:             models.LoanStatusPending,
+		DueDate:            dueDate,
+		CreatedBy:          createdBy,
+		OtherObligations:   req.OtherObligations,
+		DSROverrideReason:  overrideReason,
+		CreditScore:        creditScore,
+		CreatedAt:          time.Now(),
+		UpdatedAt:          time.Now(),
 	}
 
 	// Insert loan
-	_, err = s.db.Exec(`
-		INSERT INTO loans (id, customer_id, contract_number, amount, interest_rate, term, monthly_payment, status, due_date)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		loan.ID.String(), loan.CustomerID.String(), loan.ContractNumber,
-		loan.Amount, loan.InterestRate, loan.Term, loan.MonthlyPayment,
-		string(loan.Status), loan.DueDate)
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO loans (id, customer_id, contract_number, amount, interest_rate, interest_method, term,
+				repayment_frequency, monthly_payment,
+				product_id, admin_fee, provision_fee, status, due_date, created_by, other_obligations, dsr_override_reason,
+				credit_score, credit_grade, credit_reasons, credit_scored_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			loan.ID.String(), loan.CustomerID.String(), loan.ContractNumber,
+			loan.Amount, loan.InterestRate, string(loan.InterestMethod), loan.Term,
+			string(loan.RepaymentFrequency), loan.MonthlyPayment,
+			product.ID.String(), loan.AdminFee, loan.ProvisionFee,
+			string(loan.Status), loan.DueDate, nullableUUID(createdBy), loan.OtherObligations,
+			nullableString(loan.DSROverrideReason), creditScore.Score, creditScore.Grade, string(creditReasons),
+			creditScore.ScoredAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert loan: %v", err)
+		}
+
+		return recordLoanStatus(tx, loan.ID.String(), nil, loan.Status, createdBy, "")
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert loan: %v", err)
+		return nil, err
 	}
+	loan.Product = product
+	loan.Affordability = assessment
 
 	return loan, nil
 }
@@ -141,31 +224,55 @@ func (s *LoanService) GetLoan(id string) (*models.Loan, error) {
 	}
 
 	var loan models.Loan
-	var customerName string
+	var customerName, productCode, productName string
+	var score creditScoreColumns
+	var writeOff writeOffColumns
 
 	err = s.db.QueryRow(`
-		SELECT l.id, l.customer_id, l.contract_number, l.amount, l.interest_rate, l.term,
-		       l.monthly_payment, l.status, l.disbursed_at, l.due_date, l.created_at, l.updated_at,
-		       c.name as customer_name
+		SELECT l.id, l.customer_id, l.contract_number, l.amount, l.interest_rate, l.interest_method, l.term,
+		       l.repayment_frequency, l.monthly_payment, l.product_id, l.admin_fee, l.provision_fee,
+		       (SELECT COALESCE(SUM(credit_amount), 0) FROM payments WHERE loan_id = l.id),
+		       l.schedule_version, l.restructured,
+		       l.status, l.disbursed_at, l.settled_at, COALESCE(l.settlement_reason, ''), l.due_date,
+		       l.created_by, l.submitted_by, l.submitted_at, l.other_obligations, COALESCE(l.dsr_override_reason, ''),
+		       l.credit_score, l.credit_grade, l.credit_reasons, l.credit_scored_at,
+		       l.written_off_at, l.written_off_by, l.write_off_reason,
+		       l.written_off_principal, l.written_off_interest, l.written_off_penalty,
+		       (SELECT COALESCE(SUM(amount), 0) FROM loan_recoveries WHERE loan_id = l.id),
+		       l.created_at, l.updated_at,
+		       c.name as customer_name, COALESCE(p.code, ''), COALESCE(p.name, '')
 		FROM loans l
 		JOIN customers c ON l.customer_id = c.id
+		LEFT JOIN loan_products p ON l.product_id = p.id
 		WHERE l.id = ?`, loanID.String()).Scan(
 		&loan.ID, &loan.CustomerID, &loan.ContractNumber, &loan.Amount,
-		&loan.InterestRate, &loan.Term, &loan.MonthlyPayment, &loan.Status,
-		&loan.DisbursedAt, &loan.DueDate, &loan.CreatedAt, &loan.UpdatedAt,
-		&customerName)
+		&loan.InterestRate, &loan.InterestMethod, &loan.Term, &loan.RepaymentFrequency, &loan.MonthlyPayment,
+		&loan.ProductID, &loan.AdminFee, &loan.ProvisionFee, &loan.CreditBalance,
+		&loan.ScheduleVersion, &loan.Restructured, &loan.Status,
+		&loan.DisbursedAt, &loan.SettledAt, &loan.SettlementReason, &loan.DueDate,
+		&loan.CreatedBy, &loan.SubmittedBy, &loan.SubmittedAt, &loan.OtherObligations, &loan.DSROverrideReason,
+		&score.score, &score.grade, &score.reasons, &score.scoredAt,
+		&writeOff.at, &writeOff.by, &writeOff.reason, &writeOff.principal, &writeOff.interest, &writeOff.penalty,
+		&writeOff.recovered,
+		&loan.CreatedAt, &loan.UpdatedAt,
+		&customerName, &productCode, &productName)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("loan not found")
 		}
 		return nil, fmt.Errorf("failed to get loan: %v", err)
 	}
+	loan.CreditScore = score.creditScore()
+	loan.WriteOff = writeOff.writeOff()
 
-	// Set customer info
+	// Set customer and product info
 	loan.Customer = &models.Customer{
 		ID:   loan.CustomerID,
 		Name: customerName,
 	}
+	if loan.ProductID != nil {
+		loan.Product = &models.LoanProduct{ID: *loan.ProductID, Code: productCode, Name: productName}
+	}
 
 	// Get installments
 	installments, err := s.getInstallmentsByLoanID(loan.ID.String())
@@ -174,6 +281,22 @@ func (s *LoanService) GetLoan(id string) (*models.Loan, error) {
 	}
 	loan.Installments = installments
 
+	approvals, err := loanApprovals(s.db, loan.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	loan.Approvals = approvals
+
+	// Checkers see the affordability check the approval will run
+	if loan.Status == models.LoanStatusPending {
+		loan.Affordability, err = assessLoanAffordability(s.db, s.cfg.Affordability, loan.CustomerID.String(),
+			loan.RepaymentFrequency.MonthlyEquivalent(loan.MonthlyPayment), loan.OtherObligations, loan.ID.String())
+		if err != nil {
+			return nil, err
+		}
+		loan.Affordability.OverrideReason = loan.DSROverrideReason
+	}
+
 	return &loan, nil
 }
 
@@ -183,20 +306,30 @@ func (s *LoanService) UpdateLoan(id string, req LoanUpdateRequest) (*models.Loan
 		return nil, fmt.Errorf("invalid loan ID")
 	}
 
-	// Validate loan parameters
-	if req.Amount <= 0 {
-		return nil, fmt.Errorf("loan amount must be greater than 0")
-	}
-	if req.InterestRate <= 0 || req.InterestRate > 100 {
-		return nil, fmt.Errorf("interest rate must be between 0 and 100")
-	}
-	if req.Term <= 0 || req.Term > 60 {
-		return nil, fmt.Errorf("loan term must be between 1 and 60 months")
+	updatedBy, err := parseOptionalUUID(req.UpdatedBy, "user ID")
+	if err != nil {
+		return nil, err
 	}
+	reason := strings.TrimSpace(req.StatusReason)
 
 	// Check if loan exists
-	var existingStatus string
-	err = s.db.QueryRow("SELECT status FROM loans WHERE id = ?", loanID.String()).Scan(&existingStatus)
+	var existingStatus models.LoanStatus
+	var customerID uuid.UUID
+	var productID sql.NullString
+	var existingAmount int64
+	var existingRate float64
+	var existingMethod models.InterestMethod
+	var frequency models.RepaymentFrequency
+	var existingTerm int
+	var submitted bool
+	var otherObligations int64
+	var overrideReason string
+	err = s.db.QueryRow(`
+		SELECT status, customer_id, product_id, amount, interest_rate, interest_method, repayment_frequency, term,
+		       submitted_at IS NOT NULL, other_obligations, COALESCE(dsr_override_reason, '')
+		FROM loans WHERE id = ?`, loanID.String()).
+		Scan(&existingStatus, &customerID, &productID, &existingAmount, &existingRate, &existingMethod, &frequency, &existingTerm,
+			&submitted, &otherObligations, &overrideReason)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("loan not found")
@@ -204,25 +337,98 @@ func (s *LoanService) UpdateLoan(id string, req LoanUpdateRequest) (*models.Loan
 		return nil, fmt.Errorf("failed to check loan existence: %v", err)
 	}
 
-	// Don't allow updates to disbursed or active loans
-	if existingStatus == string(models.LoanStatusDisbursed) || existingStatus == string(models.LoanStatusActive) {
-		return nil, fmt.Errorf("cannot update disbursed or active loans")
+	if req.Status == "" {
+		req.Status = existingStatus
+	}
+	if req.Status != existingStatus {
+		if err := checkManualTransition(existingStatus, req.Status, reason); err != nil {
+			return nil, err
+		}
+	}
+
+	// Terms are fixed once the loan is submitted for approval, so checkers
+	// always decide on what gets disbursed; only its status can change
+	if existingStatus != models.LoanStatusPending || submitted {
+		if req.Amount != existingAmount || req.InterestRate != existingRate || req.Term != existingTerm ||
+			(req.InterestMethod != "" && req.InterestMethod != existingMethod) {
+			return nil, fmt.Errorf("loan terms can only be changed before the loan is submitted for approval")
+		}
+
+		err = s.db.WithTx(func(tx *sql.Tx) error {
+			return transitionLoanStatus(tx, loanID.String(), req.Status, updatedBy, reason)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return s.GetLoan(id)
+	}
+
+	// Validate loan parameters against the product; loans created before
+	// products existed keep the old fixed limits and their frequency
+	var interestMethod models.InterestMethod
+	var adminFee, provisionFee int64
+	if productID.Valid {
+		product, err := s.productService.GetLoanProduct(productID.String)
+		if err != nil {
+			return nil, err
+		}
+		err = s.productService.ValidateApplication(product, customerID, req.Amount, req.InterestRate, req.Term, &loanID)
+		if err != nil {
+			return nil, err
+		}
+		interestMethod, frequency = product.InterestMethod, product.RepaymentFrequency
+		adminFee, provisionFee = LoanFees(product, req.Amount)
+	} else {
+		if req.Amount <= 0 {
+			return nil, fmt.Errorf("loan amount must be greater than 0")
+		}
+		if req.InterestRate <= 0 || req.InterestRate > 100 {
+			return nil, fmt.Errorf("interest rate must be between 0 and 100")
+		}
+		if maxTerm := 5 * frequency.PeriodsPerYear(); req.Term <= 0 || req.Term > maxTerm {
+			return nil, fmt.Errorf("loan term must be between 1 and %d %s installments", maxTerm, frequency)
+		}
+		interestMethod, err = parseInterestMethod(req.InterestMethod)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Calculate new monthly payment
-	monthlyPayment := s.calculateMonthlyPayment(req.Amount, req.InterestRate, req.Term)
+	// Calculate new installment and due date
+	monthlyPayment, dueDate, err := s.calculateInstallment(interestMethod, frequency, req.Amount, req.InterestRate, req.Term)
+	if err != nil {
+		return nil, err
+	}
 
-	// Calculate new due date
-	dueDate := time.Now().AddDate(0, req.Term, 0)
+	if override := strings.TrimSpace(req.DSROverrideReason); override != "" {
+		overrideReason = override
+	}
+	assessment, err := assessLoanAffordability(s.db, s.cfg.Affordability, customerID.String(),
+		frequency.MonthlyEquivalent(monthlyPayment), otherObligations, loanID.String())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAffordability(assessment, overrideReason); err != nil {
+		return nil, err
+	}
 
 	// Update loan
-	_, err = s.db.Exec(`
-		UPDATE loans 
-		SET amount = ?, interest_rate = ?, term = ?, monthly_payment = ?, status = ?, due_date = ?
-		WHERE id = ?`,
-		req.Amount, req.InterestRate, req.Term, monthlyPayment, string(req.Status), dueDate, loanID.String())
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE loans 
+			SET amount = ?, interest_rate = ?, interest_method = ?, repayment_frequency = ?, term = ?, monthly_payment = ?,
+			    admin_fee = ?, provision_fee = ?, due_date = ?, dsr_override_reason = ?
+			WHERE id = ?`,
+			req.Amount, req.InterestRate, string(interestMethod), string(frequency), req.Term, monthlyPayment,
+			adminFee, provisionFee, dueDate, nullableString(overrideReason), loanID.String())
+		if err != nil {
+			return fmt.Errorf("failed to update loan: %v", err)
+		}
+
+		return transitionLoanStatus(tx, loanID.String(), req.Status, updatedBy, reason)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update loan: %v", err)
+		return nil, err
 	}
 
 	return s.GetLoan(id)
@@ -244,11 +450,21 @@ func (s *LoanService) ListLoans(req LoanListRequest) (*LoanListResponse, error)
 	args := make([]interface{}, 0)
 
 	queryBuilder.WriteString(`
-		SELECT l.id, l.customer_id, l.contract_number, l.amount, l.interest_rate, l.term,
-		       l.monthly_payment, l.status, l.disbursed_at, l.due_date, l.created_at, l.updated_at,
-		       c.name as customer_name
+		SELECT l.id, l.customer_id, l.contract_number, l.amount, l.interest_rate, l.interest_method, l.term,
+		       l.repayment_frequency, l.monthly_payment, l.product_id, l.admin_fee, l.provision_fee,
+		       (SELECT COALESCE(SUM(credit_amount), 0) FROM payments WHERE loan_id = l.id),
+		       l.schedule_version, l.restructured,
+		       l.status, l.disbursed_at, l.settled_at, COALESCE(l.settlement_reason, ''), l.due_date,
+		       l.created_by, l.submitted_by, l.submitted_at, l.other_obligations, COALESCE(l.dsr_override_reason, ''),
+		       l.credit_score, l.credit_grade, l.credit_reasons, l.credit_scored_at,
+		       l.written_off_at, l.written_off_by, l.write_off_reason,
+		       l.written_off_principal, l.written_off_interest, l.written_off_penalty,
+		       (SELECT COALESCE(SUM(amount), 0) FROM loan_recoveries WHERE loan_id = l.id),
+		       l.created_at, l.updated_at,
+		       c.name as customer_name, COALESCE(p.code, ''), COALESCE(p.name, '')
 		FROM loans l
 		JOIN customers c ON l.customer_id = c.id
+		LEFT JOIN loan_products p ON l.product_id = p.id
 		WHERE 1=1`)
 
 	// Add customer filter
@@ -277,22 +493,36 @@ func (s *LoanService) ListLoans(req LoanListRequest) (*LoanListResponse, error)
 	loans := make([]models.Loan, 0)
 	for rows.Next() {
 		var loan models.Loan
-		var customerName string
+		var customerName, productCode, productName string
+		var score creditScoreColumns
+		var writeOff writeOffColumns
 
 		err := rows.Scan(
 			&loan.ID, &loan.CustomerID, &loan.ContractNumber, &loan.Amount,
-			&loan.InterestRate, &loan.Term, &loan.MonthlyPayment, &loan.Status,
-			&loan.DisbursedAt, &loan.DueDate, &loan.CreatedAt, &loan.UpdatedAt,
-			&customerName)
+			&loan.InterestRate, &loan.InterestMethod, &loan.Term, &loan.RepaymentFrequency, &loan.MonthlyPayment,
+			&loan.ProductID, &loan.AdminFee, &loan.ProvisionFee, &loan.CreditBalance,
+			&loan.ScheduleVersion, &loan.Restructured, &loan.Status,
+			&loan.DisbursedAt, &loan.SettledAt, &loan.SettlementReason, &loan.DueDate,
+			&loan.CreatedBy, &loan.SubmittedBy, &loan.SubmittedAt, &loan.OtherObligations, &loan.DSROverrideReason,
+			&score.score, &score.grade, &score.reasons, &score.scoredAt,
+			&writeOff.at, &writeOff.by, &writeOff.reason, &writeOff.principal, &writeOff.interest, &writeOff.penalty,
+			&writeOff.recovered,
+			&loan.CreatedAt, &loan.UpdatedAt,
+			&customerName, &productCode, &productName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan loan: %v", err)
 		}
+		loan.CreditScore = score.creditScore()
+		loan.WriteOff = writeOff.writeOff()
 
-		// Set customer info
+		// Set customer and product info
 		loan.Customer = &models.Customer{
 			ID:   loan.CustomerID,
 			Name: customerName,
 		}
+		if loan.ProductID != nil {
+			loan.Product = &models.LoanProduct{ID: *loan.ProductID, Code: productCode, Name: productName}
+		}
 
 		loans = append(loans, loan)
 	}
@@ -327,17 +557,27 @@ func (s *LoanService) ListLoans(req LoanListRequest) (*LoanListResponse, error)
 	}, nil
 }
 
-func (s *LoanService) DisburseLoan(id string) (*models.Loan, error) {
+func (s *LoanService) DisburseLoan(id, userID string) (*models.Loan, error) {
 	loanID, err := uuid.Parse(id)
 	if err != nil {
 		return nil, fmt.Errorf("invalid loan ID")
 	}
 
+	disbursedBy, err := parseOptionalUUID(userID, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
 	// Check loan status
 	var currentStatus string
 	var amount int64
+	var interestRate float64
+	var interestMethod models.InterestMethod
+	var frequency models.RepaymentFrequency
 	var term int
-	err = s.db.QueryRow("SELECT status, amount, term FROM loans WHERE id = ?", loanID.String()).Scan(&currentStatus, &amount, &term)
+	var contractNumber string
+	var adminFee, provisionFee int64
+	err = s.db.QueryRow("SELECT status, amount, interest_rate, interest_method, repayment_frequency, term, contract_number, admin_fee, provision_fee FROM loans WHERE id = ?", loanID.String()).Scan(&currentStatus, &amount, &interestRate, &interestMethod, &frequency, &term, &contractNumber, &adminFee, &provisionFee)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("loan not found")
@@ -358,20 +598,31 @@ func (s *LoanService) DisburseLoan(id string) (*models.Loan, error) {
 
 	// Update loan status
 	now := time.Now()
-	_, err = tx.Exec(`
-		UPDATE loans 
-		SET status = ?, disbursed_at = ?
-		WHERE id = ?`,
-		string(models.LoanStatusDisbursed), now, loanID.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to update loan status: %v", err)
+	if err := transitionLoanStatus(tx, loanID.String(), models.LoanStatusDisbursed, disbursedBy, ""); err != nil {
+		return nil, err
 	}
 
-	// Create installments
-	err = s.createInstallments(tx, loanID, amount, term, now)
+	// Create installments; the loan is due with the last of them
+	dueDate, err := s.createInstallments(tx, loanID, interestMethod, frequency, amount, interestRate, term, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create installments: %v", err)
 	}
+	_, err = tx.Exec("UPDATE loans SET disbursed_at = ?, due_date = ? WHERE id = ?", now, dueDate, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update loan: %v", err)
+	}
+
+	// Book the disbursement and the fees deducted from it
+	entry := accounting.Disbursement(models.Loan{
+		ID:             loanID,
+		ContractNumber: contractNumber,
+		Amount:         amount,
+		AdminFee:       adminFee,
+		ProvisionFee:   provisionFee,
+	}, now, disbursedBy)
+	if err := postJournalEntry(tx, &entry); err != nil {
+		return nil, err
+	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %v", err)
@@ -380,222 +631,1403 @@ func (s *LoanService) DisburseLoan(id string) (*models.Loan, error) {
 	return s.GetLoan(id)
 }
 
-func (s *LoanService) PayInstallment(req InstallmentPaymentRequest) (*models.LoanInstallment, error) {
-	installmentID, err := uuid.Parse(req.InstallmentID)
-	if err != nil {
-		return nil, fmt.Errorf("invalid installment ID")
+// loanTransitions lists the statuses a loan may move to from each status.
+// Cancelled and written off are final, and a completed loan only reopens
+// when a payment reversal leaves an installment unpaid.
+var loanTransitions = map[models.LoanStatus][]models.LoanStatus{
+	models.LoanStatusPending:   {models.LoanStatusApproved, models.LoanStatusCancelled},
+	models.LoanStatusApproved:  {models.LoanStatusDisbursed, models.LoanStatusCancelled},
+	models.LoanStatusDisbursed: {models.LoanStatusActive, models.LoanStatusCompleted, models.LoanStatusDefaulted},
+	models.LoanStatusActive:    {models.LoanStatusCompleted, models.LoanStatusDefaulted, models.LoanStatusWrittenOff},
+	models.LoanStatusDefaulted: {models.LoanStatusActive, models.LoanStatusCompleted, models.LoanStatusWrittenOff},
+	models.LoanStatusCompleted: {models.LoanStatusActive},
+}
+
+// CanTransitionLoan reports whether a loan may move from one status to another
+func CanTransitionLoan(from, to models.LoanStatus) bool {
+	for _, next := range loanTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkManualTransition applies the extra rules for status changes made by
+// hand through UpdateLoan. Approval, disbursement and completion have their
+// own flows so that approvals, installments and the payment ledger stay
+// consistent.
+func checkManualTransition(from, to models.LoanStatus, reason string) error {
+	switch {
+	case to == models.LoanStatusApproved:
+		return fmt.Errorf("loans must be approved through the approval workflow")
+	case to == models.LoanStatusDisbursed:
+		return fmt.Errorf("loans must be disbursed through the disbursement flow")
+	case to == models.LoanStatusCompleted:
+		return fmt.Errorf("loans are completed by paying them off or settling early")
+	case to == models.LoanStatusWrittenOff:
+		return fmt.Errorf("loans must be written off through the write-off flow")
+	case from == models.LoanStatusCompleted:
+		return fmt.Errorf("completed loans cannot be changed")
+	case (to == models.LoanStatusCancelled || to == models.LoanStatusDefaulted) && reason == "":
+		return fmt.Errorf("a reason is required to mark a loan as %s", to)
 	}
 
-	if req.Amount <= 0 {
-		return nil, fmt.Errorf("payment amount must be greater than 0")
+	if !CanTransitionLoan(from, to) {
+		return fmt.Errorf("loan cannot move from %s to %s", from, to)
 	}
+	return nil
+}
 
-	// Get installment details
-	var installment models.LoanInstallment
-	var currentAmountPaid int64
-	err = s.db.QueryRow(`
-		SELECT id, loan_id, number, due_date, amount_due, amount_paid, status, paid_at, dpd
-		FROM loan_installments 
-		WHERE id = ?`, installmentID.String()).Scan(
-		&installment.ID, &installment.LoanID, &installment.Number,
-		&installment.DueDate, &installment.AmountDue, &currentAmountPaid,
-		&installment.Status, &installment.PaidAt, &installment.DPD)
+// transitionLoanStatus moves a loan to a new status and records the change in
+// its history. Moving a loan to the status it already has does nothing. actor
+// is nil for changes made by the system.
+func transitionLoanStatus(tx *sql.Tx, loanID string, to models.LoanStatus, actor *uuid.UUID, reason string) error {
+	var from models.LoanStatus
+	err := tx.QueryRow("SELECT status FROM loans WHERE id = ?", loanID).Scan(&from)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("installment not found")
+			return fmt.Errorf("loan not found")
 		}
-		return nil, fmt.Errorf("failed to get installment: %v", err)
+		return fmt.Errorf("failed to get loan status: %v", err)
 	}
 
-	// Calculate new amount paid
-	newAmountPaid := currentAmountPaid + req.Amount
-	
-	// Determine new status
-	var newStatus models.LoanInstallmentStatus
-	var paidAt *time.Time
-	
-	if newAmountPaid >= installment.AmountDue {
-		newStatus = models.InstallmentStatusPaid
-		paidAt = &req.PaymentDate
-	} else {
-		newStatus = models.InstallmentStatusPartial
+	if from == to {
+		return nil
 	}
-
-	// Update installment
-	_, err = s.db.Exec(`
-		UPDATE loan_installments 
-		SET amount_paid = ?, status = ?, paid_at = ?, dpd = 0
-		WHERE id = ?`,
-		newAmountPaid, string(newStatus), paidAt, installmentID.String())
-	if err != nil {
-		return nil, fmt.Errorf("failed to update installment: %v", err)
+	if !CanTransitionLoan(from, to) {
+		return fmt.Errorf("loan cannot move from %s to %s", from, to)
 	}
 
-	// If all installments are paid, update loan status
-	if newStatus == models.InstallmentStatusPaid {
-		var unpaidCount int
-		err = s.db.QueryRow(`
-			SELECT COUNT(*) FROM loan_installments 
-			WHERE loan_id = ? AND status != 'paid'`,
-			installment.LoanID.String()).Scan(&unpaidCount)
-		if err == nil && unpaidCount == 0 {
-			_, err = s.db.Exec(`
-				UPDATE loans SET status = ? WHERE id = ?`,
-				string(models.LoanStatusCompleted), installment.LoanID.String())
-		}
+	_, err = tx.Exec("UPDATE loans SET status = ? WHERE id = ?", string(to), loanID)
+	if err != nil {
+		return fmt.Errorf("failed to update loan status: %v", err)
 	}
 
-	// Return updated installment
-	installment.AmountPaid = newAmountPaid
-	installment.Status = newStatus
-	installment.PaidAt = paidAt
-	installment.DPD = 0
-
-	return &installment, nil
+	return recordLoanStatus(tx, loanID, &from, to, actor, reason)
 }
 
-// Helper functions
+func recordLoanStatus(tx *sql.Tx, loanID string, from *models.LoanStatus, to models.LoanStatus, actor *uuid.UUID, reason string) error {
+	var fromStatus *string
+	if from != nil {
+		value := string(*from)
+		fromStatus = &value
+	}
 
-func (s *LoanService) calculateMonthlyPayment(amount int64, interestRate float64, term int) int64 {
-	// Convert to float for calculation
-	principal := float64(amount)
-	monthlyRate := interestRate / 100 / 12
-	numPayments := float64(term)
+	_, err := tx.Exec(`
+		INSERT INTO loan_status_history (id, loan_id, from_status, to_status, changed_by, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		uuid.New().String(), loanID, fromStatus, string(to), nullableUUID(actor), nullableString(reason), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record loan status: %v", err)
+	}
+	return nil
+}
 
-	// PMT formula: PMT = P * [r(1+r)^n] / [(1+r)^n - 1]
-	if monthlyRate == 0 {
-		return int64(principal / numPayments)
+// GetLoanTimeline returns the status history of a loan, oldest first
+func (s *LoanService) GetLoanTimeline(loanID string) ([]models.LoanStatusChange, error) {
+	id, err := uuid.Parse(loanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
 	}
 
-	monthlyPayment := principal * (monthlyRate * math.Pow(1+monthlyRate, numPayments)) / (math.Pow(1+monthlyRate, numPayments) - 1)
-	
-	return int64(math.Round(monthlyPayment))
-}
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM loans WHERE id = ?)", id.String()).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check loan: %v", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("loan not found")
+	}
 
-func (s *LoanService) generateContractNumber() (string, error) {
-	year := time.Now().Year()
-	
-	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM loans WHERE strftime('%Y', created_at) = ?", fmt.Sprintf("%d", year)).Scan(&count)
+	rows, err := s.db.Query(`
+		SELECT h.id, h.loan_id, h.from_status, h.to_status, h.changed_by, COALESCE(u.name, ''),
+		       COALESCE(h.reason, ''), h.created_at
+		FROM loan_status_history h
+		LEFT JOIN users u ON h.changed_by = u.id
+		WHERE h.loan_id = ?
+		ORDER BY h.created_at, h.rowid`, id.String())
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("failed to query loan timeline: %v", err)
 	}
-	
-	return fmt.Sprintf("KOP-%d-%04d", year, count+1), nil
-}
+	defer rows.Close()
 
-func (s *LoanService) createInstallments(tx *sql.Tx, loanID uuid.UUID, amount int64, term int, disbursedAt time.Time) error {
-	monthlyPayment := s.calculateMonthlyPayment(amount, 12.0, term) // Example rate for calculation
-	
-	for i := 1; i <= term; i++ {
-		dueDate := disbursedAt.AddDate(0, i, 0)
-		
-		installment := models.LoanInstallment{
-			ID:         uuid.New(),
-			LoanID:     loanID,
-			Number:     i,
-			DueDate:    dueDate,
-			AmountDue:  monthlyPayment,
-			AmountPaid: 0,
-			Status:     models.InstallmentStatusPending,
-			DPD:        0,
-			CreatedAt:  time.Now(),
-			UpdatedAt:  time.Now(),
-		}
-		
-		_, err := tx.Exec(`
-			INSERT INTO loan_installments (id, loan_id, number, due_date, amount_due, amount_paid, status, dpd)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
-			installment.ID.String(), installment.LoanID.String(), installment.Number,
-			installment.DueDate, installment.AmountDue, installment.AmountPaid,
-			string(installment.Status), installment.DPD)
+	timeline := make([]models.LoanStatusChange, 0)
+	for rows.Next() {
+		var change models.LoanStatusChange
+		var fromStatus, changedBy sql.NullString
+		err := rows.Scan(&change.ID, &change.LoanID, &fromStatus, &change.ToStatus, &changedBy, &change.ChangedByName,
+			&change.Reason, &change.CreatedAt)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed to scan loan timeline: %v", err)
+		}
+		if fromStatus.Valid {
+			status := models.LoanStatus(fromStatus.String)
+			change.FromStatus = &status
 		}
+		change.ChangedBy = uuidFromNull(changedBy)
+		timeline = append(timeline, change)
 	}
-	
-	return nil
+
+	return timeline, rows.Err()
 }
 
-func (s *LoanService) getInstallmentsByLoanID(loanID string) ([]models.LoanInstallment, error) {
+// LoanStatusUpdate is a status change made automatically by a scheduled job
+type LoanStatusUpdate struct {
+	LoanID         string            `json:"loan_id"`
+	ContractNumber string            `json:"contract_number"`
+	From           models.LoanStatus `json:"from"`
+	To             models.LoanStatus `json:"to"`
+	Reason         string            `json:"reason"`
+}
+
+// ActivateDisbursedLoans moves disbursed loans to active once the period of
+// their first installment has started, i.e. on the day repayment begins to
+// accrue.
+func (s *LoanService) ActivateDisbursedLoans(asOf time.Time) ([]LoanStatusUpdate, error) {
 	rows, err := s.db.Query(`
-		SELECT id, loan_id, number, due_date, amount_due, amount_paid, status, paid_at, dpd, created_at, updated_at
-		FROM loan_installments 
-		WHERE loan_id = ? 
-		ORDER BY number`, loanID)
+		SELECT id, contract_number
+		FROM loans
+		WHERE status = 'disbursed' AND date(disbursed_at) <= date(?)`, asOf.Format("2006-01-02"))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to query disbursed loans: %v", err)
 	}
 	defer rows.Close()
 
-	installments := make([]models.LoanInstallment, 0)
+	updates := make([]LoanStatusUpdate, 0)
 	for rows.Next() {
-		var installment models.LoanInstallment
-		
-		err := rows.Scan(
-			&installment.ID, &installment.LoanID, &installment.Number,
-			&installment.DueDate, &installment.AmountDue, &installment.AmountPaid,
-			&installment.Status, &installment.PaidAt, &installment.DPD,
-			&installment.CreatedAt, &installment.UpdatedAt)
-		if err != nil {
-			return nil, err
+		update := LoanStatusUpdate{From: models.LoanStatusDisbursed, To: models.LoanStatusActive, Reason: "repayment period started"}
+		if err := rows.Scan(&update.LoanID, &update.ContractNumber); err != nil {
+			return nil, fmt.Errorf("failed to scan loan: %v", err)
 		}
-		
-		installments = append(installments, installment)
+		updates = append(updates, update)
 	}
-	
-	return installments, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return updates, s.applyStatusUpdates(updates)
 }
 
-func (s *LoanService) GetOverdueInstallments() ([]models.LoanInstallment, error) {
+// DefaultDelinquentLoans marks running loans as defaulted once their oldest
+// unpaid installment is more than dpdThreshold days past due. A threshold of
+// 0 disables it.
+func (s *LoanService) DefaultDelinquentLoans(dpdThreshold int, asOf time.Time) ([]LoanStatusUpdate, error) {
+	if dpdThreshold <= 0 {
+		return []LoanStatusUpdate{}, nil
+	}
+
+	today := dateOf(asOf)
+	cutoff := today.AddDate(0, 0, -dpdThreshold)
 	rows, err := s.db.Query(`
-		SELECT li.id, li.loan_id, li.number, li.due_date, li.amount_due, li.amount_paid, 
-		       li.status, li.paid_at, li.dpd, li.created_at, li.updated_at,
-		       l.contract_number, c.name as customer_name
-		FROM loan_installments li
-		JOIN loans l ON li.loan_id = l.id
-		JOIN customers c ON l.customer_id = c.id
-		WHERE li.due_date < date('now') AND li.status != 'paid'
-		ORDER BY li.due_date ASC`)
+		SELECT l.id, l.contract_number, l.status, MIN(li.due_date)
+		FROM loans l
+		JOIN loan_installments li ON li.loan_id = l.id
+		WHERE l.status IN ('disbursed', 'active')
+		  AND li.status != 'paid' AND li.settled_at IS NULL AND li.superseded_at IS NULL
+		  AND date(li.due_date) < date(?)
+		GROUP BY l.id, l.contract_number, l.status`, cutoff.Format("2006-01-02"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to query overdue installments: %v", err)
+		return nil, fmt.Errorf("failed to query delinquent loans: %v", err)
 	}
 	defer rows.Close()
 
-	installments := make([]models.LoanInstallment, 0)
+	updates := make([]LoanStatusUpdate, 0)
 	for rows.Next() {
-		var installment models.LoanInstallment
-		var contractNumber, customerName string
+		update := LoanStatusUpdate{To: models.LoanStatusDefaulted}
+		var oldestDue string
+		if err := rows.Scan(&update.LoanID, &update.ContractNumber, &update.From, &oldestDue); err != nil {
+			return nil, fmt.Errorf("failed to scan loan: %v", err)
+		}
 
-		err := rows.Scan(
-			&installment.ID, &installment.LoanID, &installment.Number,
-			&installment.DueDate, &installment.AmountDue, &installment.AmountPaid,
-			&installment.Status, &installment.PaidAt, &installment.DPD,
-			&installment.CreatedAt, &installment.UpdatedAt,
-			&contractNumber, &customerName)
+		// MIN() loses the column's date type, so the value comes back as text
+		due, err := time.Parse("2006-01-02", oldestDue[:10])
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan installment: %v", err)
+			return nil, fmt.Errorf("failed to parse due date %q: %v", oldestDue, err)
 		}
+		dpd := int(today.Sub(dateOf(due)).Hours() / 24)
+		update.Reason = fmt.Sprintf("%d days past due", dpd)
+		updates = append(updates, update)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		// Calculate DPD (Days Past Due)
-		if installment.DueDate.Before(time.Now()) {
-			installment.DPD = int(time.Since(installment.DueDate).Hours() / 24)
+	return updates, s.applyStatusUpdates(updates)
+}
+
+// applyStatusUpdates makes the system status changes in one transaction
+func (s *LoanService) applyStatusUpdates(updates []LoanStatusUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	return s.db.WithTx(func(tx *sql.Tx) error {
+		for _, update := range updates {
+			if err := transitionLoanStatus(tx, update.LoanID, update.To, nil, update.Reason); err != nil {
+				return fmt.Errorf("failed to update loan %s: %v", update.ContractNumber, err)
+			}
 		}
+		return nil
+	})
+}
 
-		// Update DPD in database
-		s.db.Exec("UPDATE loan_installments SET dpd = ? WHERE id = ?", installment.DPD, installment.ID.String())
+// creditScoreColumns scans the credit score stored on a loan, which is
+// missing for loans created before scoring existed
+type creditScoreColumns struct {
+	score    sql.NullInt64
+	grade    sql.NullString
+	reasons  sql.NullString
+	scoredAt *time.Time
+}
 
-		// Set loan and customer info
-		installment.Loan = &models.Loan{
-			ID:             installment.LoanID,
-			ContractNumber: contractNumber,
+func (c creditScoreColumns) creditScore() *models.CreditScore {
+	if !c.score.Valid {
+		return nil
+	}
+	score := &models.CreditScore{Score: int(c.score.Int64), Grade: c.grade.String, Reasons: []string{}}
+	if c.scoredAt != nil {
+		score.ScoredAt = *c.scoredAt
+	}
+	json.Unmarshal([]byte(c.reasons.String), &score.Reasons)
+	return score
+}
+
+// writeOffColumns scans the write-off of a loan, which is missing until the
+// loan is written off
+type writeOffColumns struct {
+	at        *time.Time
+	by        sql.NullString
+	reason    sql.NullString
+	principal int64
+	interest  int64
+	penalty   int64
+	recovered int64
+}
+
+func (c writeOffColumns) writeOff() *models.LoanWriteOff {
+	if c.at == nil {
+		return nil
+	}
+	return &models.LoanWriteOff{
+		WrittenOffAt: *c.at,
+		WrittenOffBy: uuidFromNull(c.by),
+		Reason:       c.reason.String,
+		Principal:    c.principal,
+		Interest:     c.interest,
+		Penalty:      c.penalty,
+		Total:        c.principal + c.interest + c.penalty,
+		Recovered:    c.recovered,
+	}
+}
+
+// AffordabilityError is returned when a loan's repayment-to-income ratio is
+// above the configured maximum and no override reason was given. It carries
+// the calculation so staff can explain the decision to the member.
+type AffordabilityError struct {
+	Assessment models.AffordabilityAssessment
+}
+
+func (e *AffordabilityError) Error() string {
+	if e.Assessment.MonthlyIncome <= 0 {
+		return "member has no monthly income recorded; an override reason is required"
+	}
+	return fmt.Sprintf("repayment-to-income ratio of %.2f%% is above the maximum of %.2f%%; an override reason is required",
+		e.Assessment.DSRPercent, e.Assessment.MaxDSRPercent)
+}
+
+// AssessAffordability computes the debt service ratio: all monthly
+// installments including the new one as percent of monthly income. A
+// maxDSRPercent of 0 disables the limit.
+func AssessAffordability(income, monthlyPayment, existingInstallments, otherObligations int64, maxDSRPercent float64) models.AffordabilityAssessment {
+	assessment := models.AffordabilityAssessment{
+		MonthlyIncome:        income,
+		MonthlyPayment:       monthlyPayment,
+		ExistingInstallments: existingInstallments,
+		OtherObligations:     otherObligations,
+		TotalObligations:     monthlyPayment + existingInstallments + otherObligations,
+		MaxDSRPercent:        maxDSRPercent,
+	}
+	if income > 0 {
+		assessment.DSRPercent = math.Round(float64(assessment.TotalObligations)*10000/float64(income)) / 100
+	}
+	if maxDSRPercent > 0 {
+		assessment.Exceeded = income <= 0 || float64(assessment.TotalObligations)*100 > maxDSRPercent*float64(income)
+	}
+	return assessment
+}
+
+// assessLoanAffordability runs AssessAffordability with the member's income
+// and the installments of their other open loans. monthlyPayment and the
+// other installments are per month, whatever the loans' frequency.
+// excludeLoanID is the loan being assessed once it exists.
+func assessLoanAffordability(q querier, rules config.AffordabilityConfig, customerID string, monthlyPayment, otherObligations int64, excludeLoanID string) (*models.AffordabilityAssessment, error) {
+	var income int64
+	err := q.QueryRow("SELECT monthly_income FROM customers WHERE id = ?", customerID).Scan(&income)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("customer not found")
 		}
-		installment.Loan.Customer = &models.Customer{
-			Name: customerName,
+		return nil, fmt.Errorf("failed to get customer income: %v", err)
+	}
+
+	rows, err := q.Query(`
+		SELECT monthly_payment, repayment_frequency FROM loans
+		WHERE customer_id = ? AND id != ? AND status IN ('pending', 'approved', 'disbursed', 'active', 'defaulted')`,
+		customerID, excludeLoanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum existing installments: %v", err)
+	}
+	defer rows.Close()
+
+	var existing int64
+	for rows.Next() {
+		var installment int64
+		var frequency models.RepaymentFrequency
+		if err := rows.Scan(&installment, &frequency); err != nil {
+			return nil, fmt.Errorf("failed to sum existing installments: %v", err)
 		}
+		existing += frequency.MonthlyEquivalent(installment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to sum existing installments: %v", err)
+	}
 
-		installments = append(installments, installment)
+	assessment := AssessAffordability(income, monthlyPayment, existing, otherObligations, rules.MaxDSRPercent)
+	return &assessment, nil
+}
+
+// checkAffordability records the override on the assessment and rejects an
+// unaffordable loan that has none.
+func checkAffordability(assessment *models.AffordabilityAssessment, overrideReason string) error {
+	assessment.OverrideReason = overrideReason
+	if assessment.Exceeded && overrideReason == "" {
+		return &AffordabilityError{Assessment: *assessment}
 	}
+	return nil
+}
 
-	return installments, nil
-}
\ No newline at end of file
+// CalculatePenalty returns the penalty for an overdue amount left unpaid for
+// the given number of days, before any cap.
+func CalculatePenalty(rules config.PenaltyConfig, overdueAmount int64, days int) int64 {
+	if days <= 0 || overdueAmount <= 0 {
+		return 0
+	}
+
+	switch rules.Type {
+	case config.PenaltyTypePercentage:
+		return int64(math.Round(float64(overdueAmount) * rules.DailyRate / 100 * float64(days)))
+	case config.PenaltyTypeFixed:
+		return rules.DailyAmount * int64(days)
+	}
+	return 0
+}
+
+// AccruePenalties charges late payment penalties on overdue installments of
+// disbursed loans for each day up to asOf. Days already charged are
+// remembered in penalty_accrued_through, so the job can run repeatedly.
+func (s *LoanService) AccruePenalties(rules config.PenaltyConfig, asOf time.Time) (int, error) {
+	if rules.Type == "" {
+		return 0, nil
+	}
+
+	today := dateOf(asOf)
+	updated := 0
+
+	err := s.db.WithTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`
+			SELECT li.id, li.due_date, li.amount_due, li.amount_paid, li.penalty_due, li.penalty_accrued_through
+			FROM loan_installments li
+			JOIN loans l ON li.loan_id = l.id
+			WHERE li.status != 'paid' AND li.superseded_at IS NULL AND l.status IN ('disbursed', 'active', 'defaulted')
+			AND date(li.due_date) < date(?)`, today.Format("2006-01-02"))
+		if err != nil {
+			return fmt.Errorf("failed to query overdue installments: %v", err)
+		}
+
+		type overdueInstallment struct {
+			id                    string
+			dueDate               time.Time
+			amountDue, amountPaid int64
+			penaltyDue            int64
+			penaltyAccruedThrough *time.Time
+		}
+		pending := make([]overdueInstallment, 0)
+		for rows.Next() {
+			var row overdueInstallment
+			err := rows.Scan(&row.id, &row.dueDate, &row.amountDue, &row.amountPaid, &row.penaltyDue, &row.penaltyAccruedThrough)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan installment: %v", err)
+			}
+			pending = append(pending, row)
+		}
+		rows.Close()
+
+		for _, row := range pending {
+			// Penalties start once the grace period is over
+			from := dateOf(row.dueDate).AddDate(0, 0, rules.GraceDays)
+			if row.penaltyAccruedThrough != nil && dateOf(*row.penaltyAccruedThrough).After(from) {
+				from = dateOf(*row.penaltyAccruedThrough)
+			}
+			days := int(today.Sub(from).Hours() / 24)
+			if days <= 0 {
+				continue
+			}
+
+			penaltyDue := row.penaltyDue + CalculatePenalty(rules, row.amountDue-row.amountPaid, days)
+			if rules.MaxPenaltyPercent > 0 {
+				penaltyCap := int64(math.Round(float64(row.amountDue) * rules.MaxPenaltyPercent / 100))
+				penaltyDue = min(penaltyDue, max(penaltyCap, row.penaltyDue))
+			}
+
+			_, err := tx.Exec(`
+				UPDATE loan_installments
+				SET penalty_due = ?, penalty_accrued_through = ?
+				WHERE id = ?`,
+				penaltyDue, today.Format("2006-01-02"), row.id)
+			if err != nil {
+				return fmt.Errorf("failed to accrue penalty for installment %s: %v", row.id, err)
+			}
+			if penaltyDue != row.penaltyDue {
+				updated++
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return updated, nil
+}
+
+// SimulateLoan computes the schedule and headline figures for a loan offer
+// without saving anything. For flat-rate offers the effective (annuity) rate
+// is returned alongside the quoted flat rate. When a product is given its
+// rate, method, frequency and fees apply, but customer eligibility is not
+// checked.
+func (s *LoanService) SimulateLoan(req LoanSimulationRequest) (*LoanSimulation, error) {
+	var adminFee, provisionFee int64
+	if req.ProductID != "" {
+		product, err := s.productService.GetLoanProduct(req.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if req.InterestRate == 0 {
+			req.InterestRate = product.DefaultInterestRate
+		}
+		if req.Amount < product.MinAmount || req.Amount > product.MaxAmount {
+			return nil, fmt.Errorf("loan amount for %s must be between %d and %d", product.Name, product.MinAmount, product.MaxAmount)
+		}
+		if !productAllowsTerm(product, req.Term) {
+			return nil, fmt.Errorf("term of %d %s installments is not offered for %s (allowed: %v)",
+				req.Term, product.RepaymentFrequency, product.Name, product.AllowedTerms)
+		}
+		req.InterestMethod = product.InterestMethod
+		req.RepaymentFrequency = product.RepaymentFrequency
+		adminFee, provisionFee = LoanFees(product, req.Amount)
+	}
+
+	interestMethod, err := parseInterestMethod(req.InterestMethod)
+	if err != nil {
+		return nil, err
+	}
+	frequency, err := parseRepaymentFrequency(req.RepaymentFrequency)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := s.generateSchedule(interestMethod, frequency, req.Amount, req.InterestRate, req.Term, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	simulation := &LoanSimulation{
+		InterestMethod:     interestMethod,
+		RepaymentFrequency: frequency,
+		InterestRate:       req.InterestRate,
+		EffectiveRate:      req.InterestRate,
+		MonthlyPayment:     schedule[0].AmountDue,
+		AdminFee:           adminFee,
+		ProvisionFee:       provisionFee,
+		NetDisbursement:    req.Amount - adminFee - provisionFee,
+		Schedule:           schedule,
+	}
+	if interestMethod == models.InterestMethodFlat {
+		simulation.EffectiveRate = utils.FlatToEffectiveRate(req.InterestRate, req.Term, frequency)
+	}
+
+	for _, line := range schedule {
+		simulation.TotalInterest += line.InterestDue
+		simulation.TotalPayment += line.AmountDue
+	}
+
+	return simulation, nil
+}
+
+// EarlySettlementQuote is what a member must pay to close a loan early
+// (pelunasan dipercepat) on SettlementDate.
+type EarlySettlementQuote struct {
+	LoanID               uuid.UUID `json:"loan_id"`
+	SettlementDate       time.Time `json:"settlement_date"`
+	OutstandingPrincipal int64     `json:"outstanding_principal"`
+	AccruedInterest      int64     `json:"accrued_interest"` // unpaid interest up to the settlement date
+	UnpaidPenalty        int64     `json:"unpaid_penalty"`
+	SettlementFee        int64     `json:"settlement_fee"`
+	InterestDiscount     int64     `json:"interest_discount"`
+	Credit               int64     `json:"credit"` // loan credit deducted from the total
+	Total                int64     `json:"total"`
+
+	lines []settlementLine
+}
+
+// settlementLine is what early settlement collects on one unpaid installment
+type settlementLine struct {
+	installmentID uuid.UUID
+	penalty       int64
+	interest      int64
+	principal     int64
+}
+
+type LoanSettlementRequest struct {
+	LoanID         string               `json:"loan_id"`
+	SettlementDate time.Time            `json:"settlement_date"`
+	Amount         int64                `json:"amount"` // must equal the quoted total
+	Method         models.PaymentMethod `json:"method"` // defaults to cash
+	Reference      string               `json:"reference"`
+	Reason         string               `json:"reason"`
+	PostedBy       string               `json:"posted_by"`
+}
+
+// QuoteEarlySettlement prices closing a loan on date. Interest is owed in
+// full for installments already due and pro rata by day for the period the
+// date falls in; later installments carry no interest. The configured fee is
+// charged on the outstanding principal and the configured discount is taken
+// off the accrued interest.
+func (s *LoanService) QuoteEarlySettlement(loanID string, date time.Time) (*EarlySettlementQuote, error) {
+	id, err := uuid.Parse(loanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+
+	return s.quoteEarlySettlement(s.db, id, date)
+}
+
+// SettleLoan closes a loan early for the quoted total. The payment is posted
+// to the ledger against each remaining installment, those installments are
+// marked settled and the loan is completed with the settlement reason.
+func (s *LoanService) SettleLoan(req LoanSettlementRequest) (*models.Loan, error) {
+	loanID, err := uuid.Parse(req.LoanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("settlement reason is required")
+	}
+
+	if req.Method == "" {
+		req.Method = models.PaymentMethodCash
+	}
+	if err := validatePaymentMethod(req.Method); err != nil {
+		return nil, err
+	}
+
+	postedBy, err := parseOptionalUUID(req.PostedBy, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
+	if req.SettlementDate.IsZero() {
+		req.SettlementDate = time.Now()
+	}
+
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		quote, err := s.quoteEarlySettlement(tx, loanID, req.SettlementDate)
+		if err != nil {
+			return err
+		}
+		if req.Amount != quote.Total {
+			return fmt.Errorf("settlement amount must be %d", quote.Total)
+		}
+
+		// The discount waives the interest of the latest installments first
+		discount := quote.InterestDiscount
+		for i := len(quote.lines) - 1; i >= 0 && discount > 0; i-- {
+			waived := min(discount, quote.lines[i].interest)
+			quote.lines[i].interest -= waived
+			discount -= waived
+		}
+
+		now := time.Now()
+		batchID := uuid.New()
+		credit := quote.Credit
+		for i, line := range quote.lines {
+			var fee int64
+			if i == len(quote.lines)-1 {
+				fee = quote.SettlementFee
+			}
+
+			// Credit already on the loan is used before new cash
+			applied := line.penalty + line.interest + line.principal + fee
+			drawn := min(credit, applied)
+			credit -= drawn
+
+			payment := &models.Payment{
+				ID:              uuid.New(),
+				LoanID:          loanID,
+				InstallmentID:   line.installmentID,
+				Amount:          applied - drawn,
+				PenaltyAmount:   line.penalty,
+				InterestAmount:  line.interest,
+				PrincipalAmount: line.principal,
+				FeeAmount:       fee,
+				CreditAmount:    -drawn,
+				Method:          req.Method,
+				Reference:       strings.TrimSpace(req.Reference),
+				PaymentDate:     req.SettlementDate,
+				PostedBy:        postedBy,
+				BatchID:         &batchID,
+				Notes:           reason,
+				CreatedAt:       now,
+			}
+			if err := insertPayment(tx, payment); err != nil {
+				return err
+			}
+
+			_, err = tx.Exec("UPDATE loan_installments SET settled_at = ? WHERE id = ?", now, line.installmentID.String())
+			if err != nil {
+				return fmt.Errorf("failed to settle installment: %v", err)
+			}
+		}
+
+		// Completing the loan before recalculating keeps the history on the
+		// settlement rather than on the last installment being paid
+		err = transitionLoanStatus(tx, loanID.String(), models.LoanStatusCompleted, postedBy, "early settlement: "+reason)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE loans SET settlement_reason = ?, settled_at = ? WHERE id = ?", reason, now, loanID.String())
+		if err != nil {
+			return fmt.Errorf("failed to complete loan: %v", err)
+		}
+
+		for _, line := range quote.lines {
+			if err := recalculateInstallment(tx, line.installmentID.String(), loanID.String(), postedBy); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetLoan(loanID.String())
+}
+
+type LoanRestructureRequest struct {
+	LoanID            string    `json:"loan_id"`
+	Term              int       `json:"term"`               // installments in the new schedule; 0 keeps the number left
+	InterestRate      float64   `json:"interest_rate"`      // 0 keeps the current rate
+	CapitalizeArrears bool      `json:"capitalize_arrears"` // add overdue interest and penalties to the principal
+	StartDate         time.Time `json:"start_date"`         // the new schedule runs from here at the loan's frequency; defaults to today
+	Reason            string    `json:"reason"`
+	ApprovedBy        string    `json:"approved_by"` // user ID of the approving admin
+}
+
+// RestructureLoan replaces the schedule of a loan with a new one built from
+// its outstanding principal, for a new term and/or rate. The old installments
+// are kept, with their payments, as a superseded schedule version. Arrears
+// (interest accrued up to the start date and unpaid penalties) are either
+// added to the new principal or carried onto the first new installment,
+// where they stay due in full.
+func (s *LoanService) RestructureLoan(req LoanRestructureRequest) (*models.Loan, error) {
+	loanID, err := uuid.Parse(req.LoanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("restructuring reason is required")
+	}
+
+	approverID, err := uuid.Parse(req.ApprovedBy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid approver ID")
+	}
+
+	if req.Term < 0 {
+		return nil, fmt.Errorf("term must not be negative")
+	}
+	if req.InterestRate < 0 {
+		return nil, fmt.Errorf("interest rate must not be negative")
+	}
+
+	if req.StartDate.IsZero() {
+		req.StartDate = time.Now()
+	}
+
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		var role models.UserRole
+		var active bool
+		err := tx.QueryRow("SELECT role, active FROM users WHERE id = ?", approverID.String()).Scan(&role, &active)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("approver not found")
+			}
+			return fmt.Errorf("failed to get approver: %v", err)
+		}
+		if !active || (role != models.RoleAdmin && role != models.RoleSuperadmin) {
+			return fmt.Errorf("restructuring must be approved by an active admin")
+		}
+
+		var status models.LoanStatus
+		var interestRate float64
+		var interestMethod models.InterestMethod
+		var frequency models.RepaymentFrequency
+		var version int
+		var disbursedAt *time.Time
+		var contractNumber string
+		err = tx.QueryRow(`
+			SELECT status, interest_rate, interest_method, repayment_frequency, schedule_version, disbursed_at, contract_number
+			FROM loans WHERE id = ?`, loanID.String()).Scan(&status, &interestRate, &interestMethod, &frequency, &version, &disbursedAt, &contractNumber)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("loan not found")
+			}
+			return fmt.Errorf("failed to get loan: %v", err)
+		}
+		if status != models.LoanStatusDisbursed && status != models.LoanStatusActive && status != models.LoanStatusDefaulted {
+			return fmt.Errorf("only disbursed, active or defaulted loans can be restructured")
+		}
+		if disbursedAt == nil {
+			return fmt.Errorf("loan has not been disbursed")
+		}
+
+		start, err := scheduleStart(tx, loanID, *disbursedAt)
+		if err != nil {
+			return err
+		}
+		position, err := outstandingPosition(tx, loanID, start, req.StartDate)
+		if err != nil {
+			return err
+		}
+
+		restructuring := models.LoanRestructuring{
+			ID:                   uuid.New(),
+			LoanID:               loanID,
+			PreviousVersion:      version,
+			ScheduleVersion:      version + 1,
+			OutstandingPrincipal: position.OutstandingPrincipal,
+			PreviousInterestRate: interestRate,
+			InterestRate:         interestRate,
+			PreviousTerm:         len(position.lines),
+			Term:                 len(position.lines),
+			StartDate:            dateOf(req.StartDate),
+			Reason:               reason,
+			ApprovedBy:           approverID,
+			CreatedAt:            time.Now(),
+		}
+		if req.InterestRate > 0 {
+			restructuring.InterestRate = req.InterestRate
+		}
+		if req.Term > 0 {
+			restructuring.Term = req.Term
+		}
+
+		principal := position.OutstandingPrincipal
+		arrearsInterest, arrearsPenalty := position.AccruedInterest, position.UnpaidPenalty
+		if req.CapitalizeArrears {
+			restructuring.CapitalizedAmount = arrearsInterest + arrearsPenalty
+			restructuring.CapitalizedPenalty = arrearsPenalty
+			principal += restructuring.CapitalizedAmount
+			arrearsInterest, arrearsPenalty = 0, 0
+		}
+		if principal <= 0 {
+			return fmt.Errorf("loan has no outstanding principal to restructure")
+		}
+
+		schedule, err := s.generateSchedule(interestMethod, frequency, principal, restructuring.InterestRate, restructuring.Term, restructuring.StartDate)
+		if err != nil {
+			return err
+		}
+		monthlyPayment := schedule[0].AmountDue
+		schedule[0].InterestDue += arrearsInterest
+		schedule[0].AmountDue += arrearsInterest
+
+		// Installment numbers are unique per loan, so the new schedule
+		// continues the numbering of the old one
+		var lastNumber int
+		err = tx.QueryRow("SELECT COALESCE(MAX(number), 0) FROM loan_installments WHERE loan_id = ?", loanID.String()).Scan(&lastNumber)
+		if err != nil {
+			return fmt.Errorf("failed to number installments: %v", err)
+		}
+		for i := range schedule {
+			schedule[i].Number += lastNumber
+		}
+
+		_, err = tx.Exec(`
+			UPDATE loan_installments SET superseded_at = ?
+			WHERE loan_id = ? AND superseded_at IS NULL`,
+			restructuring.CreatedAt, loanID.String())
+		if err != nil {
+			return fmt.Errorf("failed to supersede schedule: %v", err)
+		}
+
+		if err := insertInstallments(tx, loanID, restructuring.ScheduleVersion, schedule); err != nil {
+			return fmt.Errorf("failed to create installments: %v", err)
+		}
+		if arrearsInterest > 0 || arrearsPenalty > 0 {
+			_, err = tx.Exec(`
+				UPDATE loan_installments SET arrears_interest = ?, penalty_due = ?
+				WHERE loan_id = ? AND number = ?`,
+				arrearsInterest, arrearsPenalty, loanID.String(), schedule[0].Number)
+			if err != nil {
+				return fmt.Errorf("failed to carry over arrears: %v", err)
+			}
+		}
+
+		_, err = tx.Exec(`
+			UPDATE loans
+			SET interest_rate = ?, monthly_payment = ?, due_date = ?, schedule_version = ?, restructured = TRUE
+			WHERE id = ?`,
+			restructuring.InterestRate, monthlyPayment, schedule[len(schedule)-1].DueDate,
+			restructuring.ScheduleVersion, loanID.String())
+		if err != nil {
+			return fmt.Errorf("failed to update loan: %v", err)
+		}
+		err = transitionLoanStatus(tx, loanID.String(), models.LoanStatusActive, &approverID, "restructured: "+restructuring.Reason)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO loan_restructurings (id, loan_id, previous_version, schedule_version, outstanding_principal,
+			                                 capitalized_amount, capitalized_penalty, previous_interest_rate, interest_rate,
+			                                 previous_term, term, start_date, reason, approved_by, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			restructuring.ID.String(), loanID.String(), restructuring.PreviousVersion, restructuring.ScheduleVersion,
+			restructuring.OutstandingPrincipal, restructuring.CapitalizedAmount, restructuring.CapitalizedPenalty,
+			restructuring.PreviousInterestRate, restructuring.InterestRate, restructuring.PreviousTerm, restructuring.Term,
+			restructuring.StartDate.Format("2006-01-02"), restructuring.Reason, approverID.String(), restructuring.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record restructuring: %v", err)
+		}
+
+		entry := accounting.Capitalization(restructuring, contractNumber)
+		return postJournalEntry(tx, &entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetLoan(loanID.String())
+}
+
+// GetLoanRestructurings lists the restructurings of a loan, oldest first,
+// each with the schedule it replaced.
+func (s *LoanService) GetLoanRestructurings(loanID string) ([]models.LoanRestructuring, error) {
+	id, err := uuid.Parse(loanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+
+	rows, err := s.db.Query(`
+		SELECT r.id, r.loan_id, r.previous_version, r.schedule_version, r.outstanding_principal,
+		       r.capitalized_amount, r.capitalized_penalty, r.previous_interest_rate, r.interest_rate, r.previous_term, r.term,
+		       r.start_date, r.reason, r.approved_by, r.created_at, u.name
+		FROM loan_restructurings r
+		JOIN users u ON r.approved_by = u.id
+		WHERE r.loan_id = ?
+		ORDER BY r.schedule_version`, id.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get restructurings: %v", err)
+	}
+	defer rows.Close()
+
+	restructurings := make([]models.LoanRestructuring, 0)
+	for rows.Next() {
+		var r models.LoanRestructuring
+		var approverName string
+		err := rows.Scan(&r.ID, &r.LoanID, &r.PreviousVersion, &r.ScheduleVersion, &r.OutstandingPrincipal,
+			&r.CapitalizedAmount, &r.CapitalizedPenalty, &r.PreviousInterestRate, &r.InterestRate, &r.PreviousTerm, &r.Term,
+			&r.StartDate, &r.Reason, &r.ApprovedBy, &r.CreatedAt, &approverName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan restructuring: %v", err)
+		}
+		r.ApprovedByUser = &models.User{ID: r.ApprovedBy, Name: approverName}
+		restructurings = append(restructurings, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range restructurings {
+		restructurings[i].SupersededSchedule, err = s.getInstallments("loan_id = ? AND schedule_version = ?",
+			id.String(), restructurings[i].PreviousVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get superseded schedule: %v", err)
+		}
+	}
+
+	return restructurings, nil
+}
+
+type LoanWriteOffRequest struct {
+	LoanID       string `json:"loan_id"`
+	Reason       string `json:"reason"`
+	WrittenOffBy string `json:"written_off_by"` // user ID of the superadmin
+}
+
+// WriteOffLoan takes a loan classified Macet off the books. What it still
+// owes is recorded on the loan and its installments are left as they are;
+// anything collected afterwards is posted as a recovery.
+func (s *LoanService) WriteOffLoan(req LoanWriteOffRequest) (*models.Loan, error) {
+	loanID, err := uuid.Parse(req.LoanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("write-off reason is required")
+	}
+
+	userID, err := uuid.Parse(req.WrittenOffBy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		var role models.UserRole
+		var active bool
+		err := tx.QueryRow("SELECT role, active FROM users WHERE id = ?", userID.String()).Scan(&role, &active)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("user not found")
+			}
+			return fmt.Errorf("failed to get user: %v", err)
+		}
+		if !active || role != models.RoleSuperadmin {
+			return fmt.Errorf("loans can only be written off by an active superadmin")
+		}
+
+		var status models.LoanStatus
+		var disbursedAt *time.Time
+		var contractNumber string
+		err = tx.QueryRow("SELECT status, disbursed_at, contract_number FROM loans WHERE id = ?", loanID.String()).Scan(&status, &disbursedAt, &contractNumber)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("loan not found")
+			}
+			return fmt.Errorf("failed to get loan: %v", err)
+		}
+		if status != models.LoanStatusActive && status != models.LoanStatusDefaulted {
+			return fmt.Errorf("only active or defaulted loans can be written off")
+		}
+		if disbursedAt == nil {
+			return fmt.Errorf("loan has not been disbursed")
+		}
+
+		now := time.Now()
+		exposures, err := runningLoanExposures(tx, now, " AND l.id = ?", loanID.String())
+		if err != nil {
+			return err
+		}
+		if len(exposures) != 1 {
+			return fmt.Errorf("loan not found")
+		}
+		exposure := exposures[0]
+		class := ClassifyCollectibility(exposure.dpd, exposure.restructured, exposure.timelyPayments)
+		if class != models.CollectibilityMacet {
+			return fmt.Errorf("only loans classified %s can be written off; this loan is %s at %d days past due",
+				models.CollectibilityMacet.Name(), class.Name(), exposure.dpd)
+		}
+
+		start, err := scheduleStart(tx, loanID, *disbursedAt)
+		if err != nil {
+			return err
+		}
+		position, err := outstandingPosition(tx, loanID, start, now)
+		if err != nil {
+			return err
+		}
+
+		if err := transitionLoanStatus(tx, loanID.String(), models.LoanStatusWrittenOff, &userID, reason); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`
+			UPDATE loans
+			SET written_off_at = ?, written_off_by = ?, write_off_reason = ?,
+			    written_off_principal = ?, written_off_interest = ?, written_off_penalty = ?
+			WHERE id = ?`,
+			now, userID.String(), reason,
+			position.OutstandingPrincipal, position.AccruedInterest, position.UnpaidPenalty, loanID.String())
+		if err != nil {
+			return fmt.Errorf("failed to write off loan: %v", err)
+		}
+
+		deferred, err := deferredArrears(tx, loanID.String())
+		if err != nil {
+			return err
+		}
+		entry := accounting.WriteOff(loanID, contractNumber, position.OutstandingPrincipal,
+			deferred.interest+deferred.penalty, now, &userID)
+		return postJournalEntry(tx, &entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetLoan(loanID.String())
+}
+
+func (s *LoanService) quoteEarlySettlement(q querier, loanID uuid.UUID, date time.Time) (*EarlySettlementQuote, error) {
+	var status models.LoanStatus
+	var disbursedAt *time.Time
+	err := q.QueryRow("SELECT status, disbursed_at FROM loans WHERE id = ?", loanID.String()).Scan(&status, &disbursedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("loan not found")
+		}
+		return nil, fmt.Errorf("failed to get loan: %v", err)
+	}
+	if status != models.LoanStatusDisbursed && status != models.LoanStatusActive && status != models.LoanStatusDefaulted {
+		return nil, fmt.Errorf("only disbursed, active or defaulted loans can be settled early")
+	}
+
+	if disbursedAt == nil {
+		return nil, fmt.Errorf("loan has not been disbursed")
+	}
+	start, err := scheduleStart(q, loanID, *disbursedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := outstandingPosition(q, loanID, start, date)
+	if err != nil {
+		return nil, err
+	}
+	// Unpaid installments that owe nothing have no principal and interest
+	// split; settling them for nothing would close a loan still owed
+	if quote.OutstandingPrincipal+quote.AccruedInterest+quote.UnpaidPenalty == 0 {
+		return nil, fmt.Errorf("loan has unpaid installments but no outstanding principal or interest")
+	}
+
+	rules := s.cfg.EarlySettlement
+	quote.SettlementFee = int64(math.Round(float64(quote.OutstandingPrincipal) * rules.FeePercent / 100))
+	quote.InterestDiscount = int64(math.Round(float64(quote.AccruedInterest) * rules.InterestDiscountPercent / 100))
+	quote.Total = max(quote.OutstandingPrincipal+quote.AccruedInterest+quote.UnpaidPenalty+
+		quote.SettlementFee-quote.InterestDiscount-quote.Credit, 0)
+
+	return quote, nil
+}
+
+// scheduleStart is when the current schedule of a loan began: the start of
+// its latest restructuring, or the disbursement.
+func scheduleStart(q rowQuerier, loanID uuid.UUID, disbursedAt time.Time) (time.Time, error) {
+	var start time.Time
+	err := q.QueryRow(`
+		SELECT start_date FROM loan_restructurings
+		WHERE loan_id = ?
+		ORDER BY schedule_version DESC
+		LIMIT 1`, loanID.String()).Scan(&start)
+	if err == sql.ErrNoRows {
+		return disbursedAt, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get schedule start: %v", err)
+	}
+	return start, nil
+}
+
+// outstandingPosition works out what a loan owes on date, per unpaid
+// installment of its current schedule: the principal left, interest accrued
+// up to the date and unpaid penalties. Fees and discounts are left at zero.
+func outstandingPosition(q querier, loanID uuid.UUID, start, date time.Time) (*EarlySettlementQuote, error) {
+	settlementDate := dateOf(date)
+	if settlementDate.Before(dateOf(start)) {
+		return nil, fmt.Errorf("date is before the current schedule started")
+	}
+
+	rows, err := q.Query(`
+		SELECT id, due_date, principal_due, interest_due, arrears_interest, amount_paid, penalty_due, penalty_paid, status
+		FROM loan_installments
+		WHERE loan_id = ? AND superseded_at IS NULL
+		ORDER BY number`, loanID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installments: %v", err)
+	}
+	defer rows.Close()
+
+	quote := &EarlySettlementQuote{LoanID: loanID, SettlementDate: settlementDate}
+	periodStart := dateOf(start)
+	for rows.Next() {
+		var installment models.LoanInstallment
+		err := rows.Scan(&installment.ID, &installment.DueDate, &installment.PrincipalDue, &installment.InterestDue,
+			&installment.ArrearsInterest, &installment.AmountPaid, &installment.PenaltyDue, &installment.PenaltyPaid, &installment.Status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan installment: %v", err)
+		}
+
+		dueDate := dateOf(installment.DueDate)
+		start := periodStart
+		periodStart = dueDate
+		if installment.Status == models.InstallmentStatusPaid {
+			continue
+		}
+
+		// amount_paid covers interest before principal
+		interestPaid := min(installment.AmountPaid, installment.InterestDue)
+		principal := installment.PrincipalDue - (installment.AmountPaid - interestPaid)
+
+		// Arrears carried over by a restructuring were owed before the
+		// period began, so only the period's own interest is pro rata
+		interest := installment.ArrearsInterest
+		switch {
+		case !dueDate.After(settlementDate):
+			interest = installment.InterestDue
+		case settlementDate.After(start):
+			elapsed := settlementDate.Sub(start).Hours() / 24
+			period := dueDate.Sub(start).Hours() / 24
+			periodInterest := installment.InterestDue - installment.ArrearsInterest
+			interest += int64(math.Round(float64(periodInterest) * elapsed / period))
+		}
+		interest = max(interest-interestPaid, 0)
+
+		line := settlementLine{
+			installmentID: installment.ID,
+			penalty:       max(installment.PenaltyDue-installment.PenaltyPaid, 0),
+			interest:      interest,
+			principal:     max(principal, 0),
+		}
+		quote.lines = append(quote.lines, line)
+		quote.OutstandingPrincipal += line.principal
+		quote.AccruedInterest += line.interest
+		quote.UnpaidPenalty += line.penalty
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(quote.lines) == 0 {
+		return nil, fmt.Errorf("loan has no outstanding installments")
+	}
+
+	quote.Credit, err = loanCreditBalance(q, loanID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return quote, nil
+}
+
+// Helper functions
+
+// dateOf drops the time of day so whole days can be counted
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func parseInterestMethod(method models.InterestMethod) (models.InterestMethod, error) {
+	switch method {
+	case "":
+		return models.InterestMethodAnnuity, nil
+	case models.InterestMethodFlat, models.InterestMethodAnnuity, models.InterestMethodDecliningBalance:
+		return method, nil
+	}
+	return "", fmt.Errorf("unknown interest method: %s", method)
+}
+
+func parseRepaymentFrequency(frequency models.RepaymentFrequency) (models.RepaymentFrequency, error) {
+	switch frequency {
+	case "":
+		return models.RepaymentFrequencyMonthly, nil
+	case models.RepaymentFrequencyWeekly, models.RepaymentFrequencyBiweekly, models.RepaymentFrequencyMonthly:
+		return frequency, nil
+	}
+	return "", fmt.Errorf("unknown repayment frequency: %s", frequency)
+}
+
+// generateSchedule builds the repayment schedule for the given interest
+// method and frequency
+func (s *LoanService) generateSchedule(method models.InterestMethod, frequency models.RepaymentFrequency, amount int64, interestRate float64, term int, start time.Time) ([]utils.AmortizationLine, error) {
+	switch method {
+	case models.InterestMethodFlat:
+		return utils.FlatSchedule(amount, interestRate, term, start, frequency)
+	case models.InterestMethodDecliningBalance:
+		return utils.DecliningBalanceSchedule(amount, interestRate, term, start, frequency)
+	case models.InterestMethodAnnuity, "":
+		return utils.AmortizationSchedule(amount, interestRate, term, start, frequency)
+	}
+	return nil, fmt.Errorf("unknown interest method: %s", method)
+}
+
+// calculateInstallment returns the first installment of a schedule starting
+// today and the due date of its last installment. For declining balance
+// loans the first installment is the largest.
+func (s *LoanService) calculateInstallment(method models.InterestMethod, frequency models.RepaymentFrequency, amount int64, interestRate float64, term int) (int64, time.Time, error) {
+	schedule, err := s.generateSchedule(method, frequency, amount, interestRate, term, time.Now())
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return schedule[0].AmountDue, schedule[len(schedule)-1].DueDate, nil
+}
+
+func (s *LoanService) generateContractNumber() (string, error) {
+	year := time.Now().Year()
+
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM loans WHERE strftime('%Y', created_at) = ?", fmt.Sprintf("%d", year)).Scan(&count)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("KOP-%d-%04d", year, count+1), nil
+}
+
+// createInstallments inserts the first schedule of a loan and returns the due
+// date of its last installment
+func (s *LoanService) createInstallments(tx *sql.Tx, loanID uuid.UUID, method models.InterestMethod, frequency models.RepaymentFrequency, amount int64, interestRate float64, term int, disbursedAt time.Time) (time.Time, error) {
+	schedule, err := s.generateSchedule(method, frequency, amount, interestRate, term, disbursedAt)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return schedule[len(schedule)-1].DueDate, insertInstallments(tx, loanID, 1, schedule)
+}
+
+func insertInstallments(tx *sql.Tx, loanID uuid.UUID, version int, schedule []utils.AmortizationLine) error {
+	for _, line := range schedule {
+		installment := models.LoanInstallment{
+			ID:                 uuid.New(),
+			LoanID:             loanID,
+			Number:             line.Number,
+			DueDate:            line.DueDate,
+			PrincipalDue:       line.PrincipalDue,
+			InterestDue:        line.InterestDue,
+			AmountDue:          line.AmountDue,
+			AmountPaid:         0,
+			OutstandingBalance: line.OutstandingBalance,
+			Status:             models.InstallmentStatusPending,
+			ScheduleVersion:    version,
+			DPD:                0,
+			CreatedAt:          time.Now(),
+			UpdatedAt:          time.Now(),
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO loan_installments (id, loan_id, number, due_date, principal_due, interest_due, amount_due, amount_paid, outstanding_balance, status, schedule_version, dpd)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			installment.ID.String(), installment.LoanID.String(), installment.Number,
+			installment.DueDate, installment.PrincipalDue, installment.InterestDue,
+			installment.AmountDue, installment.AmountPaid, installment.OutstandingBalance,
+			string(installment.Status), installment.ScheduleVersion, installment.DPD)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getInstallmentsByLoanID returns the current schedule of a loan
+func (s *LoanService) getInstallmentsByLoanID(loanID string) ([]models.LoanInstallment, error) {
+	return s.getInstallments("loan_id = ? AND superseded_at IS NULL", loanID)
+}
+
+func (s *LoanService) getInstallments(condition string, args ...interface{}) ([]models.LoanInstallment, error) {
+	rows, err := s.db.Query(`
+		SELECT id, loan_id, number, due_date, principal_due, interest_due, amount_due, amount_paid,
+		       arrears_interest, outstanding_balance, penalty_due, penalty_paid, status, paid_at, settled_at,
+		       schedule_version, superseded_at, dpd, created_at, updated_at
+		FROM loan_installments 
+		WHERE `+condition+`
+		ORDER BY schedule_version, number`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	installments := make([]models.LoanInstallment, 0)
+	for rows.Next() {
+		var installment models.LoanInstallment
+
+		err := rows.Scan(
+			&installment.ID, &installment.LoanID, &installment.Number,
+			&installment.DueDate, &installment.PrincipalDue, &installment.InterestDue,
+			&installment.AmountDue, &installment.AmountPaid, &installment.ArrearsInterest,
+			&installment.OutstandingBalance, &installment.PenaltyDue, &installment.PenaltyPaid,
+			&installment.Status, &installment.PaidAt, &installment.SettledAt,
+			&installment.ScheduleVersion, &installment.SupersededAt, &installment.DPD,
+			&installment.CreatedAt, &installment.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		installments = append(installments, installment)
+	}
+
+	return installments, nil
+}
+
+func (s *LoanService) GetOverdueInstallments() ([]models.LoanInstallment, error) {
+	rows, err := s.db.Query(`
+		SELECT li.id, li.loan_id, li.number, li.due_date, li.amount_due, li.amount_paid, 
+		       li.penalty_due, li.penalty_paid, li.status, li.paid_at, li.dpd, li.created_at, li.updated_at,
+		       l.contract_number, c.name as customer_name
+		FROM loan_installments li
+		JOIN loans l ON li.loan_id = l.id
+		JOIN customers c ON l.customer_id = c.id
+		WHERE li.due_date < date('now') AND li.status != 'paid' AND li.superseded_at IS NULL
+		AND l.status IN ('disbursed', 'active', 'defaulted')
+		ORDER BY li.due_date ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query overdue installments: %v", err)
+	}
+	defer rows.Close()
+
+	installments := make([]models.LoanInstallment, 0)
+	for rows.Next() {
+		var installment models.LoanInstallment
+		var contractNumber, customerName string
+
+		err := rows.Scan(
+			&installment.ID, &installment.LoanID, &installment.Number,
+			&installment.DueDate, &installment.AmountDue, &installment.AmountPaid,
+			&installment.PenaltyDue, &installment.PenaltyPaid,
+			&installment.Status, &installment.PaidAt, &installment.DPD,
+			&installment.CreatedAt, &installment.UpdatedAt,
+			&contractNumber, &customerName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan installment: %v", err)
+		}
+
+		// Calculate DPD (Days Past Due)
+		if installment.DueDate.Before(time.Now()) {
+			installment.DPD = int(time.Since(installment.DueDate).Hours() / 24)
+		}
+
+		// Update DPD in database
+		s.db.Exec("UPDATE loan_installments SET dpd = ? WHERE id = ?", installment.DPD, installment.ID.String())
+
+		// Set loan and customer info
+		installment.Loan = &models.Loan{
+			ID:             installment.LoanID,
+			ContractNumber: contractNumber,
+		}
+		installment.Loan.Customer = &models.Customer{
+			Name: customerName,
+		}
+
+		installments = append(installments, installment)
+	}
+
+	return installments, nil
+}
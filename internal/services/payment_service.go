@@ -0,0 +1,955 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+)
+
+type PaymentService struct {
+	db           *database.DB
+	auditService *AuditService
+}
+
+func NewPaymentService(db *database.DB, auditService *AuditService) *PaymentService {
+	return &PaymentService{db: db, auditService: auditService}
+}
+
+type InstallmentPaymentRequest struct {
+	InstallmentID  string               `json:"installment_id"`
+	Amount         int64                `json:"amount"`
+	PaymentDate    time.Time            `json:"payment_date"`
+	Method         models.PaymentMethod `json:"method"` // defaults to cash
+	Reference      string               `json:"reference"`
+	IdempotencyKey string               `json:"idempotency_key"`
+	PostedBy       string               `json:"posted_by"`
+}
+
+type LoanPaymentRequest struct {
+	LoanID         string               `json:"loan_id"`
+	Amount         int64                `json:"amount"`
+	PaymentDate    time.Time            `json:"payment_date"`
+	Method         models.PaymentMethod `json:"method"` // defaults to cash
+	Reference      string               `json:"reference"`
+	IdempotencyKey string               `json:"idempotency_key"`
+	PostedBy       string               `json:"posted_by"`
+}
+
+// InstallmentAllocation is the part of a loan payment that went to one
+// installment.
+type InstallmentAllocation struct {
+	InstallmentID uuid.UUID                    `json:"installment_id"`
+	Number        int                          `json:"number"`
+	PaymentID     uuid.UUID                    `json:"payment_id"`
+	ReceiptNumber string                       `json:"receipt_number"`
+	Penalty       int64                        `json:"penalty"`
+	Interest      int64                        `json:"interest"`
+	Principal     int64                        `json:"principal"`
+	Status        models.LoanInstallmentStatus `json:"status"` // after the payment
+}
+
+type LoanPaymentResult struct {
+	LoanID        uuid.UUID               `json:"loan_id"`
+	BatchID       uuid.UUID               `json:"batch_id"`
+	Amount        int64                   `json:"amount"`
+	CreditApplied int64                   `json:"credit_applied"` // earlier credit used by this payment
+	CreditAdded   int64                   `json:"credit_added"`   // remainder kept on the loan
+	CreditBalance int64                   `json:"credit_balance"`
+	Installments  []InstallmentAllocation `json:"installments"`
+}
+
+type PaymentReversalRequest struct {
+	PaymentID string `json:"payment_id"`
+	Reason    string `json:"reason"`
+	UserID    string `json:"user_id"`
+}
+
+const paymentColumns = `
+	p.id, p.loan_id, p.installment_id, p.receipt_number, p.amount, p.penalty_amount, p.interest_amount,
+	p.principal_amount, p.fee_amount, p.credit_amount, p.capitalized_interest, p.capitalized_penalty,
+	p.method, COALESCE(p.reference, ''), p.payment_date, p.posted_by,
+	p.idempotency_key, p.batch_id, p.reversal_of, r.id, COALESCE(p.notes, ''), p.created_at`
+
+const paymentFrom = `
+	FROM payments p
+	LEFT JOIN payments r ON r.reversal_of = p.id`
+
+// PayInstallment records a payment against an installment and updates the
+// installment and loan from the ledger. Anything above what the installment
+// owes is kept as credit on the loan. A request repeating an idempotency key
+// returns the payment already recorded for it instead of posting it twice.
+func (s *PaymentService) PayInstallment(req InstallmentPaymentRequest) (*models.Payment, error) {
+	installmentID, err := uuid.Parse(req.InstallmentID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid installment ID")
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("payment amount must be greater than 0")
+	}
+
+	if req.Method == "" {
+		req.Method = models.PaymentMethodCash
+	}
+	if err := validatePaymentMethod(req.Method); err != nil {
+		return nil, err
+	}
+
+	postedBy, err := parseOptionalUUID(req.PostedBy, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
+	if req.PaymentDate.IsZero() {
+		req.PaymentDate = time.Now()
+	}
+
+	var payment *models.Payment
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		if req.IdempotencyKey != "" {
+			existing, err := s.getPaymentByIdempotencyKey(tx, req.IdempotencyKey)
+			if err == nil {
+				if existing.InstallmentID != installmentID || existing.Amount != req.Amount {
+					return fmt.Errorf("idempotency key %s was already used for a different payment", req.IdempotencyKey)
+				}
+				payment = existing
+				return nil
+			}
+			if err != sql.ErrNoRows {
+				return fmt.Errorf("failed to check idempotency key: %v", err)
+			}
+		}
+
+		installment, err := queryInstallment(tx, installmentID.String())
+		if err != nil {
+			return err
+		}
+		if installment.Status == models.InstallmentStatusPaid {
+			return fmt.Errorf("installment is already paid")
+		}
+		if installment.SupersededAt != nil {
+			return fmt.Errorf("installment belongs to a schedule replaced by restructuring")
+		}
+
+		var status models.LoanStatus
+		err = tx.QueryRow("SELECT status FROM loans WHERE id = ?", installment.LoanID.String()).Scan(&status)
+		if err != nil {
+			return fmt.Errorf("failed to get loan: %v", err)
+		}
+		if status == models.LoanStatusWrittenOff {
+			return fmt.Errorf("loan is written off; collections must be posted as recoveries")
+		}
+
+		// Penalty is settled first, then interest, then principal
+		allocation := AllocateInstallmentPayment(installment, req.Amount)
+
+		payment = &models.Payment{
+			ID:              uuid.New(),
+			LoanID:          installment.LoanID,
+			InstallmentID:   installment.ID,
+			Amount:          req.Amount,
+			PenaltyAmount:   allocation.Penalty,
+			InterestAmount:  allocation.Interest,
+			PrincipalAmount: allocation.Principal,
+			CreditAmount:    allocation.Excess,
+			Method:          req.Method,
+			Reference:       strings.TrimSpace(req.Reference),
+			PaymentDate:     req.PaymentDate,
+			PostedBy:        postedBy,
+			CreatedAt:       time.Now(),
+		}
+		if req.IdempotencyKey != "" {
+			payment.IdempotencyKey = &req.IdempotencyKey
+		}
+
+		if err := insertPayment(tx, payment); err != nil {
+			return err
+		}
+
+		return recalculateInstallment(tx, installment.ID.String(), installment.LoanID.String(), postedBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	payment.Installment, err = s.getInstallment(payment.InstallmentID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// ReversePayment cancels a payment by posting an offsetting entry, then
+// restores the installment and loan status from the remaining payments. The
+// original payment is kept, so the receipt history stays intact.
+func (s *PaymentService) ReversePayment(req PaymentReversalRequest) (*models.Payment, error) {
+	paymentID, err := uuid.Parse(req.PaymentID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment ID")
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("reversal reason is required")
+	}
+
+	userID, err := parseOptionalUUID(req.UserID, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
+	var original, reversal *models.Payment
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		original, err = scanPayment(tx.QueryRow("SELECT "+paymentColumns+paymentFrom+" WHERE p.id = ?", paymentID.String()))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("payment not found")
+			}
+			return fmt.Errorf("failed to get payment: %v", err)
+		}
+		if original.ReversalOf != nil {
+			return fmt.Errorf("a reversal entry cannot be reversed")
+		}
+		if original.ReversedBy != nil {
+			return fmt.Errorf("payment %s is already reversed", original.ReceiptNumber)
+		}
+
+		var settled, superseded bool
+		var status models.LoanStatus
+		err = tx.QueryRow(`
+			SELECT li.settled_at IS NOT NULL, li.superseded_at IS NOT NULL, l.status
+			FROM loan_installments li
+			JOIN loans l ON li.loan_id = l.id
+			WHERE li.id = ?`, original.InstallmentID.String()).Scan(&settled, &superseded, &status)
+		if err != nil {
+			return fmt.Errorf("failed to get installment: %v", err)
+		}
+		if status == models.LoanStatusWrittenOff {
+			return fmt.Errorf("payments on a written-off loan cannot be reversed")
+		}
+		if settled {
+			return fmt.Errorf("payments on an installment closed by early settlement cannot be reversed")
+		}
+		if superseded {
+			return fmt.Errorf("payments on a schedule replaced by restructuring cannot be reversed")
+		}
+
+		now := time.Now()
+		reversal = &models.Payment{
+			ID:                  uuid.New(),
+			LoanID:              original.LoanID,
+			InstallmentID:       original.InstallmentID,
+			Amount:              -original.Amount,
+			PenaltyAmount:       -original.PenaltyAmount,
+			InterestAmount:      -original.InterestAmount,
+			PrincipalAmount:     -original.PrincipalAmount,
+			FeeAmount:           -original.FeeAmount,
+			CreditAmount:        -original.CreditAmount,
+			CapitalizedInterest: -original.CapitalizedInterest,
+			CapitalizedPenalty:  -original.CapitalizedPenalty,
+			Method:              original.Method,
+			Reference:           original.Reference,
+			PaymentDate:         now,
+			PostedBy:            userID,
+			ReversalOf:          &original.ID,
+			Notes:               reason,
+			CreatedAt:           now,
+		}
+		if err := insertPayment(tx, reversal); err != nil {
+			return err
+		}
+		original.ReversedBy = &reversal.ID
+
+		credit, err := loanCreditBalance(tx, original.LoanID.String())
+		if err != nil {
+			return err
+		}
+		if credit < 0 {
+			return fmt.Errorf("the credit from payment %s has already been used by later payments; reverse those first", original.ReceiptNumber)
+		}
+
+		return recalculateInstallment(tx, original.InstallmentID.String(), original.LoanID.String(), userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.auditService.LogAction(AuditLogRequest{
+		UserID:   nullableString(req.UserID),
+		Action:   "reverse_payment",
+		Entity:   "payment",
+		EntityID: original.ID.String(),
+		Before:   original,
+		After:    reversal,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("payment reversed but failed to write audit log: %v", err)
+	}
+
+	reversal.Installment, err = s.getInstallment(reversal.InstallmentID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return reversal, nil
+}
+
+// PayLoan takes one payment for a loan and spreads it over the unpaid
+// installments, oldest first and penalties first, drawing on any credit the
+// loan already holds. Each installment covered gets its own ledger entry;
+// the entries share a batch ID. What is left over stays on the loan as credit.
+func (s *PaymentService) PayLoan(req LoanPaymentRequest) (*LoanPaymentResult, error) {
+	loanID, err := uuid.Parse(req.LoanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("payment amount must be greater than 0")
+	}
+
+	if req.Method == "" {
+		req.Method = models.PaymentMethodCash
+	}
+	if err := validatePaymentMethod(req.Method); err != nil {
+		return nil, err
+	}
+
+	postedBy, err := parseOptionalUUID(req.PostedBy, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
+	if req.PaymentDate.IsZero() {
+		req.PaymentDate = time.Now()
+	}
+
+	var result *LoanPaymentResult
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		if req.IdempotencyKey != "" {
+			existing, err := s.getPaymentByIdempotencyKey(tx, req.IdempotencyKey)
+			if err == nil {
+				if existing.LoanID != loanID || existing.BatchID == nil {
+					return fmt.Errorf("idempotency key %s was already used for a different payment", req.IdempotencyKey)
+				}
+				result, err = batchResult(tx, *existing.BatchID)
+				if err != nil {
+					return err
+				}
+				if result.Amount != req.Amount {
+					return fmt.Errorf("idempotency key %s was already used for a different payment", req.IdempotencyKey)
+				}
+				return nil
+			}
+			if err != sql.ErrNoRows {
+				return fmt.Errorf("failed to check idempotency key: %v", err)
+			}
+		}
+
+		var status models.LoanStatus
+		err := tx.QueryRow("SELECT status FROM loans WHERE id = ?", loanID.String()).Scan(&status)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("loan not found")
+			}
+			return fmt.Errorf("failed to get loan: %v", err)
+		}
+		if status != models.LoanStatusDisbursed && status != models.LoanStatusActive && status != models.LoanStatusDefaulted {
+			return fmt.Errorf("payments can only be made on disbursed, active or defaulted loans")
+		}
+
+		credit, err := loanCreditBalance(tx, loanID.String())
+		if err != nil {
+			return err
+		}
+
+		installments, err := unpaidInstallments(tx, loanID.String())
+		if err != nil {
+			return err
+		}
+		if len(installments) == 0 {
+			return fmt.Errorf("loan has no outstanding installments")
+		}
+
+		allocations, _ := AllocateLoanPayment(installments, req.Amount+credit)
+
+		// The last installment touched also carries the cash left over
+		last := 0
+		for i, allocation := range allocations {
+			if allocation.Penalty+allocation.Interest+allocation.Principal > 0 {
+				last = i
+			}
+		}
+
+		batchID := uuid.New()
+		cash := req.Amount
+		for i := 0; i <= last; i++ {
+			allocation := allocations[i]
+			applied := allocation.Penalty + allocation.Interest + allocation.Principal
+			if applied == 0 && i != last {
+				continue
+			}
+
+			// Credit already on the loan is used before new cash
+			drawn := min(credit, applied)
+			credit -= drawn
+			paid := applied - drawn
+			cash -= paid
+			creditAmount := -drawn
+			if i == last {
+				paid += cash
+				creditAmount += cash
+				cash = 0
+			}
+
+			payment := &models.Payment{
+				ID:              uuid.New(),
+				LoanID:          loanID,
+				InstallmentID:   installments[i].ID,
+				Amount:          paid,
+				PenaltyAmount:   allocation.Penalty,
+				InterestAmount:  allocation.Interest,
+				PrincipalAmount: allocation.Principal,
+				CreditAmount:    creditAmount,
+				Method:          req.Method,
+				Reference:       strings.TrimSpace(req.Reference),
+				PaymentDate:     req.PaymentDate,
+				PostedBy:        postedBy,
+				BatchID:         &batchID,
+				CreatedAt:       time.Now(),
+			}
+			// The key sits on the first entry so a retry finds the batch
+			if req.IdempotencyKey != "" && i == 0 {
+				payment.IdempotencyKey = &req.IdempotencyKey
+			}
+
+			if err := insertPayment(tx, payment); err != nil {
+				return err
+			}
+			if err := recalculateInstallment(tx, installments[i].ID.String(), loanID.String(), postedBy); err != nil {
+				return err
+			}
+		}
+
+		result, err = batchResult(tx, batchID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *PaymentService) GetPayment(id string) (*models.Payment, error) {
+	paymentID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payment ID")
+	}
+
+	payment, err := scanPayment(s.db.QueryRow("SELECT "+paymentColumns+paymentFrom+" WHERE p.id = ?", paymentID.String()))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("payment not found")
+		}
+		return nil, fmt.Errorf("failed to get payment: %v", err)
+	}
+
+	return payment, nil
+}
+
+// GetLoanPayments lists every ledger entry of a loan, reversals included, in
+// the order they were posted.
+func (s *PaymentService) GetLoanPayments(loanID string) ([]models.Payment, error) {
+	id, err := uuid.Parse(loanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+
+	rows, err := s.db.Query("SELECT "+paymentColumns+paymentFrom+`
+		WHERE p.loan_id = ?
+		ORDER BY p.created_at, p.receipt_number`, id.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %v", err)
+	}
+	defer rows.Close()
+
+	payments := make([]models.Payment, 0)
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %v", err)
+		}
+		payments = append(payments, *payment)
+	}
+
+	return payments, rows.Err()
+}
+
+// PaymentAllocation is how a payment on an installment was split
+type PaymentAllocation struct {
+	Penalty   int64 `json:"penalty"`
+	Interest  int64 `json:"interest"`
+	Principal int64 `json:"principal"`
+	Excess    int64 `json:"excess"` // more than the installment owes
+}
+
+// AllocateInstallmentPayment splits a payment into outstanding penalty, then
+// interest, then principal. amount_paid is applied to interest before
+// principal, so the interest still owed is interest_due less amount_paid.
+// Whatever the installment does not owe is returned as Excess.
+func AllocateInstallmentPayment(installment *models.LoanInstallment, amount int64) PaymentAllocation {
+	var allocation PaymentAllocation
+	remaining := amount
+
+	allocation.Penalty = min(remaining, max(installment.PenaltyDue-installment.PenaltyPaid, 0))
+	remaining -= allocation.Penalty
+
+	allocation.Interest = min(remaining, max(installment.InterestDue-installment.AmountPaid, 0))
+	remaining -= allocation.Interest
+
+	allocation.Principal = min(remaining, max(installment.AmountDue-installment.AmountPaid-allocation.Interest, 0))
+	remaining -= allocation.Principal
+
+	allocation.Excess = remaining
+	return allocation
+}
+
+// AllocateLoanPayment spreads amount over unpaid installments ordered oldest
+// first. Outstanding penalties on all of them are settled before any interest
+// or principal, then each installment is paid off in turn. It returns one
+// allocation per installment and the amount left over.
+func AllocateLoanPayment(installments []models.LoanInstallment, amount int64) ([]PaymentAllocation, int64) {
+	allocations := make([]PaymentAllocation, len(installments))
+	remaining := amount
+
+	for i, installment := range installments {
+		allocations[i].Penalty = min(remaining, max(installment.PenaltyDue-installment.PenaltyPaid, 0))
+		remaining -= allocations[i].Penalty
+	}
+
+	for i, installment := range installments {
+		if remaining <= 0 {
+			break
+		}
+		installment.PenaltyPaid += allocations[i].Penalty
+		allocation := AllocateInstallmentPayment(&installment, remaining)
+		allocations[i].Interest = allocation.Interest
+		allocations[i].Principal = allocation.Principal
+		remaining = allocation.Excess
+	}
+
+	return allocations, remaining
+}
+
+// batchResult rebuilds the breakdown of a loan payment from its entries
+func batchResult(tx *sql.Tx, batchID uuid.UUID) (*LoanPaymentResult, error) {
+	rows, err := tx.Query("SELECT "+paymentColumns+`, li.number, li.status`+paymentFrom+`
+		JOIN loan_installments li ON li.id = p.installment_id
+		WHERE p.batch_id = ?
+		ORDER BY li.number`, batchID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment batch: %v", err)
+	}
+	defer rows.Close()
+
+	result := &LoanPaymentResult{BatchID: batchID, Installments: make([]InstallmentAllocation, 0)}
+	for rows.Next() {
+		var number int
+		var status models.LoanInstallmentStatus
+		payment, err := scanPayment(extraColumns{rows, []interface{}{&number, &status}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %v", err)
+		}
+
+		result.LoanID = payment.LoanID
+		result.Amount += payment.Amount
+		if payment.CreditAmount < 0 {
+			result.CreditApplied -= payment.CreditAmount
+		} else {
+			result.CreditAdded += payment.CreditAmount
+		}
+		result.Installments = append(result.Installments, InstallmentAllocation{
+			InstallmentID: payment.InstallmentID,
+			Number:        number,
+			PaymentID:     payment.ID,
+			ReceiptNumber: payment.ReceiptNumber,
+			Penalty:       payment.PenaltyAmount,
+			Interest:      payment.InterestAmount,
+			Principal:     payment.PrincipalAmount,
+			Status:        status,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result.CreditBalance, err = loanCreditBalance(tx, result.LoanID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// extraColumns scans columns selected after the payment columns
+type extraColumns struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (e extraColumns) Scan(dest ...interface{}) error {
+	return e.row.Scan(append(dest, e.extra...)...)
+}
+
+func loanCreditBalance(q rowQuerier, loanID string) (int64, error) {
+	var credit int64
+	err := q.QueryRow("SELECT COALESCE(SUM(credit_amount), 0) FROM payments WHERE loan_id = ?", loanID).Scan(&credit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get loan credit: %v", err)
+	}
+	return credit, nil
+}
+
+// capitalizedArrears is what restructurings of a loan capitalized and has not
+// yet been earned, with the principal receivable it is part of
+type capitalizedArrears struct {
+	interest, penalty int64
+	principal         int64
+}
+
+func deferredArrears(q rowQuerier, loanID string) (capitalizedArrears, error) {
+	var deferred capitalizedArrears
+	err := q.QueryRow(`
+		SELECT r.interest - p.interest, r.penalty - p.penalty, l.amount + r.interest + r.penalty - p.principal
+		FROM loans l,
+		     (SELECT COALESCE(SUM(capitalized_amount - capitalized_penalty), 0) AS interest,
+		             COALESCE(SUM(capitalized_penalty), 0) AS penalty
+		      FROM loan_restructurings WHERE loan_id = ?) r,
+		     (SELECT COALESCE(SUM(capitalized_interest), 0) AS interest, COALESCE(SUM(capitalized_penalty), 0) AS penalty,
+		             COALESCE(SUM(principal_amount), 0) AS principal
+		      FROM payments WHERE loan_id = ?) p
+		WHERE l.id = ?`, loanID, loanID, loanID).Scan(&deferred.interest, &deferred.penalty, &deferred.principal)
+	if err != nil {
+		return deferred, fmt.Errorf("failed to get capitalized arrears: %v", err)
+	}
+	return deferred, nil
+}
+
+// earnedBy is the part of the capitalized arrears earned by repaying
+// principal: its share of the receivable, or all that is left once the
+// receivable is repaid
+func (c capitalizedArrears) earnedBy(principal int64) (interest, penalty int64) {
+	if c.interest <= 0 && c.penalty <= 0 {
+		return 0, 0
+	}
+	if principal >= c.principal {
+		return max(c.interest, 0), max(c.penalty, 0)
+	}
+	share := float64(principal) / float64(c.principal)
+	return int64(math.Round(float64(c.interest) * share)), int64(math.Round(float64(c.penalty) * share))
+}
+
+func unpaidInstallments(tx *sql.Tx, loanID string) ([]models.LoanInstallment, error) {
+	rows, err := tx.Query(`
+		SELECT id, loan_id, number, due_date, principal_due, interest_due, amount_due, amount_paid,
+		       penalty_due, penalty_paid, status
+		FROM loan_installments
+		WHERE loan_id = ? AND status != 'paid' AND superseded_at IS NULL
+		ORDER BY number`, loanID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installments: %v", err)
+	}
+	defer rows.Close()
+
+	installments := make([]models.LoanInstallment, 0)
+	for rows.Next() {
+		var installment models.LoanInstallment
+		err := rows.Scan(
+			&installment.ID, &installment.LoanID, &installment.Number, &installment.DueDate,
+			&installment.PrincipalDue, &installment.InterestDue, &installment.AmountDue, &installment.AmountPaid,
+			&installment.PenaltyDue, &installment.PenaltyPaid, &installment.Status)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan installment: %v", err)
+		}
+		installments = append(installments, installment)
+	}
+
+	return installments, rows.Err()
+}
+
+func (s *PaymentService) getPaymentByIdempotencyKey(tx *sql.Tx, key string) (*models.Payment, error) {
+	return scanPayment(tx.QueryRow("SELECT "+paymentColumns+paymentFrom+" WHERE p.idempotency_key = ?", key))
+}
+
+func (s *PaymentService) getInstallment(id string) (*models.LoanInstallment, error) {
+	return queryInstallment(s.db, id)
+}
+
+// rowQuerier and querier are satisfied by both the database and a transaction
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+type querier interface {
+	rowQuerier
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryInstallment(q rowQuerier, id string) (*models.LoanInstallment, error) {
+	var installment models.LoanInstallment
+	err := q.QueryRow(`
+		SELECT id, loan_id, number, due_date, principal_due, interest_due, amount_due, amount_paid,
+		       outstanding_balance, penalty_due, penalty_paid, status, paid_at, settled_at,
+		       schedule_version, superseded_at, dpd, created_at, updated_at
+		FROM loan_installments
+		WHERE id = ?`, id).Scan(
+		&installment.ID, &installment.LoanID, &installment.Number,
+		&installment.DueDate, &installment.PrincipalDue, &installment.InterestDue,
+		&installment.AmountDue, &installment.AmountPaid, &installment.OutstandingBalance,
+		&installment.PenaltyDue, &installment.PenaltyPaid,
+		&installment.Status, &installment.PaidAt, &installment.SettledAt,
+		&installment.ScheduleVersion, &installment.SupersededAt, &installment.DPD,
+		&installment.CreatedAt, &installment.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("installment not found")
+		}
+		return nil, fmt.Errorf("failed to get installment: %v", err)
+	}
+	return &installment, nil
+}
+
+func insertPayment(tx *sql.Tx, payment *models.Payment) error {
+	receiptNumber, err := nextReceiptNumber(tx, payment.CreatedAt)
+	if err != nil {
+		return err
+	}
+	payment.ReceiptNumber = receiptNumber
+
+	// A reversal carries the negated amounts of the original instead
+	if payment.ReversalOf == nil && payment.PrincipalAmount > 0 {
+		deferred, err := deferredArrears(tx, payment.LoanID.String())
+		if err != nil {
+			return err
+		}
+		payment.CapitalizedInterest, payment.CapitalizedPenalty = deferred.earnedBy(payment.PrincipalAmount)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO payments (id, loan_id, installment_id, receipt_number, amount, penalty_amount,
+		                      interest_amount, principal_amount, fee_amount, credit_amount, capitalized_interest,
+		                      capitalized_penalty, method, reference, payment_date, posted_by, idempotency_key,
+		                      batch_id, reversal_of, notes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?, NULLIF(?, ''), ?)`,
+		payment.ID.String(), payment.LoanID.String(), payment.InstallmentID.String(), payment.ReceiptNumber,
+		payment.Amount, payment.PenaltyAmount, payment.InterestAmount, payment.PrincipalAmount,
+		payment.FeeAmount, payment.CreditAmount, payment.CapitalizedInterest, payment.CapitalizedPenalty,
+		string(payment.Method), payment.Reference, payment.PaymentDate,
+		nullableUUID(payment.PostedBy), payment.IdempotencyKey, nullableUUID(payment.BatchID),
+		nullableUUID(payment.ReversalOf), payment.Notes, payment.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record payment: %v", err)
+	}
+
+	var contractNumber string
+	err = tx.QueryRow("SELECT contract_number FROM loans WHERE id = ?", payment.LoanID.String()).Scan(&contractNumber)
+	if err != nil {
+		return fmt.Errorf("failed to get loan: %v", err)
+	}
+	entry := accounting.Payment(*payment, contractNumber)
+	return postJournalEntry(tx, &entry)
+}
+
+// nextReceiptNumber numbers receipts per day as KW-YYYYMMDD-NNNN
+func nextReceiptNumber(tx *sql.Tx, at time.Time) (string, error) {
+	prefix := fmt.Sprintf("KW-%s-", at.Format("20060102"))
+
+	var last int
+	err := tx.QueryRow(`
+		SELECT COALESCE(MAX(CAST(substr(receipt_number, ?) AS INTEGER)), 0)
+		FROM payments
+		WHERE receipt_number LIKE ?`, len(prefix)+1, prefix+"%").Scan(&last)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate receipt number: %v", err)
+	}
+
+	return fmt.Sprintf("%s%04d", prefix, last+1), nil
+}
+
+// recalculateInstallment derives amount_paid, penalty_paid and the status of
+// an installment from its ledger entries, then refreshes the loan status on
+// behalf of actor. An installment closed by early settlement counts as paid
+// even though less than amount_due was collected.
+func recalculateInstallment(tx *sql.Tx, installmentID, loanID string, actor *uuid.UUID) error {
+	var paid, penaltyPaid int64
+	err := tx.QueryRow(`
+		SELECT COALESCE(SUM(interest_amount + principal_amount), 0), COALESCE(SUM(penalty_amount), 0)
+		FROM payments
+		WHERE installment_id = ?`, installmentID).Scan(&paid, &penaltyPaid)
+	if err != nil {
+		return fmt.Errorf("failed to sum payments: %v", err)
+	}
+
+	var amountDue, penaltyDue int64
+	var dueDate time.Time
+	var settled bool
+	err = tx.QueryRow("SELECT amount_due, penalty_due, due_date, settled_at IS NOT NULL FROM loan_installments WHERE id = ?", installmentID).
+		Scan(&amountDue, &penaltyDue, &dueDate, &settled)
+	if err != nil {
+		return fmt.Errorf("failed to get installment: %v", err)
+	}
+
+	today := dateOf(time.Now())
+	status := models.InstallmentStatusPending
+	var paidAt *time.Time
+	dpd := 0
+	switch {
+	case settled || paid >= amountDue && penaltyPaid >= penaltyDue:
+		status = models.InstallmentStatusPaid
+		paidAt, err = lastPaymentDate(tx, installmentID)
+		if err != nil {
+			return err
+		}
+	case paid > 0 || penaltyPaid > 0:
+		status = models.InstallmentStatusPartial
+	case dateOf(dueDate).Before(today):
+		status = models.InstallmentStatusOverdue
+	}
+	if status != models.InstallmentStatusPaid && dateOf(dueDate).Before(today) {
+		dpd = int(today.Sub(dateOf(dueDate)).Hours() / 24)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE loan_installments
+		SET amount_paid = ?, penalty_paid = ?, status = ?, paid_at = ?, dpd = ?
+		WHERE id = ?`,
+		paid, penaltyPaid, string(status), paidAt, dpd, installmentID)
+	if err != nil {
+		return fmt.Errorf("failed to update installment: %v", err)
+	}
+
+	return refreshLoanStatus(tx, loanID, actor)
+}
+
+// lastPaymentDate is the date of the latest payment on an installment that
+// has not been reversed.
+func lastPaymentDate(tx *sql.Tx, installmentID string) (*time.Time, error) {
+	var paymentDate time.Time
+	err := tx.QueryRow(`
+		SELECT p.payment_date
+		FROM payments p
+		WHERE p.installment_id = ? AND p.reversal_of IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM payments r WHERE r.reversal_of = p.id)
+		ORDER BY p.payment_date DESC
+		LIMIT 1`, installmentID).Scan(&paymentDate)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last payment date: %v", err)
+	}
+	return &paymentDate, nil
+}
+
+// refreshLoanStatus completes a running loan once every installment is paid
+// and reopens a completed loan when a reversal leaves something unpaid.
+// Installments already marked as settled count as paid.
+func refreshLoanStatus(tx *sql.Tx, loanID string, actor *uuid.UUID) error {
+	var status models.LoanStatus
+	var unpaidCount int
+	err := tx.QueryRow(`
+		SELECT l.status, (SELECT COUNT(*) FROM loan_installments
+		                  WHERE loan_id = l.id AND status != 'paid' AND settled_at IS NULL AND superseded_at IS NULL)
+		FROM loans l
+		WHERE l.id = ?`, loanID).Scan(&status, &unpaidCount)
+	if err != nil {
+		return fmt.Errorf("failed to get loan status: %v", err)
+	}
+
+	switch {
+	case unpaidCount == 0 && (status == models.LoanStatusDisbursed || status == models.LoanStatusActive ||
+		status == models.LoanStatusDefaulted):
+		return transitionLoanStatus(tx, loanID, models.LoanStatusCompleted, actor, "all installments paid")
+	case unpaidCount > 0 && status == models.LoanStatusCompleted:
+		return transitionLoanStatus(tx, loanID, models.LoanStatusActive, actor, "payment reversed")
+	}
+	return nil
+}
+
+func scanPayment(row rowScanner) (*models.Payment, error) {
+	var payment models.Payment
+	var postedBy, batchID, reversalOf, reversedBy sql.NullString
+	var idempotencyKey sql.NullString
+	err := row.Scan(
+		&payment.ID, &payment.LoanID, &payment.InstallmentID, &payment.ReceiptNumber,
+		&payment.Amount, &payment.PenaltyAmount, &payment.InterestAmount, &payment.PrincipalAmount,
+		&payment.FeeAmount, &payment.CreditAmount, &payment.CapitalizedInterest, &payment.CapitalizedPenalty, &payment.Method, &payment.Reference, &payment.PaymentDate, &postedBy,
+		&idempotencyKey, &batchID, &reversalOf, &reversedBy, &payment.Notes, &payment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.PostedBy = uuidFromNull(postedBy)
+	payment.BatchID = uuidFromNull(batchID)
+	payment.ReversalOf = uuidFromNull(reversalOf)
+	payment.ReversedBy = uuidFromNull(reversedBy)
+	if idempotencyKey.Valid {
+		payment.IdempotencyKey = &idempotencyKey.String
+	}
+
+	return &payment, nil
+}
+
+func validatePaymentMethod(method models.PaymentMethod) error {
+	switch method {
+	case models.PaymentMethodCash, models.PaymentMethodTransfer, models.PaymentMethodQRIS:
+		return nil
+	}
+	return fmt.Errorf("invalid payment method %q", method)
+}
+
+func parseOptionalUUID(value, name string) (*uuid.UUID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &id, nil
+}
+
+func uuidFromNull(value sql.NullString) *uuid.UUID {
+	if !value.Valid {
+		return nil
+	}
+	id, err := uuid.Parse(value.String)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+func nullableUUID(id *uuid.UUID) *string {
+	if id == nil {
+		return nil
+	}
+	value := id.String()
+	return &value
+}
+
+func nullableString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
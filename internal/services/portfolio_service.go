@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"koperasi-app/internal/database"
+)
+
+// PortfolioService computes the loan portfolio figures for the dashboard
+type PortfolioService struct {
+	db *database.DB
+}
+
+func NewPortfolioService(db *database.DB) *PortfolioService {
+	return &PortfolioService{db: db}
+}
+
+type PortfolioRequest struct {
+	OwnerUserID string    `json:"owner_user_id"` // karyawan owning the customers' referral codes
+	City        string    `json:"city"`
+	Province    string    `json:"province"`
+	PeriodStart time.Time `json:"period_start"` // collection period; defaults to the current month
+	PeriodEnd   time.Time `json:"period_end"`
+}
+
+// PortfolioAtRisk is the outstanding principal of loans more than a number of
+// days past due
+type PortfolioAtRisk struct {
+	Loans                int     `json:"loans"`
+	OutstandingPrincipal int64   `json:"outstanding_principal"`
+	Ratio                float64 `json:"ratio"` // percent of the portfolio's outstanding principal
+}
+
+// AgingBucket groups running loans by the days past due of their oldest
+// unpaid installment
+type AgingBucket struct {
+	Name                 string  `json:"name"`
+	MinDPD               int     `json:"min_dpd"`
+	MaxDPD               int     `json:"max_dpd"` // -1 for the open-ended last bucket
+	Loans                int     `json:"loans"`
+	OutstandingPrincipal int64   `json:"outstanding_principal"`
+	Ratio                float64 `json:"ratio"`
+}
+
+// CollectionRate compares what fell due in a period with what was collected
+// on those installments by the end of it
+type CollectionRate struct {
+	PeriodStart     time.Time `json:"period_start"`
+	PeriodEnd       time.Time `json:"period_end"`
+	AmountDue       int64     `json:"amount_due"`       // principal and interest due in the period
+	AmountCollected int64     `json:"amount_collected"` // principal and interest paid on it, net of reversals
+	Rate            float64   `json:"rate"`
+}
+
+type PortfolioReport struct {
+	AsOf                 time.Time       `json:"as_of"`
+	Loans                int             `json:"loans"` // disbursed, active and defaulted loans
+	OutstandingPrincipal int64           `json:"outstanding_principal"`
+	PAR1                 PortfolioAtRisk `json:"par1"`
+	PAR30                PortfolioAtRisk `json:"par30"`
+	PAR90                PortfolioAtRisk `json:"par90"`
+	Aging                []AgingBucket   `json:"aging"`
+	Collection           CollectionRate  `json:"collection"`
+}
+
+// agingBuckets are the DPD ranges of the aging analysis
+var agingBuckets = []AgingBucket{
+	{Name: "current", MinDPD: 0, MaxDPD: 0},
+	{Name: "1-30", MinDPD: 1, MaxDPD: 30},
+	{Name: "31-60", MinDPD: 31, MaxDPD: 60},
+	{Name: "61-90", MinDPD: 61, MaxDPD: 90},
+	{Name: "90+", MinDPD: 91, MaxDPD: -1},
+}
+
+// GetPortfolioReport returns portfolio at risk, aging and the collection rate
+// for the loans matching the request's filters. PAR1, PAR30 and PAR90 cover
+// loans whose oldest unpaid installment is more than 0, 30 and 90 days past
+// due.
+func (s *PortfolioService) GetPortfolioReport(req PortfolioRequest) (*PortfolioReport, error) {
+	now := time.Now()
+	filter, args := portfolioFilter(req)
+
+	exposures, err := runningLoanExposures(s.db, now, filter, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PortfolioReport{AsOf: dateOf(now), Aging: make([]AgingBucket, len(agingBuckets))}
+	copy(report.Aging, agingBuckets)
+	for _, exposure := range exposures {
+		report.Loans++
+		report.OutstandingPrincipal += exposure.outstandingPrincipal
+
+		for _, par := range []struct {
+			risk   *PortfolioAtRisk
+			minDPD int
+		}{{&report.PAR1, 1}, {&report.PAR30, 31}, {&report.PAR90, 91}} {
+			if exposure.dpd >= par.minDPD {
+				par.risk.Loans++
+				par.risk.OutstandingPrincipal += exposure.outstandingPrincipal
+			}
+		}
+
+		for i := range report.Aging {
+			bucket := &report.Aging[i]
+			if exposure.dpd >= bucket.MinDPD && (bucket.MaxDPD < 0 || exposure.dpd <= bucket.MaxDPD) {
+				bucket.Loans++
+				bucket.OutstandingPrincipal += exposure.outstandingPrincipal
+				break
+			}
+		}
+	}
+
+	report.PAR1.Ratio = percentOf(report.PAR1.OutstandingPrincipal, report.OutstandingPrincipal)
+	report.PAR30.Ratio = percentOf(report.PAR30.OutstandingPrincipal, report.OutstandingPrincipal)
+	report.PAR90.Ratio = percentOf(report.PAR90.OutstandingPrincipal, report.OutstandingPrincipal)
+	for i := range report.Aging {
+		report.Aging[i].Ratio = percentOf(report.Aging[i].OutstandingPrincipal, report.OutstandingPrincipal)
+	}
+
+	collection, err := s.collectionRate(req, filter, args)
+	if err != nil {
+		return nil, err
+	}
+	report.Collection = *collection
+
+	return report, nil
+}
+
+// collectionRate looks at the installments that fell due in the period, as
+// long as they had not been replaced by a restructuring before their due date
+func (s *PortfolioService) collectionRate(req PortfolioRequest, filter string, args []interface{}) (*CollectionRate, error) {
+	rate := &CollectionRate{}
+	var err error
+	rate.PeriodStart, rate.PeriodEnd, err = reportPeriod(req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := rate.PeriodStart.Format("2006-01-02"), rate.PeriodEnd.Format("2006-01-02")
+	queryArgs := append([]interface{}{end, start, end}, args...)
+	err = s.db.QueryRow(`
+		SELECT COALESCE(SUM(li.amount_due), 0),
+		       COALESCE(SUM((SELECT COALESCE(SUM(p.principal_amount + p.interest_amount), 0) FROM payments p
+		                     WHERE p.installment_id = li.id AND date(p.payment_date) <= date(?))), 0)
+		FROM loan_installments li
+		JOIN loans l ON li.loan_id = l.id
+		JOIN customers c ON l.customer_id = c.id
+		LEFT JOIN referral_codes rc ON c.referral_code_id = rc.id
+		WHERE date(li.due_date) BETWEEN date(?) AND date(?)
+		  AND (li.superseded_at IS NULL OR date(li.superseded_at) > date(li.due_date))`+filter,
+		queryArgs...).Scan(&rate.AmountDue, &rate.AmountCollected)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate collection rate: %v", err)
+	}
+	rate.Rate = percentOf(rate.AmountCollected, rate.AmountDue)
+
+	return rate, nil
+}
+
+// reportPeriod fills in a report period, which defaults to the current month
+// up to today
+func reportPeriod(start, end time.Time) (time.Time, time.Time, error) {
+	now := time.Now()
+	if start.IsZero() {
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	if end.IsZero() {
+		end = now
+	}
+	start, end = dateOf(start), dateOf(end)
+	if end.Before(start) {
+		return start, end, fmt.Errorf("period end is before period start")
+	}
+	return start, end, nil
+}
+
+// portfolioFilter narrows queries joining loans l, customers c and
+// referral_codes rc to the request's karyawan and region
+func portfolioFilter(req PortfolioRequest) (string, []interface{}) {
+	var filter strings.Builder
+	args := make([]interface{}, 0)
+	if req.OwnerUserID != "" {
+		filter.WriteString(" AND rc.owner_user_id = ?")
+		args = append(args, req.OwnerUserID)
+	}
+	if city := strings.TrimSpace(req.City); city != "" {
+		filter.WriteString(" AND c.city = ? COLLATE NOCASE")
+		args = append(args, city)
+	}
+	if province := strings.TrimSpace(req.Province); province != "" {
+		filter.WriteString(" AND c.province = ? COLLATE NOCASE")
+		args = append(args, province)
+	}
+	return filter.String(), args
+}
+
+// percentOf returns part as a percentage of whole, rounded to two decimals
+func percentOf(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*10000/float64(whole)) / 100
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+)
+
+// RecoveryService posts and reports collections on written-off loans. They
+// are kept apart from the payment ledger, so the installments of a loan stay
+// as they were when it was written off.
+type RecoveryService struct {
+	db *database.DB
+}
+
+func NewRecoveryService(db *database.DB) *RecoveryService {
+	return &RecoveryService{db: db}
+}
+
+type RecoveryRequest struct {
+	LoanID       string               `json:"loan_id"`
+	Amount       int64                `json:"amount"`
+	RecoveryDate time.Time            `json:"recovery_date"` // defaults to now
+	Method       models.PaymentMethod `json:"method"`        // defaults to cash
+	Reference    string               `json:"reference"`
+	Notes        string               `json:"notes"`
+	PostedBy     string               `json:"posted_by"`
+}
+
+type RecoveryReportRequest struct {
+	PeriodStart time.Time `json:"period_start"` // defaults to the start of the current month
+	PeriodEnd   time.Time `json:"period_end"`   // defaults to today
+}
+
+// WrittenOffLoanRecovery is what has been recovered on one written-off loan
+type WrittenOffLoanRecovery struct {
+	LoanID            uuid.UUID `json:"loan_id"`
+	ContractNumber    string    `json:"contract_number"`
+	CustomerName      string    `json:"customer_name"`
+	WrittenOffAt      time.Time `json:"written_off_at"`
+	WrittenOff        int64     `json:"written_off"` // principal, interest and penalty written off
+	RecoveredInPeriod int64     `json:"recovered_in_period"`
+	RecoveredToDate   int64     `json:"recovered_to_date"` // up to the end of the period
+	Remaining         int64     `json:"remaining"`
+}
+
+type RecoveryReport struct {
+	PeriodStart       time.Time                `json:"period_start"`
+	PeriodEnd         time.Time                `json:"period_end"`
+	Loans             []WrittenOffLoanRecovery `json:"loans"`      // loans written off by the end of the period
+	Recoveries        []models.LoanRecovery    `json:"recoveries"` // posted in the period
+	WrittenOff        int64                    `json:"written_off"`
+	RecoveredInPeriod int64                    `json:"recovered_in_period"`
+	RecoveredToDate   int64                    `json:"recovered_to_date"`
+	RecoveryRate      float64                  `json:"recovery_rate"` // recovered to date, in percent of written off
+}
+
+const recoveryColumns = `
+	id, loan_id, receipt_number, amount, method, COALESCE(reference, ''), recovery_date, posted_by,
+	COALESCE(notes, ''), created_at`
+
+// PostRecovery records an amount collected on a written-off loan. Recoveries
+// cannot add up to more than was written off.
+func (s *RecoveryService) PostRecovery(req RecoveryRequest) (*models.LoanRecovery, error) {
+	loanID, err := uuid.Parse(req.LoanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("recovery amount must be greater than 0")
+	}
+
+	if req.Method == "" {
+		req.Method = models.PaymentMethodCash
+	}
+	if err := validatePaymentMethod(req.Method); err != nil {
+		return nil, err
+	}
+
+	postedBy, err := parseOptionalUUID(req.PostedBy, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RecoveryDate.IsZero() {
+		req.RecoveryDate = time.Now()
+	}
+
+	var recovery *models.LoanRecovery
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		var status models.LoanStatus
+		var writtenOffAt *time.Time
+		var contractNumber string
+		var writtenOff, recovered int64
+		err := tx.QueryRow(`
+			SELECT l.status, l.written_off_at, l.contract_number,
+			       l.written_off_principal + l.written_off_interest + l.written_off_penalty,
+			       (SELECT COALESCE(SUM(amount), 0) FROM loan_recoveries WHERE loan_id = l.id)
+			FROM loans l
+			WHERE l.id = ?`, loanID.String()).Scan(&status, &writtenOffAt, &contractNumber, &writtenOff, &recovered)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("loan not found")
+			}
+			return fmt.Errorf("failed to get loan: %v", err)
+		}
+		if status != models.LoanStatusWrittenOff || writtenOffAt == nil {
+			return fmt.Errorf("recoveries can only be posted on written-off loans")
+		}
+		if dateOf(req.RecoveryDate).Before(dateOf(*writtenOffAt)) {
+			return fmt.Errorf("recovery date is before the loan was written off")
+		}
+		if remaining := writtenOff - recovered; req.Amount > remaining {
+			return fmt.Errorf("recovery amount is more than the %d still outstanding from the write-off", remaining)
+		}
+
+		recovery = &models.LoanRecovery{
+			ID:           uuid.New(),
+			LoanID:       loanID,
+			Amount:       req.Amount,
+			Method:       req.Method,
+			Reference:    strings.TrimSpace(req.Reference),
+			RecoveryDate: req.RecoveryDate,
+			PostedBy:     postedBy,
+			Notes:        strings.TrimSpace(req.Notes),
+			CreatedAt:    time.Now(),
+		}
+		recovery.ReceiptNumber, err = nextRecoveryNumber(tx, recovery.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO loan_recoveries (id, loan_id, receipt_number, amount, method, reference, recovery_date,
+			                             posted_by, notes, created_at)
+			VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, NULLIF(?, ''), ?)`,
+			recovery.ID.String(), recovery.LoanID.String(), recovery.ReceiptNumber, recovery.Amount,
+			string(recovery.Method), recovery.Reference, recovery.RecoveryDate, nullableUUID(recovery.PostedBy),
+			recovery.Notes, recovery.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to record recovery: %v", err)
+		}
+
+		entry := accounting.Recovery(*recovery, contractNumber)
+		return postJournalEntry(tx, &entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recovery, nil
+}
+
+// GetLoanRecoveries lists the recoveries of a loan, oldest first
+func (s *RecoveryService) GetLoanRecoveries(loanID string) ([]models.LoanRecovery, error) {
+	id, err := uuid.Parse(loanID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loan ID")
+	}
+
+	return s.queryRecoveries("loan_id = ?", id.String())
+}
+
+// GetRecoveryReport lists every loan written off by the end of the period
+// with what was recovered on it in the period and to date, and the
+// recoveries posted in the period.
+func (s *RecoveryService) GetRecoveryReport(req RecoveryReportRequest) (*RecoveryReport, error) {
+	start, end, err := reportPeriod(req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	startDate, endDate := start.Format("2006-01-02"), end.Format("2006-01-02")
+
+	rows, err := s.db.Query(`
+		SELECT l.id, l.contract_number, c.name, l.written_off_at,
+		       l.written_off_principal + l.written_off_interest + l.written_off_penalty,
+		       (SELECT COALESCE(SUM(amount), 0) FROM loan_recoveries
+		        WHERE loan_id = l.id AND date(recovery_date) BETWEEN date(?) AND date(?)),
+		       (SELECT COALESCE(SUM(amount), 0) FROM loan_recoveries
+		        WHERE loan_id = l.id AND date(recovery_date) <= date(?))
+		FROM loans l
+		JOIN customers c ON l.customer_id = c.id
+		WHERE l.written_off_at IS NOT NULL AND date(l.written_off_at) <= date(?)
+		ORDER BY l.written_off_at`, startDate, endDate, endDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query written-off loans: %v", err)
+	}
+	defer rows.Close()
+
+	report := &RecoveryReport{PeriodStart: start, PeriodEnd: end, Loans: make([]WrittenOffLoanRecovery, 0)}
+	for rows.Next() {
+		var loan WrittenOffLoanRecovery
+		err := rows.Scan(&loan.LoanID, &loan.ContractNumber, &loan.CustomerName, &loan.WrittenOffAt,
+			&loan.WrittenOff, &loan.RecoveredInPeriod, &loan.RecoveredToDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan written-off loan: %v", err)
+		}
+		loan.Remaining = loan.WrittenOff - loan.RecoveredToDate
+
+		report.Loans = append(report.Loans, loan)
+		report.WrittenOff += loan.WrittenOff
+		report.RecoveredInPeriod += loan.RecoveredInPeriod
+		report.RecoveredToDate += loan.RecoveredToDate
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	report.RecoveryRate = percentOf(report.RecoveredToDate, report.WrittenOff)
+
+	report.Recoveries, err = s.queryRecoveries("date(recovery_date) BETWEEN date(?) AND date(?)", startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (s *RecoveryService) queryRecoveries(condition string, args ...interface{}) ([]models.LoanRecovery, error) {
+	rows, err := s.db.Query("SELECT "+recoveryColumns+" FROM loan_recoveries WHERE "+condition+
+		" ORDER BY recovery_date, created_at", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recoveries: %v", err)
+	}
+	defer rows.Close()
+
+	recoveries := make([]models.LoanRecovery, 0)
+	for rows.Next() {
+		var recovery models.LoanRecovery
+		var postedBy sql.NullString
+		err := rows.Scan(&recovery.ID, &recovery.LoanID, &recovery.ReceiptNumber, &recovery.Amount, &recovery.Method,
+			&recovery.Reference, &recovery.RecoveryDate, &postedBy, &recovery.Notes, &recovery.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recovery: %v", err)
+		}
+		recovery.PostedBy = uuidFromNull(postedBy)
+		recoveries = append(recoveries, recovery)
+	}
+
+	return recoveries, rows.Err()
+}
+
+// nextRecoveryNumber numbers recovery receipts per day as RC-YYYYMMDD-NNNN
+func nextRecoveryNumber(tx *sql.Tx, at time.Time) (string, error) {
+	prefix := fmt.Sprintf("RC-%s-", at.Format("20060102"))
+
+	var last int
+	err := tx.QueryRow(`
+		SELECT COALESCE(MAX(CAST(substr(receipt_number, ?) AS INTEGER)), 0)
+		FROM loan_recoveries
+		WHERE receipt_number LIKE ?`, len(prefix)+1, prefix+"%").Scan(&last)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate receipt number: %v", err)
+	}
+
+	return fmt.Sprintf("%s%04d", prefix, last+1), nil
+}
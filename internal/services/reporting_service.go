@@ -0,0 +1,682 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+)
+
+// ReportingService draws up the financial statements from the general
+// ledger for the pengurus and the members' meeting (RAT): the trial balance,
+// the balance sheet (neraca) and the income statement (laba rugi), each
+// compared with the prior period.
+type ReportingService struct {
+	db  *database.DB
+	cfg *config.Config
+}
+
+func NewReportingService(db *database.DB, cfg *config.Config) *ReportingService {
+	return &ReportingService{db: db, cfg: cfg}
+}
+
+type FinancialReport string
+
+const (
+	FinancialReportTrialBalance    FinancialReport = "trial_balance"
+	FinancialReportBalanceSheet    FinancialReport = "balance_sheet"
+	FinancialReportIncomeStatement FinancialReport = "income_statement"
+)
+
+type ReportFormat string
+
+const (
+	ReportFormatCSV ReportFormat = "csv"
+	ReportFormatPDF ReportFormat = "pdf"
+)
+
+type FinancialReportRequest struct {
+	PeriodStart time.Time `json:"period_start"` // defaults to the start of the current month
+	PeriodEnd   time.Time `json:"period_end"`   // defaults to today
+}
+
+type ReportExportRequest struct {
+	Report      FinancialReport `json:"report"`
+	Format      ReportFormat    `json:"format"`
+	PeriodStart time.Time       `json:"period_start"`
+	PeriodEnd   time.Time       `json:"period_end"`
+}
+
+// TrialBalanceLine is an account's opening balance, movements and closing
+// balance in the period. A balance is on the debit or the credit side.
+type TrialBalanceLine struct {
+	AccountCode   string               `json:"account_code"`
+	AccountName   string               `json:"account_name"`
+	AccountType   models.GLAccountType `json:"account_type"`
+	OpeningDebit  int64                `json:"opening_debit"`
+	OpeningCredit int64                `json:"opening_credit"`
+	Debit         int64                `json:"debit"`
+	Credit        int64                `json:"credit"`
+	ClosingDebit  int64                `json:"closing_debit"`
+	ClosingCredit int64                `json:"closing_credit"`
+	PriorDebit    int64                `json:"prior_debit"` // movements in the prior period
+	PriorCredit   int64                `json:"prior_credit"`
+}
+
+type TrialBalance struct {
+	PeriodStart      time.Time          `json:"period_start"`
+	PeriodEnd        time.Time          `json:"period_end"`
+	PriorPeriodStart time.Time          `json:"prior_period_start"`
+	PriorPeriodEnd   time.Time          `json:"prior_period_end"`
+	Lines            []TrialBalanceLine `json:"lines"`
+	Totals           TrialBalanceLine   `json:"totals"`
+	Balanced         bool               `json:"balanced"` // each pair of debit and credit totals agrees
+}
+
+// StatementLine is an account's amount in a statement. Lines without an
+// account code are computed, such as the surplus of the current year.
+type StatementLine struct {
+	AccountCode string `json:"account_code"`
+	AccountName string `json:"account_name"`
+	Amount      int64  `json:"amount"`
+	PriorAmount int64  `json:"prior_amount"`
+}
+
+type StatementSection struct {
+	Name       string          `json:"name"`
+	Lines      []StatementLine `json:"lines"`
+	Total      int64           `json:"total"`
+	PriorTotal int64           `json:"prior_total"`
+}
+
+// BalanceSheet is the neraca as of the end of the period, compared with the
+// end of the prior period
+type BalanceSheet struct {
+	AsOf                        time.Time        `json:"as_of"`
+	PriorAsOf                   time.Time        `json:"prior_as_of"`
+	Assets                      StatementSection `json:"assets"`
+	Liabilities                 StatementSection `json:"liabilities"`
+	Equity                      StatementSection `json:"equity"`
+	TotalLiabilitiesEquity      int64            `json:"total_liabilities_equity"`
+	PriorTotalLiabilitiesEquity int64            `json:"prior_total_liabilities_equity"`
+	Balanced                    bool             `json:"balanced"`
+}
+
+// IncomeStatement is the laba rugi of the period. Its net surplus is the
+// koperasi's SHU before distribution.
+type IncomeStatement struct {
+	PeriodStart      time.Time        `json:"period_start"`
+	PeriodEnd        time.Time        `json:"period_end"`
+	PriorPeriodStart time.Time        `json:"prior_period_start"`
+	PriorPeriodEnd   time.Time        `json:"prior_period_end"`
+	Income           StatementSection `json:"income"`
+	Expenses         StatementSection `json:"expenses"`
+	NetSurplus       int64            `json:"net_surplus"`
+	PriorNetSurplus  int64            `json:"prior_net_surplus"`
+}
+
+// ledgerTotals are the debits and credits booked to an account
+type ledgerTotals struct {
+	debit  int64
+	credit int64
+}
+
+// PriorPeriod is the period a report is compared with. A period starting on
+// the first of a month is compared with the same stretch of months before
+// it, so a month is compared with the previous month and a year with the
+// previous year. Any other period is compared with as many days just before it.
+func PriorPeriod(start, end time.Time) (time.Time, time.Time) {
+	start, end = dateOf(start), dateOf(end)
+
+	if start.Day() == 1 {
+		months := (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+		priorStart := start.AddDate(0, -months, 0)
+
+		// End on the same day of the month, or the last day when the
+		// period ends on a month end or the earlier month is shorter
+		lastDay := time.Date(end.Year(), end.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+		priorMonthEnd := time.Date(end.Year(), end.Month()-time.Month(months)+1, 0, 0, 0, 0, 0, time.UTC)
+		priorEnd := time.Date(priorMonthEnd.Year(), priorMonthEnd.Month(), end.Day(), 0, 0, 0, 0, time.UTC)
+		if end.Equal(lastDay) || priorEnd.After(priorMonthEnd) {
+			priorEnd = priorMonthEnd
+		}
+		return priorStart, priorEnd
+	}
+
+	days := int(end.Sub(start).Hours()/24) + 1
+	priorEnd := start.AddDate(0, 0, -1)
+	return priorEnd.AddDate(0, 0, 1-days), priorEnd
+}
+
+// TrialBalance lists the accounts with a balance or movements in the period
+// or the prior period
+func (s *ReportingService) TrialBalance(req FinancialReportRequest) (*TrialBalance, error) {
+	start, end, err := reportPeriod(req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	priorStart, priorEnd := PriorPeriod(start, end)
+
+	accounts, err := NewAccountingService(s.db).ListGLAccounts()
+	if err != nil {
+		return nil, err
+	}
+	opening, err := s.ledgerTotals(time.Time{}, start.AddDate(0, 0, -1), true)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.ledgerTotals(start, end, true)
+	if err != nil {
+		return nil, err
+	}
+	prior, err := s.ledgerTotals(priorStart, priorEnd, true)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TrialBalance{
+		PeriodStart:      start,
+		PeriodEnd:        end,
+		PriorPeriodStart: priorStart,
+		PriorPeriodEnd:   priorEnd,
+		Lines:            make([]TrialBalanceLine, 0, len(accounts)),
+	}
+	for _, account := range accounts {
+		o, c, p := opening[account.Code], current[account.Code], prior[account.Code]
+		if o == (ledgerTotals{}) && c == (ledgerTotals{}) && p == (ledgerTotals{}) {
+			continue
+		}
+
+		line := TrialBalanceLine{
+			AccountCode: account.Code,
+			AccountName: account.Name,
+			AccountType: account.Type,
+			Debit:       c.debit,
+			Credit:      c.credit,
+			PriorDebit:  p.debit,
+			PriorCredit: p.credit,
+		}
+		line.OpeningDebit, line.OpeningCredit = balanceSides(o.debit, o.credit)
+		line.ClosingDebit, line.ClosingCredit = balanceSides(o.debit+c.debit, o.credit+c.credit)
+		report.Lines = append(report.Lines, line)
+
+		totals := &report.Totals
+		totals.OpeningDebit += line.OpeningDebit
+		totals.OpeningCredit += line.OpeningCredit
+		totals.Debit += line.Debit
+		totals.Credit += line.Credit
+		totals.ClosingDebit += line.ClosingDebit
+		totals.ClosingCredit += line.ClosingCredit
+		totals.PriorDebit += line.PriorDebit
+		totals.PriorCredit += line.PriorCredit
+	}
+	totals := report.Totals
+	report.Balanced = totals.OpeningDebit == totals.OpeningCredit && totals.Debit == totals.Credit &&
+		totals.ClosingDebit == totals.ClosingCredit && totals.PriorDebit == totals.PriorCredit
+
+	return report, nil
+}
+
+// BalanceSheet draws up the neraca as of the end of the period. The surplus
+// of earlier years is shown under SHU Belum Dibagi, whether or not they have
+// been closed, and that of the current year on its own line.
+func (s *ReportingService) BalanceSheet(req FinancialReportRequest) (*BalanceSheet, error) {
+	start, end, err := reportPeriod(req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	_, priorEnd := PriorPeriod(start, end)
+
+	accounts, err := NewAccountingService(s.db).ListGLAccounts()
+	if err != nil {
+		return nil, err
+	}
+	current, currentSurplus, err := s.positionAt(accounts, end)
+	if err != nil {
+		return nil, err
+	}
+	prior, priorSurplus, err := s.positionAt(accounts, priorEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BalanceSheet{
+		AsOf:        end,
+		PriorAsOf:   priorEnd,
+		Assets:      statementSection("Aset", accounts, models.GLAccountAsset, current, prior),
+		Liabilities: statementSection("Kewajiban", accounts, models.GLAccountLiability, current, prior),
+		Equity:      statementSection("Ekuitas", accounts, models.GLAccountEquity, current, prior),
+	}
+	if currentSurplus != 0 || priorSurplus != 0 {
+		report.Equity.Lines = append(report.Equity.Lines, StatementLine{
+			AccountName: "SHU Tahun Berjalan",
+			Amount:      currentSurplus,
+			PriorAmount: priorSurplus,
+		})
+		report.Equity.Total += currentSurplus
+		report.Equity.PriorTotal += priorSurplus
+	}
+	report.TotalLiabilitiesEquity = report.Liabilities.Total + report.Equity.Total
+	report.PriorTotalLiabilitiesEquity = report.Liabilities.PriorTotal + report.Equity.PriorTotal
+	report.Balanced = report.Assets.Total == report.TotalLiabilitiesEquity &&
+		report.Assets.PriorTotal == report.PriorTotalLiabilitiesEquity
+
+	return report, nil
+}
+
+// IncomeStatement draws up the laba rugi of the period. Year-end closing
+// entries are left out, so a closed year still shows what it earned.
+func (s *ReportingService) IncomeStatement(req FinancialReportRequest) (*IncomeStatement, error) {
+	start, end, err := reportPeriod(req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+	priorStart, priorEnd := PriorPeriod(start, end)
+
+	accounts, err := NewAccountingService(s.db).ListGLAccounts()
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.ledgerTotals(start, end, false)
+	if err != nil {
+		return nil, err
+	}
+	prior, err := s.ledgerTotals(priorStart, priorEnd, false)
+	if err != nil {
+		return nil, err
+	}
+
+	currentBalances := balancesOf(accounts, current)
+	priorBalances := balancesOf(accounts, prior)
+	report := &IncomeStatement{
+		PeriodStart:      start,
+		PeriodEnd:        end,
+		PriorPeriodStart: priorStart,
+		PriorPeriodEnd:   priorEnd,
+		Income:           statementSection("Pendapatan", accounts, models.GLAccountIncome, currentBalances, priorBalances),
+		Expenses:         statementSection("Beban", accounts, models.GLAccountExpense, currentBalances, priorBalances),
+	}
+	report.NetSurplus = report.Income.Total - report.Expenses.Total
+	report.PriorNetSurplus = report.Income.PriorTotal - report.Expenses.PriorTotal
+
+	return report, nil
+}
+
+// ExportReport writes a report to a CSV or PDF file in the reports folder
+// and returns the file's path
+func (s *ReportingService) ExportReport(req ReportExportRequest) (string, error) {
+	if req.Format != ReportFormatCSV && req.Format != ReportFormatPDF {
+		return "", fmt.Errorf("invalid report format")
+	}
+
+	period := FinancialReportRequest{PeriodStart: req.PeriodStart, PeriodEnd: req.PeriodEnd}
+	var table *reportTable
+	switch req.Report {
+	case FinancialReportTrialBalance:
+		report, err := s.TrialBalance(period)
+		if err != nil {
+			return "", err
+		}
+		table = report.table()
+	case FinancialReportBalanceSheet:
+		report, err := s.BalanceSheet(period)
+		if err != nil {
+			return "", err
+		}
+		table = report.table()
+	case FinancialReportIncomeStatement:
+		report, err := s.IncomeStatement(period)
+		if err != nil {
+			return "", err
+		}
+		table = report.table()
+	default:
+		return "", fmt.Errorf("invalid report")
+	}
+
+	dir := s.reportsPath()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create reports directory: %v", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.%s", req.Report, table.period, req.Format))
+
+	var err error
+	if req.Format == ReportFormatCSV {
+		err = table.writeCSV(path)
+	} else {
+		err = table.writePDF(path, s.cfg.App.Name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write report: %v", err)
+	}
+
+	return path, nil
+}
+
+func (s *ReportingService) reportsPath() string {
+	if s.cfg.Storage.ReportsPath != "" {
+		return s.cfg.Storage.ReportsPath
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".koperasi", "reports")
+}
+
+// ledgerTotals sums the debits and credits per account of the entries dated
+// from one day to another. A zero from sums from the first entry; closing
+// tells whether year-end closing entries are included.
+func (s *ReportingService) ledgerTotals(from, to time.Time, closing bool) (map[string]ledgerTotals, error) {
+	condition := "date(je.entry_date) <= date(?)"
+	args := []interface{}{to.Format("2006-01-02")}
+	if !from.IsZero() {
+		condition += " AND date(je.entry_date) >= date(?)"
+		args = append(args, from.Format("2006-01-02"))
+	}
+	if !closing {
+		condition += " AND je.source != ?"
+		args = append(args, string(models.JournalSourceClosing))
+	}
+
+	rows, err := s.db.Query(`
+		SELECT jl.account_code, COALESCE(SUM(jl.debit), 0), COALESCE(SUM(jl.credit), 0)
+		FROM journal_lines jl
+		JOIN journal_entries je ON je.id = jl.entry_id
+		WHERE `+condition+`
+		GROUP BY jl.account_code`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger totals: %v", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]ledgerTotals)
+	for rows.Next() {
+		var code string
+		var t ledgerTotals
+		if err := rows.Scan(&code, &t.debit, &t.credit); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger totals: %v", err)
+		}
+		totals[code] = t
+	}
+
+	return totals, rows.Err()
+}
+
+// positionAt returns the balances of the accounts as of a day and the
+// surplus of that day's year so far. The surplus of earlier years that have
+// not been closed is added to SHU Belum Dibagi as if they had been.
+func (s *ReportingService) positionAt(accounts []models.GLAccount, asOf time.Time) (map[string]int64, int64, error) {
+	yearStart := time.Date(asOf.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+
+	earlier, err := s.ledgerTotals(time.Time{}, yearStart.AddDate(0, 0, -1), true)
+	if err != nil {
+		return nil, 0, err
+	}
+	year, err := s.ledgerTotals(yearStart, asOf, true)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	balances := balancesOf(accounts, earlier)
+	earlierSurplus := surplusOf(accounts, balances)
+	for code, t := range year {
+		earlier[code] = ledgerTotals{earlier[code].debit + t.debit, earlier[code].credit + t.credit}
+	}
+	balances = balancesOf(accounts, earlier)
+	balances[accounting.AccountSHUBelumDibagi] += earlierSurplus
+
+	return balances, surplusOf(accounts, balances) - earlierSurplus, nil
+}
+
+// balanceSides puts the balance of debits and credits on the side that
+// exceeds the other
+func balanceSides(debit, credit int64) (int64, int64) {
+	if debit >= credit {
+		return debit - credit, 0
+	}
+	return 0, credit - debit
+}
+
+// balancesOf turns ledger totals into balances on each account's normal side
+func balancesOf(accounts []models.GLAccount, totals map[string]ledgerTotals) map[string]int64 {
+	balances := make(map[string]int64, len(totals))
+	for _, account := range accounts {
+		if t, ok := totals[account.Code]; ok {
+			balances[account.Code] = accounting.Balance(account.Type, t.debit, t.credit)
+		}
+	}
+	return balances
+}
+
+// surplusOf is income less expenses
+func surplusOf(accounts []models.GLAccount, balances map[string]int64) int64 {
+	var surplus int64
+	for _, account := range accounts {
+		switch account.Type {
+		case models.GLAccountIncome:
+			surplus += balances[account.Code]
+		case models.GLAccountExpense:
+			surplus -= balances[account.Code]
+		}
+	}
+	return surplus
+}
+
+// statementSection lists the accounts of a type with an amount in either
+// period
+func statementSection(name string, accounts []models.GLAccount, accountType models.GLAccountType, current, prior map[string]int64) StatementSection {
+	section := StatementSection{Name: name, Lines: make([]StatementLine, 0)}
+	for _, account := range accounts {
+		if account.Type != accountType || (current[account.Code] == 0 && prior[account.Code] == 0) {
+			continue
+		}
+		section.Lines = append(section.Lines, StatementLine{
+			AccountCode: account.Code,
+			AccountName: account.Name,
+			Amount:      current[account.Code],
+			PriorAmount: prior[account.Code],
+		})
+		section.Total += current[account.Code]
+		section.PriorTotal += prior[account.Code]
+	}
+	return section
+}
+
+// reportTable is a report laid out for export: a row per account under
+// section headings, with the amounts in columns
+type reportTable struct {
+	title    string
+	subtitle string
+	period   string // for the file name
+	columns  []string
+	rows     []reportRow
+}
+
+type reportRow struct {
+	code    string
+	name    string
+	amounts []int64
+	heading bool // a section heading without amounts
+	total   bool
+}
+
+func (t *reportTable) section(section StatementSection) {
+	t.rows = append(t.rows, reportRow{name: section.Name, heading: true})
+	for _, line := range section.Lines {
+		t.rows = append(t.rows, reportRow{code: line.AccountCode, name: line.AccountName,
+			amounts: []int64{line.Amount, line.PriorAmount}})
+	}
+	t.rows = append(t.rows, reportRow{name: "Jumlah " + section.Name,
+		amounts: []int64{section.Total, section.PriorTotal}, total: true})
+}
+
+func (r *TrialBalance) table() *reportTable {
+	t := &reportTable{
+		title: "Neraca Saldo",
+		subtitle: fmt.Sprintf("Periode %s, dibandingkan dengan %s",
+			periodLabel(r.PeriodStart, r.PeriodEnd), periodLabel(r.PriorPeriodStart, r.PriorPeriodEnd)),
+		period: periodFileLabel(r.PeriodStart, r.PeriodEnd),
+		columns: []string{"Saldo Awal D", "Saldo Awal K", "Mutasi D", "Mutasi K",
+			"Saldo Akhir D", "Saldo Akhir K", "Mutasi Lalu D", "Mutasi Lalu K"},
+	}
+	amounts := func(line TrialBalanceLine) []int64 {
+		return []int64{line.OpeningDebit, line.OpeningCredit, line.Debit, line.Credit,
+			line.ClosingDebit, line.ClosingCredit, line.PriorDebit, line.PriorCredit}
+	}
+	for _, line := range r.Lines {
+		t.rows = append(t.rows, reportRow{code: line.AccountCode, name: line.AccountName, amounts: amounts(line)})
+	}
+	t.rows = append(t.rows, reportRow{name: "Jumlah", total: true, amounts: amounts(r.Totals)})
+	return t
+}
+
+func (r *BalanceSheet) table() *reportTable {
+	t := &reportTable{
+		title:    "Neraca",
+		subtitle: fmt.Sprintf("Per %s", r.AsOf.Format("02/01/2006")),
+		period:   r.AsOf.Format("20060102"),
+		columns:  []string{r.AsOf.Format("02/01/2006"), r.PriorAsOf.Format("02/01/2006")},
+	}
+	t.section(r.Assets)
+	t.section(r.Liabilities)
+	t.section(r.Equity)
+	t.rows = append(t.rows, reportRow{name: "Jumlah Kewajiban dan Ekuitas", total: true,
+		amounts: []int64{r.TotalLiabilitiesEquity, r.PriorTotalLiabilitiesEquity}})
+	return t
+}
+
+func (r *IncomeStatement) table() *reportTable {
+	t := &reportTable{
+		title:    "Laba Rugi",
+		subtitle: fmt.Sprintf("Periode %s", periodLabel(r.PeriodStart, r.PeriodEnd)),
+		period:   periodFileLabel(r.PeriodStart, r.PeriodEnd),
+		columns:  []string{periodLabel(r.PeriodStart, r.PeriodEnd), periodLabel(r.PriorPeriodStart, r.PriorPeriodEnd)},
+	}
+	t.section(r.Income)
+	t.section(r.Expenses)
+	t.rows = append(t.rows, reportRow{name: "Sisa Hasil Usaha", total: true,
+		amounts: []int64{r.NetSurplus, r.PriorNetSurplus}})
+	return t
+}
+
+// writeCSV writes the table with plain amounts, for spreadsheets
+func (t *reportTable) writeCSV(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.Write(append([]string{"Kode", "Akun"}, t.columns...)); err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		record := []string{row.code, row.name}
+		for _, amount := range row.amounts {
+			record = append(record, strconv.FormatInt(amount, 10))
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// writePDF prints the table on A4, turning the page for wide tables
+func (t *reportTable) writePDF(path, organization string) error {
+	orientation, pageWidth := "P", 190.0
+	if len(t.columns) > 2 {
+		orientation, pageWidth = "L", 277.0
+	}
+	codeWidth, amountWidth, fontSize := 15.0, 40.0, 9.0
+	if len(t.columns) > 2 {
+		amountWidth, fontSize = 25, 8
+	}
+	nameWidth := pageWidth - codeWidth - amountWidth*float64(len(t.columns))
+
+	pdf := fpdf.New(orientation, "mm", "A4", "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 14)
+	pdf.CellFormat(pageWidth, 7, organization, "", 1, "C", false, 0, "")
+	pdf.CellFormat(pageWidth, 7, t.title, "", 1, "C", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(pageWidth, 6, t.subtitle, "", 1, "C", false, 0, "")
+	pdf.Ln(4)
+
+	pdf.SetFont("Helvetica", "B", fontSize)
+	pdf.CellFormat(codeWidth, 7, "Kode", "TB", 0, "L", false, 0, "")
+	pdf.CellFormat(nameWidth, 7, "Akun", "TB", 0, "L", false, 0, "")
+	for _, column := range t.columns {
+		pdf.CellFormat(amountWidth, 7, column, "TB", 0, "R", false, 0, "")
+	}
+	pdf.Ln(-1)
+
+	for _, row := range t.rows {
+		style, border := "", ""
+		if row.heading || row.total {
+			style = "B"
+		}
+		if row.total {
+			border = "T"
+		}
+		pdf.SetFont("Helvetica", style, fontSize)
+		pdf.CellFormat(codeWidth, 6, row.code, border, 0, "L", false, 0, "")
+		pdf.CellFormat(nameWidth, 6, row.name, border, 0, "L", false, 0, "")
+		for i := range t.columns {
+			text := ""
+			if i < len(row.amounts) {
+				text = formatAmount(row.amounts[i])
+			}
+			pdf.CellFormat(amountWidth, 6, text, border, 0, "R", false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	return pdf.OutputFileAndClose(path)
+}
+
+// formatAmount writes an amount with thousand separators, negative amounts
+// in parentheses
+func formatAmount(amount int64) string {
+	negative := amount < 0
+	if negative {
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(c)
+	}
+
+	if negative {
+		return "(" + b.String() + ")"
+	}
+	return b.String()
+}
+
+func periodLabel(start, end time.Time) string {
+	return start.Format("02/01/2006") + " - " + end.Format("02/01/2006")
+}
+
+func periodFileLabel(start, end time.Time) string {
+	return start.Format("20060102") + "-" + end.Format("20060102")
+}
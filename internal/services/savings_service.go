@@ -0,0 +1,898 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+)
+
+// SavingsService manages member savings accounts (simpanan pokok, wajib and
+// sukarela) and their deposit and withdrawal ledger
+type SavingsService struct {
+	db  *database.DB
+	cfg *config.Config
+}
+
+func NewSavingsService(db *database.DB, cfg *config.Config) *SavingsService {
+	return &SavingsService{db: db, cfg: cfg}
+}
+
+type SavingsAccountOpenRequest struct {
+	CustomerID string             `json:"customer_id"`
+	Type       models.SavingsType `json:"type"`
+	OpenedBy   string             `json:"opened_by"`
+}
+
+type SavingsTransactionRequest struct {
+	AccountID string               `json:"account_id"`
+	Amount    int64                `json:"amount"`
+	Method    models.PaymentMethod `json:"method"` // defaults to cash
+	Reference string               `json:"reference"`
+	Notes     string               `json:"notes"`
+	PostedBy  string               `json:"posted_by"`
+}
+
+type SavingsStatementRequest struct {
+	AccountID   string    `json:"account_id"`
+	PeriodStart time.Time `json:"period_start"` // defaults to the start of the current month
+	PeriodEnd   time.Time `json:"period_end"`   // defaults to today
+}
+
+type SavingsStatement struct {
+	Account          models.SavingsAccount       `json:"account"`
+	CustomerName     string                      `json:"customer_name"`
+	PeriodStart      time.Time                   `json:"period_start"`
+	PeriodEnd        time.Time                   `json:"period_end"`
+	OpeningBalance   int64                       `json:"opening_balance"`
+	Transactions     []models.SavingsTransaction `json:"transactions"`
+	TotalDeposits    int64                       `json:"total_deposits"`
+	TotalWithdrawals int64                       `json:"total_withdrawals"`
+	TotalInterest    int64                       `json:"total_interest"`
+	TotalTax         int64                       `json:"total_tax"`
+	TotalSHU         int64                       `json:"total_shu"`
+	ClosingBalance   int64                       `json:"closing_balance"`
+}
+
+// WajibArrear is a member who has deposited less simpanan wajib than owed
+type WajibArrear struct {
+	CustomerID    uuid.UUID              `json:"customer_id"`
+	CustomerName  string                 `json:"customer_name"`
+	AccountID     uuid.UUID              `json:"account_id"`
+	AccountNumber string                 `json:"account_number"`
+	Obligation    models.WajibObligation `json:"obligation"`
+}
+
+const savingsAccountColumns = `
+	a.id, a.customer_id, a.account_number, a.type, a.status, a.balance, a.monthly_obligation, a.opened_by,
+	a.opened_at, a.closed_at, a.updated_at,
+	(SELECT COALESCE(SUM(amount), 0) FROM savings_transactions WHERE account_id = a.id AND type = 'deposit'),
+	(SELECT COALESCE(SUM(interest), 0) FROM savings_interest_accruals WHERE account_id = a.id AND credited_at IS NULL)`
+
+const savingsTransactionColumns = `
+	id, account_id, receipt_number, type, amount, balance_after, method, COALESCE(reference, ''),
+	transaction_date, posted_by, COALESCE(notes, '')`
+
+// OpenSavingsAccount opens a savings account of the given type for a member.
+// A member holds at most one account of each type. Wajib accounts owe the
+// configured monthly amount from the month they are opened.
+func (s *SavingsService) OpenSavingsAccount(req SavingsAccountOpenRequest) (*models.SavingsAccount, error) {
+	customerID, err := uuid.Parse(req.CustomerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid customer ID")
+	}
+
+	savingsType, err := parseSavingsType(req.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	openedBy, err := parseOptionalUUID(req.OpenedBy, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	account := &models.SavingsAccount{
+		ID:         uuid.New(),
+		CustomerID: customerID,
+		Type:       savingsType,
+		Status:     models.SavingsAccountStatusActive,
+		OpenedBy:   openedBy,
+		OpenedAt:   now,
+		UpdatedAt:  now,
+	}
+	if savingsType == models.SavingsTypeWajib {
+		account.MonthlyObligation = s.cfg.Savings.WajibMonthly
+	}
+
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		var status models.CustomerStatus
+		err := tx.QueryRow("SELECT status FROM customers WHERE id = ?", customerID.String()).Scan(&status)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("customer not found")
+			}
+			return fmt.Errorf("failed to check customer: %v", err)
+		}
+		if status == models.CustomerStatusInactive || status == models.CustomerStatusBlocked {
+			return fmt.Errorf("savings accounts cannot be opened for %s members", status)
+		}
+
+		var existing int
+		err = tx.QueryRow("SELECT COUNT(*) FROM savings_accounts WHERE customer_id = ? AND type = ?",
+			customerID.String(), string(savingsType)).Scan(&existing)
+		if err != nil {
+			return fmt.Errorf("failed to check existing accounts: %v", err)
+		}
+		if existing > 0 {
+			return fmt.Errorf("member already has a simpanan %s account", savingsType)
+		}
+
+		return insertSavingsAccount(tx, account)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if savingsType == models.SavingsTypeWajib {
+		obligation := CalculateWajibObligation(account.MonthlyObligation, account.OpenedAt, now, 0)
+		account.Wajib = &obligation
+	}
+
+	return account, nil
+}
+
+// Deposit adds money to a savings account. Simpanan pokok is paid once and,
+// when configured, must be exactly the pokok amount.
+func (s *SavingsService) Deposit(req SavingsTransactionRequest) (*models.SavingsTransaction, error) {
+	return s.post(req, models.SavingsTransactionDeposit)
+}
+
+// Withdraw takes money out of a savings account. Simpanan sukarela can be
+// withdrawn down to zero at any time. Simpanan pokok and wajib are only paid
+// out in full once the member has left (status inactive), which closes the
+// account.
+func (s *SavingsService) Withdraw(req SavingsTransactionRequest) (*models.SavingsTransaction, error) {
+	return s.post(req, models.SavingsTransactionWithdrawal)
+}
+
+func (s *SavingsService) post(req SavingsTransactionRequest, txType models.SavingsTransactionType) (*models.SavingsTransaction, error) {
+	accountID, err := uuid.Parse(req.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid savings account ID")
+	}
+
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("%s amount must be greater than 0", txType)
+	}
+
+	if req.Method == "" {
+		req.Method = models.PaymentMethodCash
+	}
+	if err := validatePaymentMethod(req.Method); err != nil {
+		return nil, err
+	}
+
+	postedBy, err := parseOptionalUUID(req.PostedBy, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
+	var transaction *models.SavingsTransaction
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		account, err := querySavingsAccount(tx, accountID.String())
+		if err != nil {
+			return err
+		}
+		if account.Status == models.SavingsAccountStatusClosed {
+			return fmt.Errorf("savings account %s is closed", account.AccountNumber)
+		}
+
+		balance := account.Balance
+		closeAccount := false
+		switch txType {
+		case models.SavingsTransactionDeposit:
+			if account.Type == models.SavingsTypePokok {
+				// pokok is only ever paid out in full, closing the account
+				if account.Balance > 0 {
+					return fmt.Errorf("simpanan pokok has already been paid")
+				}
+				if pokok := s.cfg.Savings.PokokAmount; pokok > 0 && req.Amount != pokok {
+					return fmt.Errorf("simpanan pokok is %d", pokok)
+				}
+			}
+			balance += req.Amount
+
+		case models.SavingsTransactionWithdrawal:
+			if req.Amount > balance {
+				return fmt.Errorf("withdrawal is more than the balance of %d", balance)
+			}
+			if account.Type != models.SavingsTypeSukarela {
+				var status models.CustomerStatus
+				err := tx.QueryRow("SELECT status FROM customers WHERE id = ?", account.CustomerID.String()).Scan(&status)
+				if err != nil {
+					return fmt.Errorf("failed to check customer: %v", err)
+				}
+				if status != models.CustomerStatusInactive {
+					return fmt.Errorf("simpanan %s can only be withdrawn when the member leaves the koperasi", account.Type)
+				}
+				if req.Amount != balance {
+					return fmt.Errorf("simpanan %s must be withdrawn in full (%d)", account.Type, balance)
+				}
+				closeAccount = true
+			}
+			balance -= req.Amount
+		}
+
+		now := time.Now()
+		transaction = &models.SavingsTransaction{
+			ID:              uuid.New(),
+			AccountID:       account.ID,
+			Type:            txType,
+			Amount:          req.Amount,
+			BalanceAfter:    balance,
+			Method:          req.Method,
+			Reference:       strings.TrimSpace(req.Reference),
+			TransactionDate: now,
+			PostedBy:        postedBy,
+			Notes:           strings.TrimSpace(req.Notes),
+		}
+		if err := insertSavingsTransaction(tx, transaction); err != nil {
+			return err
+		}
+
+		status, closedAt := account.Status, account.ClosedAt
+		if closeAccount {
+			status, closedAt = models.SavingsAccountStatusClosed, &now
+		}
+		_, err = tx.Exec(`
+			UPDATE savings_accounts SET balance = ?, status = ?, closed_at = ?, updated_at = ?
+			WHERE id = ?`,
+			balance, string(status), closedAt, now, account.ID.String())
+		if err != nil {
+			return fmt.Errorf("failed to update savings balance: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+func (s *SavingsService) GetSavingsAccount(id string) (*models.SavingsAccount, error) {
+	accountID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid savings account ID")
+	}
+
+	return querySavingsAccount(s.db, accountID.String())
+}
+
+// GetMemberSavings lists a member's savings accounts, with what they owe on
+// simpanan wajib
+func (s *SavingsService) GetMemberSavings(customerID string) ([]models.SavingsAccount, error) {
+	id, err := uuid.Parse(customerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid customer ID")
+	}
+
+	return memberSavingsAccounts(s.db, id.String())
+}
+
+// GetSavingsStatement lists the transactions of an account in a period with
+// the balances before and after it
+func (s *SavingsService) GetSavingsStatement(req SavingsStatementRequest) (*SavingsStatement, error) {
+	accountID, err := uuid.Parse(req.AccountID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid savings account ID")
+	}
+
+	start, end, err := reportPeriod(req.PeriodStart, req.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := querySavingsAccount(s.db, accountID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &SavingsStatement{Account: *account, PeriodStart: start, PeriodEnd: end}
+	err = s.db.QueryRow("SELECT name FROM customers WHERE id = ?", account.CustomerID.String()).Scan(&statement.CustomerName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %v", err)
+	}
+
+	err = s.db.QueryRow(`
+		SELECT COALESCE((SELECT balance_after FROM savings_transactions
+		                 WHERE account_id = ? AND date(transaction_date) < date(?)
+		                 ORDER BY transaction_date DESC LIMIT 1), 0)`,
+		account.ID.String(), start.Format("2006-01-02")).Scan(&statement.OpeningBalance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get opening balance: %v", err)
+	}
+
+	statement.Transactions, err = querySavingsTransactions(s.db,
+		"account_id = ? AND date(transaction_date) BETWEEN date(?) AND date(?)",
+		account.ID.String(), start.Format("2006-01-02"), end.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	statement.ClosingBalance = statement.OpeningBalance
+	for _, transaction := range statement.Transactions {
+		switch transaction.Type {
+		case models.SavingsTransactionDeposit:
+			statement.TotalDeposits += transaction.Amount
+		case models.SavingsTransactionWithdrawal:
+			statement.TotalWithdrawals += transaction.Amount
+		case models.SavingsTransactionInterest:
+			statement.TotalInterest += transaction.Amount
+		case models.SavingsTransactionTax:
+			statement.TotalTax += transaction.Amount
+		case models.SavingsTransactionSHU:
+			statement.TotalSHU += transaction.Amount
+		}
+		statement.ClosingBalance = transaction.BalanceAfter
+	}
+
+	return statement, nil
+}
+
+// GetWajibArrears lists the members with an open simpanan wajib account who
+// have deposited less than they owe up to this month, largest arrears first
+func (s *SavingsService) GetWajibArrears() ([]WajibArrear, error) {
+	rows, err := s.db.Query(`
+		SELECT c.name, ` + savingsAccountColumns + `
+		FROM savings_accounts a
+		JOIN customers c ON a.customer_id = c.id
+		WHERE a.type = 'wajib' AND a.status = 'active'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wajib accounts: %v", err)
+	}
+	defer rows.Close()
+
+	arrears := make([]WajibArrear, 0)
+	for rows.Next() {
+		var customerName string
+		account, err := scanSavingsAccount(rows, &customerName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan savings account: %v", err)
+		}
+		if account.Wajib.Arrears == 0 {
+			continue
+		}
+		arrears = append(arrears, WajibArrear{
+			CustomerID:    account.CustomerID,
+			CustomerName:  customerName,
+			AccountID:     account.ID,
+			AccountNumber: account.AccountNumber,
+			Obligation:    *account.Wajib,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(arrears, func(i, j int) bool {
+		return arrears[i].Obligation.Arrears > arrears[j].Obligation.Arrears
+	})
+	return arrears, nil
+}
+
+// CalculateWajibObligation compares the simpanan wajib owed from the month an
+// account was opened up to and including the month of asOf with what was
+// deposited on it
+func CalculateWajibObligation(monthlyAmount int64, openedAt, asOf time.Time, deposited int64) models.WajibObligation {
+	obligation := models.WajibObligation{MonthlyAmount: monthlyAmount, Deposited: deposited}
+
+	months := (asOf.Year()-openedAt.Year())*12 + int(asOf.Month()-openedAt.Month()) + 1
+	if months > 0 {
+		obligation.MonthsDue = months
+	}
+	obligation.AmountDue = monthlyAmount * int64(obligation.MonthsDue)
+	if monthlyAmount > 0 {
+		obligation.MonthsPaid = int(deposited / monthlyAmount)
+	}
+	if deposited < obligation.AmountDue {
+		obligation.Arrears = obligation.AmountDue - deposited
+	}
+
+	return obligation
+}
+
+// SavingsInterestRate is the annual rate in percent paid on a sukarela
+// balance: the rate of the highest tier the balance reaches, or the base rate
+// below the first tier
+func SavingsInterestRate(cfg config.SavingsInterestConfig, balance int64) float64 {
+	rate := cfg.AnnualRate
+	for _, tier := range cfg.Tiers {
+		if balance >= tier.MinBalance {
+			rate = tier.AnnualRate
+		}
+	}
+	return rate
+}
+
+// DailySavingsInterest is a day's interest on an end-of-day balance in
+// fractions of a rupiah, on a 365-day year
+func DailySavingsInterest(balance int64, annualRate float64) float64 {
+	return float64(balance) * annualRate / 100 / 365
+}
+
+// SavingsInterestTax is the tax withheld from a month's credited interest:
+// nothing up to the threshold, and TaxRate of all of it above
+func SavingsInterestTax(cfg config.SavingsInterestConfig, interest int64) int64 {
+	if interest <= cfg.TaxThreshold {
+		return 0
+	}
+	return int64(math.Round(float64(interest) * cfg.TaxRate / 100))
+}
+
+// AccrueInterest accrues the interest of every open sukarela account for each
+// day up to and including through's date that has not been accrued yet,
+// starting from the day the account was opened. Days missed while the app was
+// not running are caught up at the current rates. It returns the number of
+// days accrued over all accounts.
+func (s *SavingsService) AccrueInterest(through time.Time) (int, error) {
+	lastDay := dateOf(through)
+
+	rows, err := s.db.Query(`
+		SELECT a.id, a.opened_at, (SELECT MAX(accrual_date) FROM savings_interest_accruals WHERE account_id = a.id)
+		FROM savings_accounts a
+		WHERE a.type = 'sukarela' AND a.status = 'active'`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query sukarela accounts: %v", err)
+	}
+	defer rows.Close()
+
+	type pendingAccrual struct {
+		accountID string
+		from      time.Time
+	}
+	pending := make([]pendingAccrual, 0)
+	for rows.Next() {
+		var accountID string
+		var openedAt time.Time
+		var lastAccrued sql.NullString
+		if err := rows.Scan(&accountID, &openedAt, &lastAccrued); err != nil {
+			return 0, fmt.Errorf("failed to scan sukarela account: %v", err)
+		}
+
+		from := dateOf(openedAt)
+		if lastAccrued.Valid {
+			last, err := time.Parse("2006-01-02", lastAccrued.String[:10])
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse accrual date %q: %v", lastAccrued.String, err)
+			}
+			from = last.AddDate(0, 0, 1)
+		}
+		if !from.After(lastDay) {
+			pending = append(pending, pendingAccrual{accountID: accountID, from: from})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	accrued := 0
+	for _, account := range pending {
+		days, err := s.accrueAccountInterest(account.accountID, account.from, lastDay)
+		if err != nil {
+			return accrued, err
+		}
+		accrued += days
+	}
+
+	return accrued, nil
+}
+
+// accrueAccountInterest accrues an account's interest for every day from
+// from up to and including through on the balance at the end of that day
+func (s *SavingsService) accrueAccountInterest(accountID string, from, through time.Time) (int, error) {
+	interestCfg := s.cfg.SavingsInterest
+	days := 0
+
+	err := s.db.WithTx(func(tx *sql.Tx) error {
+		var balance int64
+		err := tx.QueryRow(`
+			SELECT COALESCE((SELECT balance_after FROM savings_transactions
+			                 WHERE account_id = ? AND date(transaction_date) < date(?)
+			                 ORDER BY transaction_date DESC LIMIT 1), 0)`,
+			accountID, from.Format("2006-01-02")).Scan(&balance)
+		if err != nil {
+			return fmt.Errorf("failed to get opening balance: %v", err)
+		}
+
+		rows, err := tx.Query(`
+			SELECT date(transaction_date), balance_after
+			FROM savings_transactions
+			WHERE account_id = ? AND date(transaction_date) BETWEEN date(?) AND date(?)
+			ORDER BY transaction_date`,
+			accountID, from.Format("2006-01-02"), through.Format("2006-01-02"))
+		if err != nil {
+			return fmt.Errorf("failed to query savings transactions: %v", err)
+		}
+		type balanceChange struct {
+			date         string
+			balanceAfter int64
+		}
+		changes := make([]balanceChange, 0)
+		for rows.Next() {
+			var change balanceChange
+			if err := rows.Scan(&change.date, &change.balanceAfter); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan savings transaction: %v", err)
+			}
+			changes = append(changes, change)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		next := 0
+		for day := from; !day.After(through); day = day.AddDate(0, 0, 1) {
+			date := day.Format("2006-01-02")
+			for next < len(changes) && changes[next].date <= date {
+				balance = changes[next].balanceAfter
+				next++
+			}
+
+			rate := SavingsInterestRate(interestCfg, balance)
+			_, err := tx.Exec(`
+				INSERT INTO savings_interest_accruals (account_id, accrual_date, balance, annual_rate, interest)
+				VALUES (?, ?, ?, ?, ?)`,
+				accountID, date, balance, rate, DailySavingsInterest(balance, rate))
+			if err != nil {
+				return fmt.Errorf("failed to accrue interest: %v", err)
+			}
+			days++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return days, nil
+}
+
+// CreditInterest credits the sukarela interest accrued in the months before
+// asOf's month that has not been credited yet, after accruing up to the end
+// of the previous month. An account gets an interest line per month, rounded
+// down to the rupiah, followed by a tax line when tax is withheld. It returns
+// the number of interest lines posted.
+func (s *SavingsService) CreditInterest(asOf time.Time) (int, error) {
+	today := dateOf(asOf)
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if _, err := s.AccrueInterest(monthStart.AddDate(0, 0, -1)); err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.Query(`
+		SELECT account_id, substr(accrual_date, 1, 7), SUM(interest)
+		FROM savings_interest_accruals
+		WHERE credited_at IS NULL AND accrual_date < ?
+		GROUP BY account_id, substr(accrual_date, 1, 7)
+		ORDER BY substr(accrual_date, 1, 7), account_id`, monthStart.Format("2006-01-02"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to query accrued interest: %v", err)
+	}
+	defer rows.Close()
+
+	type monthInterest struct {
+		accountID string
+		month     string // YYYY-MM
+		interest  float64
+	}
+	months := make([]monthInterest, 0)
+	for rows.Next() {
+		var month monthInterest
+		if err := rows.Scan(&month.accountID, &month.month, &month.interest); err != nil {
+			return 0, fmt.Errorf("failed to scan accrued interest: %v", err)
+		}
+		months = append(months, month)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	rows.Close()
+
+	credited := 0
+	for _, month := range months {
+		posted, err := s.creditMonthInterest(month.accountID, month.month, month.interest)
+		if err != nil {
+			return credited, err
+		}
+		if posted {
+			credited++
+		}
+	}
+
+	return credited, nil
+}
+
+// creditMonthInterest posts an account's interest for a month and marks its
+// accruals credited. It reports whether an interest line was posted; interest
+// below one rupiah is only marked credited.
+func (s *SavingsService) creditMonthInterest(accountID, month string, accrued float64) (bool, error) {
+	interestCfg := s.cfg.SavingsInterest
+	interest := int64(math.Floor(accrued))
+	now := time.Now()
+
+	err := s.db.WithTx(func(tx *sql.Tx) error {
+		var transactionID interface{}
+		if interest > 0 {
+			var balance int64
+			err := tx.QueryRow("SELECT balance FROM savings_accounts WHERE id = ?", accountID).Scan(&balance)
+			if err != nil {
+				return fmt.Errorf("failed to get savings account: %v", err)
+			}
+
+			id, err := uuid.Parse(accountID)
+			if err != nil {
+				return fmt.Errorf("invalid savings account ID %q", accountID)
+			}
+
+			// Interest and tax are book entries on the account, not cash
+			// movements
+			balance += interest
+			line := &models.SavingsTransaction{
+				ID:              uuid.New(),
+				AccountID:       id,
+				Type:            models.SavingsTransactionInterest,
+				Amount:          interest,
+				BalanceAfter:    balance,
+				Method:          models.PaymentMethodTransfer,
+				TransactionDate: now,
+				Notes:           "Interest for " + month,
+			}
+			if err := insertSavingsTransaction(tx, line); err != nil {
+				return err
+			}
+			transactionID = line.ID.String()
+
+			if tax := SavingsInterestTax(interestCfg, interest); tax > 0 {
+				balance -= tax
+				taxLine := &models.SavingsTransaction{
+					ID:              uuid.New(),
+					AccountID:       id,
+					Type:            models.SavingsTransactionTax,
+					Amount:          tax,
+					BalanceAfter:    balance,
+					Method:          models.PaymentMethodTransfer,
+					TransactionDate: now,
+					Notes:           "Tax on interest for " + month,
+				}
+				if err := insertSavingsTransaction(tx, taxLine); err != nil {
+					return err
+				}
+			}
+
+			_, err = tx.Exec("UPDATE savings_accounts SET balance = ?, updated_at = ? WHERE id = ?",
+				balance, now, accountID)
+			if err != nil {
+				return fmt.Errorf("failed to update savings balance: %v", err)
+			}
+		}
+
+		_, err := tx.Exec(`
+			UPDATE savings_interest_accruals SET credited_at = ?, transaction_id = ?
+			WHERE account_id = ? AND credited_at IS NULL AND substr(accrual_date, 1, 7) = ?`,
+			now, transactionID, accountID, month)
+		if err != nil {
+			return fmt.Errorf("failed to mark interest credited: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return interest > 0, nil
+}
+
+// Helper functions
+
+func parseSavingsType(savingsType models.SavingsType) (models.SavingsType, error) {
+	switch savingsType {
+	case models.SavingsTypePokok, models.SavingsTypeWajib, models.SavingsTypeSukarela:
+		return savingsType, nil
+	}
+	return "", fmt.Errorf("unknown savings type: %s", savingsType)
+}
+
+func querySavingsAccount(q rowQuerier, id string) (*models.SavingsAccount, error) {
+	account, err := scanSavingsAccount(q.QueryRow("SELECT "+savingsAccountColumns+" FROM savings_accounts a WHERE a.id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("savings account not found")
+		}
+		return nil, fmt.Errorf("failed to get savings account: %v", err)
+	}
+	return account, nil
+}
+
+// memberSavingsAccounts lists a member's savings accounts in the order
+// pokok, wajib, sukarela
+func memberSavingsAccounts(q querier, customerID string) ([]models.SavingsAccount, error) {
+	rows, err := q.Query(`
+		SELECT `+savingsAccountColumns+`
+		FROM savings_accounts a
+		WHERE a.customer_id = ?
+		ORDER BY CASE a.type WHEN 'pokok' THEN 1 WHEN 'wajib' THEN 2 ELSE 3 END`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query savings accounts: %v", err)
+	}
+	defer rows.Close()
+
+	accounts := make([]models.SavingsAccount, 0)
+	for rows.Next() {
+		account, err := scanSavingsAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan savings account: %v", err)
+		}
+		accounts = append(accounts, *account)
+	}
+
+	return accounts, rows.Err()
+}
+
+// scanSavingsAccount reads savingsAccountColumns, after any leading columns
+// given in extra, and works out the wajib obligation up to now
+func scanSavingsAccount(row rowScanner, extra ...interface{}) (*models.SavingsAccount, error) {
+	var account models.SavingsAccount
+	var openedBy sql.NullString
+	var deposited int64
+	var accrued float64
+	dest := append(extra,
+		&account.ID, &account.CustomerID, &account.AccountNumber, &account.Type, &account.Status, &account.Balance,
+		&account.MonthlyObligation, &openedBy, &account.OpenedAt, &account.ClosedAt, &account.UpdatedAt, &deposited,
+		&accrued)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	account.OpenedBy = uuidFromNull(openedBy)
+	account.AccruedInterest = int64(math.Floor(accrued))
+
+	if account.Type == models.SavingsTypeWajib {
+		asOf := time.Now()
+		if account.ClosedAt != nil {
+			asOf = *account.ClosedAt
+		}
+		obligation := CalculateWajibObligation(account.MonthlyObligation, account.OpenedAt, asOf, deposited)
+		account.Wajib = &obligation
+	}
+
+	return &account, nil
+}
+
+func querySavingsTransactions(q querier, condition string, args ...interface{}) ([]models.SavingsTransaction, error) {
+	rows, err := q.Query("SELECT "+savingsTransactionColumns+" FROM savings_transactions WHERE "+condition+
+		" ORDER BY transaction_date", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query savings transactions: %v", err)
+	}
+	defer rows.Close()
+
+	transactions := make([]models.SavingsTransaction, 0)
+	for rows.Next() {
+		var transaction models.SavingsTransaction
+		var postedBy sql.NullString
+		err := rows.Scan(&transaction.ID, &transaction.AccountID, &transaction.ReceiptNumber, &transaction.Type,
+			&transaction.Amount, &transaction.BalanceAfter, &transaction.Method, &transaction.Reference,
+			&transaction.TransactionDate, &postedBy, &transaction.Notes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan savings transaction: %v", err)
+		}
+		transaction.PostedBy = uuidFromNull(postedBy)
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, rows.Err()
+}
+
+// insertSavingsTransaction numbers a ledger line, records it and books it to
+// the general ledger. The caller updates the account balance.
+func insertSavingsTransaction(tx *sql.Tx, transaction *models.SavingsTransaction) error {
+	var err error
+	transaction.ReceiptNumber, err = nextSavingsReceiptNumber(tx, transaction.TransactionDate)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO savings_transactions (id, account_id, receipt_number, type, amount, balance_after, method,
+		                                  reference, transaction_date, posted_by, notes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, NULLIF(?, ''))`,
+		transaction.ID.String(), transaction.AccountID.String(), transaction.ReceiptNumber, string(transaction.Type),
+		transaction.Amount, transaction.BalanceAfter, string(transaction.Method), transaction.Reference,
+		transaction.TransactionDate, nullableUUID(transaction.PostedBy), transaction.Notes)
+	if err != nil {
+		return fmt.Errorf("failed to record savings transaction: %v", err)
+	}
+
+	var account models.SavingsAccount
+	err = tx.QueryRow("SELECT account_number, type FROM savings_accounts WHERE id = ?",
+		transaction.AccountID.String()).Scan(&account.AccountNumber, &account.Type)
+	if err != nil {
+		return fmt.Errorf("failed to get savings account: %v", err)
+	}
+	entry := accounting.SavingsTransaction(*transaction, account)
+	return postJournalEntry(tx, &entry)
+}
+
+// insertSavingsAccount numbers and records a new account with a zero balance
+func insertSavingsAccount(tx *sql.Tx, account *models.SavingsAccount) error {
+	var err error
+	account.AccountNumber, err = nextSavingsAccountNumber(tx, account.Type)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO savings_accounts (id, customer_id, account_number, type, status, balance, monthly_obligation,
+		                              opened_by, opened_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?, ?)`,
+		account.ID.String(), account.CustomerID.String(), account.AccountNumber, string(account.Type),
+		string(account.Status), account.MonthlyObligation, nullableUUID(account.OpenedBy), account.OpenedAt,
+		account.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to open savings account: %v", err)
+	}
+	return nil
+}
+
+// savingsAccountPrefixes start the account numbers of each savings type
+var savingsAccountPrefixes = map[models.SavingsType]string{
+	models.SavingsTypePokok:    "SP-",
+	models.SavingsTypeWajib:    "SW-",
+	models.SavingsTypeSukarela: "SS-",
+}
+
+// nextSavingsAccountNumber numbers accounts per type as SP-NNNNNN (pokok),
+// SW-NNNNNN (wajib) or SS-NNNNNN (sukarela)
+func nextSavingsAccountNumber(tx *sql.Tx, savingsType models.SavingsType) (string, error) {
+	prefix := savingsAccountPrefixes[savingsType]
+
+	var last int
+	err := tx.QueryRow(`
+		SELECT COALESCE(MAX(CAST(substr(account_number, ?) AS INTEGER)), 0)
+		FROM savings_accounts
+		WHERE account_number LIKE ?`, len(prefix)+1, prefix+"%").Scan(&last)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate account number: %v", err)
+	}
+
+	return fmt.Sprintf("%s%06d", prefix, last+1), nil
+}
+
+// nextSavingsReceiptNumber numbers savings receipts per day as
+// SV-YYYYMMDD-NNNN
+func nextSavingsReceiptNumber(tx *sql.Tx, at time.Time) (string, error) {
+	prefix := fmt.Sprintf("SV-%s-", at.Format("20060102"))
+
+	var last int
+	err := tx.QueryRow(`
+		SELECT COALESCE(MAX(CAST(substr(receipt_number, ?) AS INTEGER)), 0)
+		FROM savings_transactions
+		WHERE receipt_number LIKE ?`, len(prefix)+1, prefix+"%").Scan(&last)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate receipt number: %v", err)
+	}
+
+	return fmt.Sprintf("%s%04d", prefix, last+1), nil
+}
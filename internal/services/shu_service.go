@@ -0,0 +1,460 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+)
+
+// SHUService works out how a year's surplus (SHU) is divided between the
+// funds and the members, and posts the members' shares to their simpanan
+// sukarela
+type SHUService struct {
+	db  *database.DB
+	cfg *config.Config
+}
+
+func NewSHUService(db *database.DB, cfg *config.Config) *SHUService {
+	return &SHUService{db: db, cfg: cfg}
+}
+
+type SHURequest struct {
+	Year       int               `json:"year"`        // defaults to last year
+	NetSurplus int64             `json:"net_surplus"` // defaults to the year's laba rugi
+	Allocation *config.SHUConfig `json:"allocation"`  // defaults to the configured percentages
+	PostedBy   string            `json:"posted_by"`
+}
+
+// CalculateSHU works out the distribution of a year's SHU without posting
+// it. Members share jasa modal by their simpanan pokok and wajib at the end
+// of the year and jasa usaha by the loan interest they paid in it.
+func (s *SHUService) CalculateSHU(req SHURequest) (*models.SHUDistribution, error) {
+	if req.Year == 0 {
+		req.Year = time.Now().Year() - 1
+	}
+	if req.Year < 1900 || req.Year > time.Now().Year() {
+		return nil, fmt.Errorf("invalid year %d", req.Year)
+	}
+
+	allocation := s.cfg.SHU
+	if req.Allocation != nil {
+		allocation = *req.Allocation
+	}
+	if err := allocation.Validate(); err != nil {
+		return nil, err
+	}
+
+	yearStart := time.Date(req.Year, 1, 1, 0, 0, 0, 0, time.UTC)
+	yearEnd := time.Date(req.Year, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	netSurplus := req.NetSurplus
+	if netSurplus == 0 {
+		statement, err := NewReportingService(s.db, s.cfg).IncomeStatement(FinancialReportRequest{
+			PeriodStart: yearStart,
+			PeriodEnd:   yearEnd,
+		})
+		if err != nil {
+			return nil, err
+		}
+		netSurplus = statement.NetSurplus
+	}
+	if netSurplus <= 0 {
+		return nil, fmt.Errorf("there is no surplus to distribute for %d", req.Year)
+	}
+	if err := checkSHUAvailable(s.db, req.Year, netSurplus); err != nil {
+		return nil, err
+	}
+
+	members, err := s.memberContributions(yearStart, yearEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	distribution := AllocateSHU(netSurplus, allocation, members)
+	distribution.Year = req.Year
+	return &distribution, nil
+}
+
+// PostSHU closes the year and posts its distribution out of SHU Belum
+// Dibagi: the funds' shares are set aside in their accounts and each
+// member's share is credited to their simpanan sukarela, which is opened for
+// members without one. A year is posted once.
+func (s *SHUService) PostSHU(req SHURequest) (*models.SHUDistribution, error) {
+	postedBy, err := parseOptionalUUID(req.PostedBy, "user ID")
+	if err != nil {
+		return nil, err
+	}
+
+	distribution, err := s.CalculateSHU(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if distribution.Year >= now.Year() {
+		return nil, fmt.Errorf("SHU for %d cannot be posted before the year ends", distribution.Year)
+	}
+
+	id := uuid.New()
+	distribution.ID = &id
+	distribution.PostedBy = postedBy
+	distribution.PostedAt = &now
+
+	err = s.db.WithTx(func(tx *sql.Tx) error {
+		var existing int
+		err := tx.QueryRow("SELECT COUNT(*) FROM shu_distributions WHERE year = ?", distribution.Year).Scan(&existing)
+		if err != nil {
+			return fmt.Errorf("failed to check existing distribution: %v", err)
+		}
+		if existing > 0 {
+			return fmt.Errorf("SHU for %d has already been posted", distribution.Year)
+		}
+
+		if err := closeYear(tx, distribution.Year, postedBy); err != nil {
+			return err
+		}
+		if err := checkSHUAvailable(tx, distribution.Year, distribution.NetSurplus); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO shu_distributions (id, year, net_surplus, jasa_modal_percent, jasa_usaha_percent, jasa_modal,
+			                               jasa_usaha, total_savings, total_interest, posted_by, posted_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id.String(), distribution.Year, distribution.NetSurplus, distribution.JasaModalPercent,
+			distribution.JasaUsahaPercent, distribution.JasaModal, distribution.JasaUsaha, distribution.TotalSavings,
+			distribution.TotalInterest, nullableUUID(postedBy), now)
+		if err != nil {
+			return fmt.Errorf("failed to record SHU distribution: %v", err)
+		}
+
+		for _, fund := range distribution.Funds {
+			var accounts int
+			err := tx.QueryRow("SELECT COUNT(*) FROM gl_accounts WHERE code = ?", fund.AccountCode).Scan(&accounts)
+			if err != nil {
+				return fmt.Errorf("failed to check account: %v", err)
+			}
+			if accounts == 0 {
+				return fmt.Errorf("account %s of fund %s not found", fund.AccountCode, fund.Name)
+			}
+
+			_, err = tx.Exec(`
+				INSERT INTO shu_fund_allocations (distribution_id, name, percent, account_code, amount)
+				VALUES (?, ?, ?, ?, ?)`,
+				id.String(), fund.Name, fund.Percent, fund.AccountCode, fund.Amount)
+			if err != nil {
+				return fmt.Errorf("failed to record fund %s: %v", fund.Name, err)
+			}
+		}
+
+		entry := accounting.SHUFunds(*distribution)
+		if err := postJournalEntry(tx, &entry); err != nil {
+			return err
+		}
+
+		for i := range distribution.Members {
+			member := &distribution.Members[i]
+			if member.Total > 0 {
+				member.TransactionID, err = creditSHU(tx, *member, distribution.Year, postedBy, now)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = tx.Exec(`
+				INSERT INTO shu_member_allocations (distribution_id, customer_id, savings, interest_paid, jasa_modal,
+				                                    jasa_usaha, transaction_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?)`,
+				id.String(), member.CustomerID.String(), member.Savings, member.InterestPaid, member.JasaModal,
+				member.JasaUsaha, nullableUUID(member.TransactionID))
+			if err != nil {
+				return fmt.Errorf("failed to record member allocation: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return distribution, nil
+}
+
+// checkSHUAvailable refuses to distribute more than SHU Belum Dibagi holds
+// at the end of a year once it is closed: its balance plus the year's
+// surplus not yet closed into it
+func checkSHUAvailable(q rowQuerier, year int, netSurplus int64) error {
+	var available int64
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(jl.credit - jl.debit), 0)
+		FROM journal_lines jl
+		JOIN journal_entries je ON je.id = jl.entry_id
+		JOIN gl_accounts a ON a.code = jl.account_code
+		WHERE date(je.entry_date) <= date(?)
+		  AND (jl.account_code = ? OR (a.type IN ('income', 'expense') AND date(je.entry_date) >= date(?)))`,
+		fmt.Sprintf("%04d-12-31", year), accounting.AccountSHUBelumDibagi, fmt.Sprintf("%04d-01-01", year)).Scan(&available)
+	if err != nil {
+		return fmt.Errorf("failed to get SHU Belum Dibagi: %v", err)
+	}
+	if netSurplus > available {
+		return fmt.Errorf("net surplus %d exceeds the %d in SHU Belum Dibagi for %d", netSurplus, available, year)
+	}
+	return nil
+}
+
+// GetSHUDistribution returns the distribution posted for a year
+func (s *SHUService) GetSHUDistribution(year int) (*models.SHUDistribution, error) {
+	var distribution models.SHUDistribution
+	var id string
+	var postedBy sql.NullString
+	var postedAt time.Time
+	err := s.db.QueryRow(`
+		SELECT id, year, net_surplus, jasa_modal_percent, jasa_usaha_percent, jasa_modal, jasa_usaha, total_savings,
+		       total_interest, posted_by, posted_at
+		FROM shu_distributions WHERE year = ?`, year).Scan(
+		&id, &distribution.Year, &distribution.NetSurplus, &distribution.JasaModalPercent,
+		&distribution.JasaUsahaPercent, &distribution.JasaModal, &distribution.JasaUsaha, &distribution.TotalSavings,
+		&distribution.TotalInterest, &postedBy, &postedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("SHU for %d has not been posted", year)
+		}
+		return nil, fmt.Errorf("failed to get SHU distribution: %v", err)
+	}
+	distributionID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SHU distribution ID %q", id)
+	}
+	distribution.ID = &distributionID
+	distribution.PostedBy = uuidFromNull(postedBy)
+	distribution.PostedAt = &postedAt
+
+	funds, err := s.db.Query(`
+		SELECT name, percent, account_code, amount FROM shu_fund_allocations
+		WHERE distribution_id = ? ORDER BY rowid`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fund allocations: %v", err)
+	}
+	defer funds.Close()
+
+	distribution.Funds = make([]models.SHUFundAllocation, 0)
+	var setAside int64
+	for funds.Next() {
+		var fund models.SHUFundAllocation
+		if err := funds.Scan(&fund.Name, &fund.Percent, &fund.AccountCode, &fund.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan fund allocation: %v", err)
+		}
+		setAside += fund.Amount
+		distribution.Funds = append(distribution.Funds, fund)
+	}
+	if err := funds.Err(); err != nil {
+		return nil, err
+	}
+	funds.Close()
+
+	members, err := s.db.Query(`
+		SELECT m.customer_id, c.name, m.savings, m.interest_paid, m.jasa_modal, m.jasa_usaha, m.transaction_id
+		FROM shu_member_allocations m
+		JOIN customers c ON c.id = m.customer_id
+		WHERE m.distribution_id = ?
+		ORDER BY c.name`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query member allocations: %v", err)
+	}
+	defer members.Close()
+
+	distribution.Members = make([]models.SHUMemberAllocation, 0)
+	for members.Next() {
+		var member models.SHUMemberAllocation
+		var transactionID sql.NullString
+		err := members.Scan(&member.CustomerID, &member.CustomerName, &member.Savings, &member.InterestPaid,
+			&member.JasaModal, &member.JasaUsaha, &transactionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan member allocation: %v", err)
+		}
+		member.Total = member.JasaModal + member.JasaUsaha
+		member.TransactionID = uuidFromNull(transactionID)
+		distribution.Distributed += member.Total
+		distribution.Members = append(distribution.Members, member)
+	}
+	if err := members.Err(); err != nil {
+		return nil, err
+	}
+	distribution.Undistributed = distribution.NetSurplus - setAside - distribution.Distributed
+
+	return &distribution, nil
+}
+
+// AllocateSHU divides a surplus by the allocation. The funds get their
+// percentage of it; the members share the jasa modal pool by savings and the
+// jasa usaha pool by interest paid. Every share is rounded down to the
+// rupiah and what rounding leaves over stays undistributed.
+func AllocateSHU(netSurplus int64, allocation config.SHUConfig, members []models.SHUMemberAllocation) models.SHUDistribution {
+	distribution := models.SHUDistribution{
+		NetSurplus:       netSurplus,
+		JasaModalPercent: allocation.JasaModal,
+		JasaUsahaPercent: allocation.JasaUsaha,
+		JasaModal:        percentShare(netSurplus, allocation.JasaModal),
+		JasaUsaha:        percentShare(netSurplus, allocation.JasaUsaha),
+		Funds:            make([]models.SHUFundAllocation, 0, len(allocation.Funds)),
+		Members:          make([]models.SHUMemberAllocation, 0, len(members)),
+	}
+
+	setAside := int64(0)
+	for _, fund := range allocation.Funds {
+		amount := percentShare(netSurplus, fund.Percent)
+		setAside += amount
+		distribution.Funds = append(distribution.Funds, models.SHUFundAllocation{
+			Name:        fund.Name,
+			Percent:     fund.Percent,
+			AccountCode: fund.AccountCode,
+			Amount:      amount,
+		})
+	}
+
+	for _, member := range members {
+		distribution.TotalSavings += member.Savings
+		distribution.TotalInterest += member.InterestPaid
+	}
+	for _, member := range members {
+		member.JasaModal = proportionalShare(distribution.JasaModal, member.Savings, distribution.TotalSavings)
+		member.JasaUsaha = proportionalShare(distribution.JasaUsaha, member.InterestPaid, distribution.TotalInterest)
+		member.Total = member.JasaModal + member.JasaUsaha
+		distribution.Distributed += member.Total
+		distribution.Members = append(distribution.Members, member)
+	}
+	distribution.Undistributed = netSurplus - setAside - distribution.Distributed
+
+	return distribution
+}
+
+// memberContributions lists, by name, the members with simpanan pokok or
+// wajib at the end of the year or loan interest paid on their installments
+// in it, net of reversals
+func (s *SHUService) memberContributions(yearStart, yearEnd time.Time) ([]models.SHUMemberAllocation, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, savings, interest_paid FROM (
+			SELECT c.id, c.name,
+			       (SELECT COALESCE(SUM((SELECT t.balance_after FROM savings_transactions t
+			                             WHERE t.account_id = a.id AND date(t.transaction_date) <= date(?)
+			                             ORDER BY t.transaction_date DESC, t.rowid DESC LIMIT 1)), 0)
+			        FROM savings_accounts a
+			        WHERE a.customer_id = c.id AND a.type IN ('pokok', 'wajib')) AS savings,
+			       (SELECT COALESCE(SUM(p.interest_amount), 0)
+			        FROM payments p
+			        JOIN loan_installments li ON li.id = p.installment_id
+			        JOIN loans l ON l.id = li.loan_id
+			        WHERE l.customer_id = c.id
+			          AND date(p.payment_date) BETWEEN date(?) AND date(?)) AS interest_paid
+			FROM customers c
+		)
+		WHERE savings > 0 OR interest_paid > 0
+		ORDER BY name`,
+		yearEnd.Format("2006-01-02"), yearStart.Format("2006-01-02"), yearEnd.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query member contributions: %v", err)
+	}
+	defer rows.Close()
+
+	members := make([]models.SHUMemberAllocation, 0)
+	for rows.Next() {
+		var member models.SHUMemberAllocation
+		err := rows.Scan(&member.CustomerID, &member.CustomerName, &member.Savings, &member.InterestPaid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan member contribution: %v", err)
+		}
+		if member.InterestPaid < 0 {
+			member.InterestPaid = 0
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// creditSHU credits a member's share to their simpanan sukarela, opening the
+// account when they have none, and returns the transaction's ID
+func creditSHU(tx *sql.Tx, member models.SHUMemberAllocation, year int, postedBy *uuid.UUID, at time.Time) (*uuid.UUID, error) {
+	account := &models.SavingsAccount{}
+	var accountID string
+	err := tx.QueryRow(`
+		SELECT id, account_number, status, balance FROM savings_accounts
+		WHERE customer_id = ? AND type = ?`,
+		member.CustomerID.String(), string(models.SavingsTypeSukarela)).Scan(
+		&accountID, &account.AccountNumber, &account.Status, &account.Balance)
+	switch {
+	case err == sql.ErrNoRows:
+		account = &models.SavingsAccount{
+			ID:         uuid.New(),
+			CustomerID: member.CustomerID,
+			Type:       models.SavingsTypeSukarela,
+			Status:     models.SavingsAccountStatusActive,
+			OpenedBy:   postedBy,
+			OpenedAt:   at,
+			UpdatedAt:  at,
+		}
+		if err := insertSavingsAccount(tx, account); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get savings account: %v", err)
+	default:
+		account.ID, err = uuid.Parse(accountID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid savings account ID %q", accountID)
+		}
+		if account.Status == models.SavingsAccountStatusClosed {
+			return nil, fmt.Errorf("savings account %s of %s is closed", account.AccountNumber, member.CustomerName)
+		}
+	}
+
+	balance := account.Balance + member.Total
+	transaction := &models.SavingsTransaction{
+		ID:              uuid.New(),
+		AccountID:       account.ID,
+		Type:            models.SavingsTransactionSHU,
+		Amount:          member.Total,
+		BalanceAfter:    balance,
+		Method:          models.PaymentMethodTransfer,
+		TransactionDate: at,
+		PostedBy:        postedBy,
+		Notes:           fmt.Sprintf("SHU %d", year),
+	}
+	if err := insertSavingsTransaction(tx, transaction); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE savings_accounts SET balance = ?, updated_at = ? WHERE id = ?",
+		balance, at, account.ID.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to update savings balance: %v", err)
+	}
+
+	return &transaction.ID, nil
+}
+
+// percentShare is a percentage of an amount, rounded down to the rupiah.
+// The small margin keeps shares that come out whole from being rounded down
+// a rupiah by floating point error.
+func percentShare(amount int64, percent float64) int64 {
+	return int64(math.Floor(float64(amount)*percent/100 + 1e-6))
+}
+
+// proportionalShare is a pool's share for part of a total, rounded down to
+// the rupiah. It is worked out in big integers, as pool times part can
+// overflow an int64.
+func proportionalShare(pool, part, total int64) int64 {
+	if total <= 0 || part <= 0 {
+		return 0
+	}
+	share := new(big.Int).Mul(big.NewInt(pool), big.NewInt(part))
+	return share.Quo(share, big.NewInt(total)).Int64()
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"koperasi-app/internal/models"
+)
+
+// AmortizationLine is one installment of a repayment schedule. All amounts are
+// in whole rupiah.
+type AmortizationLine struct {
+	Number             int       `json:"number"`
+	DueDate            time.Time `json:"due_date"`
+	PrincipalDue       int64     `json:"principal_due"`
+	InterestDue        int64     `json:"interest_due"`
+	AmountDue          int64     `json:"amount_due"`
+	OutstandingBalance int64     `json:"outstanding_balance"` // principal left after this installment
+}
+
+// AnnuityPayment returns the fixed payment that repays principal over term
+// periods at periodRate (a fraction per period), rounded to the rupiah.
+func AnnuityPayment(principal int64, periodRate float64, term int) int64 {
+	if term <= 0 {
+		return 0
+	}
+
+	p := float64(principal)
+	n := float64(term)
+
+	// PMT formula: PMT = P * [r(1+r)^n] / [(1+r)^n - 1]
+	if periodRate == 0 {
+		return int64(math.Round(p / n))
+	}
+
+	growth := math.Pow(1+periodRate, n)
+	return int64(math.Round(p * periodRate * growth / (growth - 1)))
+}
+
+func validateScheduleParams(principal int64, annualRate float64, term int) error {
+	if principal <= 0 {
+		return fmt.Errorf("principal must be greater than 0")
+	}
+	if annualRate < 0 {
+		return fmt.Errorf("interest rate must not be negative")
+	}
+	if term <= 0 {
+		return fmt.Errorf("term must be at least 1")
+	}
+	return nil
+}
+
+// AmortizationSchedule builds an annuity (efektif) schedule of term
+// installments at frequency for principal at annualRate percent per year.
+// Interest is charged on the outstanding balance and the last installment
+// absorbs rounding, so the principal column always sums to principal exactly.
+func AmortizationSchedule(principal int64, annualRate float64, term int, start time.Time, frequency models.RepaymentFrequency) ([]AmortizationLine, error) {
+	if err := validateScheduleParams(principal, annualRate, term); err != nil {
+		return nil, err
+	}
+
+	periodRate := annualRate / 100 / float64(frequency.PeriodsPerYear())
+	payment := AnnuityPayment(principal, periodRate, term)
+
+	lines := make([]AmortizationLine, 0, term)
+	balance := principal
+	for i := 1; i <= term; i++ {
+		interest := int64(math.Round(float64(balance) * periodRate))
+		principalPart := payment - interest
+
+		// Final installment clears whatever rounding left behind
+		if i == term || principalPart > balance {
+			principalPart = balance
+		}
+		if principalPart < 0 {
+			principalPart = 0
+		}
+
+		balance -= principalPart
+		lines = append(lines, AmortizationLine{
+			Number:             i,
+			DueDate:            frequency.DueDate(start, i),
+			PrincipalDue:       principalPart,
+			InterestDue:        interest,
+			AmountDue:          principalPart + interest,
+			OutstandingBalance: balance,
+		})
+	}
+
+	return lines, nil
+}
+
+// FlatSchedule builds a flat-rate (bunga flat) schedule: interest is charged
+// on the original principal every period and principal is repaid in equal
+// parts. annualRate is the flat rate in percent per year, so a quoted
+// "1.5%/bulan" is 18.
+func FlatSchedule(principal int64, annualRate float64, term int, start time.Time, frequency models.RepaymentFrequency) ([]AmortizationLine, error) {
+	if err := validateScheduleParams(principal, annualRate, term); err != nil {
+		return nil, err
+	}
+
+	interest := int64(math.Round(float64(principal) * annualRate / 100 / float64(frequency.PeriodsPerYear())))
+	return equalPrincipalSchedule(principal, term, start, frequency, func(balance int64) int64 {
+		return interest
+	}), nil
+}
+
+// DecliningBalanceSchedule builds a sliding-balance (bunga menurun) schedule:
+// principal is repaid in equal parts and interest is charged on the balance
+// still outstanding, so installments shrink over time.
+func DecliningBalanceSchedule(principal int64, annualRate float64, term int, start time.Time, frequency models.RepaymentFrequency) ([]AmortizationLine, error) {
+	if err := validateScheduleParams(principal, annualRate, term); err != nil {
+		return nil, err
+	}
+
+	periodRate := annualRate / 100 / float64(frequency.PeriodsPerYear())
+	return equalPrincipalSchedule(principal, term, start, frequency, func(balance int64) int64 {
+		return int64(math.Round(float64(balance) * periodRate))
+	}), nil
+}
+
+// equalPrincipalSchedule repays principal in equal parts, the last one
+// absorbing the remainder, with interest computed from the opening balance.
+func equalPrincipalSchedule(principal int64, term int, start time.Time, frequency models.RepaymentFrequency, interestFor func(balance int64) int64) []AmortizationLine {
+	principalPart := principal / int64(term)
+
+	lines := make([]AmortizationLine, 0, term)
+	balance := principal
+	for i := 1; i <= term; i++ {
+		part := principalPart
+		if i == term {
+			part = balance
+		}
+
+		interest := interestFor(balance)
+		balance -= part
+		lines = append(lines, AmortizationLine{
+			Number:             i,
+			DueDate:            frequency.DueDate(start, i),
+			PrincipalDue:       part,
+			InterestDue:        interest,
+			AmountDue:          part + interest,
+			OutstandingBalance: balance,
+		})
+	}
+
+	return lines
+}
+
+// FlatToEffectiveRate converts a flat annual rate into the annuity (efektif)
+// annual rate that gives the same installment over term installments at
+// frequency. Both rates are in percent per year.
+func FlatToEffectiveRate(flatAnnualRate float64, term int, frequency models.RepaymentFrequency) float64 {
+	if flatAnnualRate <= 0 || term <= 0 {
+		return 0
+	}
+	periods := float64(frequency.PeriodsPerYear())
+
+	// Installment per rupiah of principal under the flat method
+	target := 1/float64(term) + flatAnnualRate/100/periods
+	payment := func(r float64) float64 {
+		growth := math.Pow(1+r, float64(term))
+		return r * growth / (growth - 1)
+	}
+
+	// payment(r) is increasing in r; bisect on the rate per period
+	low, high := 0.0, 1.0
+	for i := 0; i < 100; i++ {
+		mid := (low + high) / 2
+		if payment(mid) < target {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return math.Round((low+high)/2*periods*100*100) / 100
+}
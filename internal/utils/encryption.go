@@ -1,36 +1,55 @@
 package utils
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 
 	"filippo.io/age"
+	"golang.org/x/crypto/hkdf"
 )
 
-var (
-	// This should be loaded from config in production
-	// For demo purposes, using a static key
-	encryptionIdentity *age.X25519Identity
-)
+// Encryptor encrypts and decrypts customer PII with a single age identity.
+// Services receive one from the caller instead of relying on package state.
+type Encryptor struct {
+	identity      *age.X25519Identity
+	version       int
+	blindIndexKey []byte
+}
 
-func init() {
-	// Generate a new identity for demo purposes
-	// In production, this should be loaded from secure configuration
-	identity, err := age.GenerateX25519Identity()
-	if err != nil {
-		panic(fmt.Sprintf("Failed to generate encryption identity: %v", err))
+// NewEncryptor creates an Encryptor for the given identity. version is the
+// key version recorded on every row the encryptor writes.
+func NewEncryptor(identity *age.X25519Identity, version int) *Encryptor {
+	// The blind index key is derived from the identity so it rotates with it
+	blindIndexKey := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, []byte(identity.String()), nil, []byte("koperasi blind index v1"))
+	if _, err := io.ReadFull(kdf, blindIndexKey); err != nil {
+		panic(fmt.Sprintf("Failed to derive blind index key: %v", err))
 	}
-	encryptionIdentity = identity
+
+	return &Encryptor{identity: identity, version: version, blindIndexKey: blindIndexKey}
+}
+
+// Identity returns the age identity used by the encryptor
+func (e *Encryptor) Identity() *age.X25519Identity {
+	return e.identity
+}
+
+// Version returns the key version of the encryptor's identity
+func (e *Encryptor) Version() int {
+	return e.version
 }
 
 // Encrypt encrypts plaintext using age encryption
-func Encrypt(plaintext string) (string, error) {
+func (e *Encryptor) Encrypt(plaintext string) (string, error) {
 	if plaintext == "" {
 		return "", nil
 	}
 
-	recipient := encryptionIdentity.Recipient()
+	recipient := e.identity.Recipient()
 	
 	// Create a buffer to hold the encrypted data
 	var encrypted []byte
@@ -53,7 +72,7 @@ func Encrypt(plaintext string) (string, error) {
 }
 
 // Decrypt decrypts ciphertext using age decryption
-func Decrypt(ciphertext string) (string, error) {
+func (e *Encryptor) Decrypt(ciphertext string) (string, error) {
 	if ciphertext == "" {
 		return "", nil
 	}
@@ -64,7 +83,7 @@ func Decrypt(ciphertext string) (string, error) {
 	}
 
 	r := &readBuffer{buf: encrypted}
-	ageReader, err := age.Decrypt(r, encryptionIdentity)
+	ageReader, err := age.Decrypt(r, e.identity)
 	if err != nil {
 		return "", fmt.Errorf("failed to decrypt: %v", err)
 	}
@@ -77,6 +96,18 @@ func Decrypt(ciphertext string) (string, error) {
 	return string(plaintext), nil
 }
 
+// BlindIndex returns a keyed HMAC of an already normalized value, used for
+// exact-match lookups on encrypted columns. Empty values return "".
+func (e *Encryptor) BlindIndex(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, e.blindIndexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // MaskNIK masks NIK for display (shows only last 4 digits)
 func MaskNIK(nik string) string {
 	if len(nik) < 4 {
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// ParseIdentity parses an age secret key (AGE-SECRET-KEY-1...)
+func ParseIdentity(key string) (*age.X25519Identity, error) {
+	identity, err := age.ParseX25519Identity(strings.TrimSpace(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %v", err)
+	}
+	return identity, nil
+}
+
+// LoadIdentityFile reads an age identity from a key file. The file must not be
+// readable by group or others.
+func LoadIdentityFile(path string) (*age.X25519Identity, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Windows does not use unix permission bits
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("encryption key file %s has permissions %04o, expected 0600", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key file: %v", err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return ParseIdentity(line)
+	}
+
+	return nil, fmt.Errorf("encryption key file %s is empty", path)
+}
+
+// WriteIdentityFile writes an age identity to a new key file with 0600
+// permissions. It never overwrites an existing file.
+func WriteIdentityFile(path string, identity *age.X25519Identity) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create encryption key file: %v", err)
+	}
+	defer file.Close()
+
+	content := fmt.Sprintf("# public key: %s\n%s\n", identity.Recipient().String(), identity.String())
+	if _, err := file.WriteString(content); err != nil {
+		return fmt.Errorf("failed to write encryption key file: %v", err)
+	}
+
+	return file.Sync()
+}
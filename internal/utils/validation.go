@@ -7,6 +7,34 @@ import (
 	"strings"
 )
 
+// NormalizeNIK removes whitespace from a NIK
+func NormalizeNIK(nik string) string {
+	return strings.Join(strings.Fields(nik), "")
+}
+
+// NormalizeIndonesianPhone strips separators and converts +62/62 prefixes to 0
+func NormalizeIndonesianPhone(phone string) string {
+	phone = strings.ReplaceAll(phone, " ", "")
+	phone = strings.ReplaceAll(phone, "-", "")
+	phone = strings.ReplaceAll(phone, ".", "")
+	phone = strings.ReplaceAll(phone, "(", "")
+	phone = strings.ReplaceAll(phone, ")", "")
+	
+	// Check if it starts with +62 and convert to 0
+	if strings.HasPrefix(phone, "+62") {
+		phone = "0" + phone[3:]
+	} else if strings.HasPrefix(phone, "62") {
+		phone = "0" + phone[2:]
+	}
+	
+	return phone
+}
+
+// NormalizeEmail lower-cases and trims an email address
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // ValidateNIK validates Indonesian NIK (16 digits only)
 func ValidateNIK(nik string) error {
 	// Remove any whitespace
@@ -54,19 +82,8 @@ func ExtractDateFromNIK(nik string) (day, month, year int, isFemale bool, err er
 
 // ValidateIndonesianPhone validates Indonesian phone numbers
 func ValidateIndonesianPhone(phone string) error {
-	// Remove any whitespace, dashes, or dots
-	phone = strings.ReplaceAll(phone, " ", "")
-	phone = strings.ReplaceAll(phone, "-", "")
-	phone = strings.ReplaceAll(phone, ".", "")
-	phone = strings.ReplaceAll(phone, "(", "")
-	phone = strings.ReplaceAll(phone, ")", "")
-	
-	// Check if it starts with +62 and convert to 0
-	if strings.HasPrefix(phone, "+62") {
-		phone = "0" + phone[3:]
-	} else if strings.HasPrefix(phone, "62") {
-		phone = "0" + phone[2:]
-	}
+	// Remove any whitespace, dashes, or dots and convert +62 to 0
+	phone = NormalizeIndonesianPhone(phone)
 	
 	// Check if all characters are digits
 	if _, err := strconv.ParseInt(phone, 10, 64); err != nil {
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_customers_key_version;
+ALTER TABLE customers DROP COLUMN key_version;
+DROP TABLE IF EXISTS encryption_keys;
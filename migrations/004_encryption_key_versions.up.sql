@@ -0,0 +1,12 @@
+-- Encryption keys used for customer PII, one row per key version
+CREATE TABLE encryption_keys (
+    version INTEGER PRIMARY KEY,
+    public_key TEXT UNIQUE NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    retired_at DATETIME
+);
+
+-- Key version each customer row is encrypted with (NULL = written before versioning)
+ALTER TABLE customers ADD COLUMN key_version INTEGER;
+
+CREATE INDEX idx_customers_key_version ON customers(key_version);
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_customers_email_hash;
+DROP INDEX IF EXISTS idx_customers_phone_hash;
+DROP INDEX IF EXISTS idx_customers_nik_hash;
+
+CREATE INDEX idx_customers_nik ON customers(nik);
+CREATE INDEX idx_customers_phone ON customers(phone);
+
+ALTER TABLE customers DROP COLUMN email_hash;
+ALTER TABLE customers DROP COLUMN phone_hash;
+ALTER TABLE customers DROP COLUMN nik_hash;
@@ -0,0 +1,14 @@
+-- Keyed HMAC blind indexes for exact-match lookups on encrypted PII.
+-- Values are backfilled by the application on startup because the HMAC key
+-- is derived from the encryption key.
+ALTER TABLE customers ADD COLUMN nik_hash TEXT;
+ALTER TABLE customers ADD COLUMN phone_hash TEXT;
+ALTER TABLE customers ADD COLUMN email_hash TEXT;
+
+CREATE UNIQUE INDEX idx_customers_nik_hash ON customers(nik_hash);
+CREATE UNIQUE INDEX idx_customers_phone_hash ON customers(phone_hash);
+CREATE UNIQUE INDEX idx_customers_email_hash ON customers(email_hash);
+
+-- Ciphertext indexes are useless for lookups since age encryption is randomized
+DROP INDEX IF EXISTS idx_customers_nik;
+DROP INDEX IF EXISTS idx_customers_phone;
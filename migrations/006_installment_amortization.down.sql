@@ -0,0 +1,3 @@
+ALTER TABLE loan_installments DROP COLUMN outstanding_balance;
+ALTER TABLE loan_installments DROP COLUMN interest_due;
+ALTER TABLE loan_installments DROP COLUMN principal_due;
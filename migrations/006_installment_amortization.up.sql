@@ -0,0 +1,40 @@
+-- Principal/interest split and remaining principal for each installment
+ALTER TABLE loan_installments ADD COLUMN principal_due INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE loan_installments ADD COLUMN interest_due INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE loan_installments ADD COLUMN outstanding_balance INTEGER NOT NULL DEFAULT 0;
+
+-- Installments of existing loans are split as an annuity at the loan's
+-- rate: each owes a month's interest on the principal still open and the
+-- rest of amount_due repays principal. The last installment repays what is
+-- left, up to its amount_due.
+CREATE TEMP TABLE installment_split AS
+WITH RECURSIVE schedule(id, loan_id, number, last, rate, amount_due, opening) AS (
+    SELECT li.id, li.loan_id, li.number,
+           (SELECT MAX(number) FROM loan_installments WHERE loan_id = li.loan_id),
+           l.interest_rate / 1200.0, li.amount_due, l.amount
+    FROM loan_installments li
+    JOIN loans l ON l.id = li.loan_id
+    WHERE li.number = (SELECT MIN(number) FROM loan_installments WHERE loan_id = li.loan_id)
+    UNION ALL
+    SELECT li.id, li.loan_id, li.number, s.last, s.rate, li.amount_due,
+           s.opening - s.amount_due + MIN(CAST(ROUND(MAX(s.opening, 0) * s.rate) AS INTEGER), s.amount_due)
+    FROM schedule s
+    JOIN loan_installments li ON li.loan_id = s.loan_id AND li.number = s.number + 1
+),
+interest AS (
+    SELECT id, amount_due, opening,
+           CASE WHEN number = last THEN amount_due - MIN(MAX(opening, 0), amount_due)
+                ELSE MIN(CAST(ROUND(MAX(opening, 0) * rate) AS INTEGER), amount_due)
+           END AS interest_due
+    FROM schedule
+)
+SELECT id, amount_due - interest_due AS principal_due, interest_due,
+       MAX(opening - (amount_due - interest_due), 0) AS outstanding_balance
+FROM interest;
+
+UPDATE loan_installments
+SET principal_due = s.principal_due, interest_due = s.interest_due, outstanding_balance = s.outstanding_balance
+FROM installment_split s
+WHERE s.id = loan_installments.id;
+
+DROP TABLE installment_split;
@@ -0,0 +1 @@
+ALTER TABLE loans DROP COLUMN interest_method;
@@ -0,0 +1,2 @@
+-- How interest is computed for the loan's schedule; existing loans are annuity
+ALTER TABLE loans ADD COLUMN interest_method TEXT NOT NULL DEFAULT 'annuity' CHECK (interest_method IN ('flat', 'annuity', 'declining_balance'));
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_loans_product_id;
+ALTER TABLE loans DROP COLUMN provision_fee;
+ALTER TABLE loans DROP COLUMN admin_fee;
+ALTER TABLE loans DROP COLUMN product_id;
+
+DROP TRIGGER IF EXISTS trigger_loan_products_updated_at;
+DROP INDEX IF EXISTS idx_loan_products_active;
+DROP TABLE IF EXISTS loan_products;
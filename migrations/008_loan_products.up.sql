@@ -0,0 +1,41 @@
+-- Loan products define the limits, pricing and eligibility rules for new loans
+CREATE TABLE loan_products (
+    id TEXT PRIMARY KEY,
+    code TEXT UNIQUE NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    min_amount INTEGER NOT NULL,
+    max_amount INTEGER NOT NULL,
+    allowed_terms TEXT NOT NULL, -- JSON array of terms in months, e.g. [3,6,12]
+    default_interest_rate REAL NOT NULL, -- percent per year
+    min_interest_rate REAL NOT NULL,
+    max_interest_rate REAL NOT NULL,
+    interest_method TEXT NOT NULL DEFAULT 'annuity' CHECK (interest_method IN ('flat', 'annuity', 'declining_balance')),
+    admin_fee INTEGER NOT NULL DEFAULT 0, -- flat fee in rupiah
+    provision_fee_rate REAL NOT NULL DEFAULT 0, -- percent of the loan amount
+    max_active_loans INTEGER NOT NULL DEFAULT 1,
+    min_membership_months INTEGER NOT NULL DEFAULT 0,
+    min_monthly_income INTEGER NOT NULL DEFAULT 0,
+    require_ktp_verified BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (min_amount > 0 AND max_amount >= min_amount),
+    CHECK (min_interest_rate <= default_interest_rate AND default_interest_rate <= max_interest_rate)
+);
+
+CREATE INDEX idx_loan_products_active ON loan_products(active);
+
+CREATE TRIGGER trigger_loan_products_updated_at 
+    AFTER UPDATE ON loan_products 
+    BEGIN 
+        UPDATE loan_products SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id; 
+    END;
+
+-- Loans created before products existed keep a NULL product_id. No REFERENCES
+-- clause so the column can be dropped again on rollback.
+ALTER TABLE loans ADD COLUMN product_id TEXT;
+ALTER TABLE loans ADD COLUMN admin_fee INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE loans ADD COLUMN provision_fee INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_loans_product_id ON loans(product_id);
@@ -0,0 +1,3 @@
+ALTER TABLE loan_installments DROP COLUMN penalty_accrued_through;
+ALTER TABLE loan_installments DROP COLUMN penalty_paid;
+ALTER TABLE loan_installments DROP COLUMN penalty_due;
@@ -0,0 +1,6 @@
+-- Late payment penalty (denda). amount_paid only covers principal and
+-- interest; penalty payments are tracked separately in penalty_paid.
+ALTER TABLE loan_installments ADD COLUMN penalty_due INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE loan_installments ADD COLUMN penalty_paid INTEGER NOT NULL DEFAULT 0;
+-- Last day penalties were accrued for, so the hourly job charges each day once
+ALTER TABLE loan_installments ADD COLUMN penalty_accrued_through DATE;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_payments_payment_date;
+DROP INDEX IF EXISTS idx_payments_installment_id;
+DROP INDEX IF EXISTS idx_payments_loan_id;
+DROP TABLE IF EXISTS payments;
@@ -0,0 +1,41 @@
+-- Payment ledger. Installment amount_paid and penalty_paid are the sums of
+-- their payments. A reversal is a new row with negated amounts that points
+-- at the original payment through reversal_of.
+CREATE TABLE payments (
+    id TEXT PRIMARY KEY,
+    loan_id TEXT NOT NULL,
+    installment_id TEXT NOT NULL,
+    receipt_number TEXT UNIQUE NOT NULL,
+    amount INTEGER NOT NULL,
+    penalty_amount INTEGER NOT NULL DEFAULT 0,
+    interest_amount INTEGER NOT NULL DEFAULT 0,
+    principal_amount INTEGER NOT NULL DEFAULT 0,
+    method TEXT NOT NULL CHECK (method IN ('cash', 'transfer', 'qris')),
+    reference TEXT,
+    payment_date DATETIME NOT NULL,
+    posted_by TEXT,
+    idempotency_key TEXT UNIQUE,
+    reversal_of TEXT UNIQUE, -- each payment can be reversed once
+    notes TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (loan_id) REFERENCES loans(id) ON DELETE CASCADE,
+    FOREIGN KEY (installment_id) REFERENCES loan_installments(id) ON DELETE CASCADE,
+    FOREIGN KEY (posted_by) REFERENCES users(id),
+    FOREIGN KEY (reversal_of) REFERENCES payments(id)
+);
+
+CREATE INDEX idx_payments_loan_id ON payments(loan_id);
+CREATE INDEX idx_payments_installment_id ON payments(installment_id);
+CREATE INDEX idx_payments_payment_date ON payments(payment_date);
+
+-- Carry over amounts paid before the ledger existed as one payment each
+INSERT INTO payments (id, loan_id, installment_id, receipt_number, amount, penalty_amount, interest_amount,
+                      principal_amount, method, reference, payment_date)
+SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+             substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+       loan_id, id, 'MIG-' || id,
+       amount_paid + penalty_paid, penalty_paid,
+       min(amount_paid, interest_due), amount_paid - min(amount_paid, interest_due),
+       'cash', 'migrated', COALESCE(paid_at, updated_at)
+FROM loan_installments
+WHERE amount_paid > 0 OR penalty_paid > 0;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_batch_id;
+ALTER TABLE payments DROP COLUMN batch_id;
+ALTER TABLE payments DROP COLUMN credit_amount;
@@ -0,0 +1,8 @@
+-- Part of a payment that went to (positive) or came from (negative) the
+-- loan's credit balance. amount = penalty + interest + principal + credit.
+ALTER TABLE payments ADD COLUMN credit_amount INTEGER NOT NULL DEFAULT 0;
+
+-- Groups the per-installment entries of one loan-level payment
+ALTER TABLE payments ADD COLUMN batch_id TEXT;
+
+CREATE INDEX idx_payments_batch_id ON payments(batch_id);
@@ -0,0 +1,4 @@
+ALTER TABLE payments DROP COLUMN fee_amount;
+ALTER TABLE loan_installments DROP COLUMN settled_at;
+ALTER TABLE loans DROP COLUMN settled_at;
+ALTER TABLE loans DROP COLUMN settlement_reason;
@@ -0,0 +1,10 @@
+-- Early settlement (pelunasan dipercepat). Settled installments keep the
+-- 'paid' status, since the status CHECK cannot change without rebuilding
+-- loan_installments; settled_at tells them apart from regular payments.
+ALTER TABLE loans ADD COLUMN settlement_reason TEXT;
+ALTER TABLE loans ADD COLUMN settled_at DATETIME;
+
+ALTER TABLE loan_installments ADD COLUMN settled_at DATETIME;
+
+-- Early settlement fee collected with a payment
+ALTER TABLE payments ADD COLUMN fee_amount INTEGER NOT NULL DEFAULT 0;
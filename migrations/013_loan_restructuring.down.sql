@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_loan_installments_schedule;
+DROP INDEX IF EXISTS idx_loan_restructurings_loan_id;
+DROP TABLE IF EXISTS loan_restructurings;
+ALTER TABLE loans DROP COLUMN restructured;
+ALTER TABLE loans DROP COLUMN schedule_version;
+ALTER TABLE loan_installments DROP COLUMN superseded_at;
+ALTER TABLE loan_installments DROP COLUMN schedule_version;
@@ -0,0 +1,30 @@
+-- Restructuring replaces a loan's schedule. The replaced installments keep
+-- their payments and stay as a superseded schedule version.
+ALTER TABLE loan_installments ADD COLUMN schedule_version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE loan_installments ADD COLUMN superseded_at DATETIME;
+
+ALTER TABLE loans ADD COLUMN schedule_version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE loans ADD COLUMN restructured BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE loan_restructurings (
+    id TEXT PRIMARY KEY,
+    loan_id TEXT NOT NULL,
+    previous_version INTEGER NOT NULL,
+    schedule_version INTEGER NOT NULL,
+    outstanding_principal INTEGER NOT NULL,
+    capitalized_amount INTEGER NOT NULL DEFAULT 0, -- arrears added to the new principal
+    previous_interest_rate REAL NOT NULL,
+    interest_rate REAL NOT NULL,
+    previous_term INTEGER NOT NULL, -- installments left on the replaced schedule
+    term INTEGER NOT NULL,
+    start_date DATE NOT NULL, -- the new schedule runs from here
+    reason TEXT NOT NULL,
+    approved_by TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (loan_id) REFERENCES loans(id) ON DELETE CASCADE,
+    FOREIGN KEY (approved_by) REFERENCES users(id),
+    UNIQUE (loan_id, schedule_version)
+);
+
+CREATE INDEX idx_loan_restructurings_loan_id ON loan_restructurings(loan_id);
+CREATE INDEX idx_loan_installments_schedule ON loan_installments(loan_id, schedule_version);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_loan_status_history_loan_id;
+DROP TABLE IF EXISTS loan_status_history;
@@ -0,0 +1,20 @@
+CREATE TABLE loan_status_history (
+    id TEXT PRIMARY KEY,
+    loan_id TEXT NOT NULL,
+    from_status TEXT, -- NULL for the entry written when the loan is created
+    to_status TEXT NOT NULL,
+    changed_by TEXT,  -- NULL for changes made by the system
+    reason TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (loan_id) REFERENCES loans(id) ON DELETE CASCADE,
+    FOREIGN KEY (changed_by) REFERENCES users(id)
+);
+
+CREATE INDEX idx_loan_status_history_loan_id ON loan_status_history(loan_id, created_at);
+
+-- Existing loans start their history at their current status
+INSERT INTO loan_status_history (id, loan_id, from_status, to_status, reason, created_at)
+SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+             substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+       id, NULL, status, 'migrated', created_at
+FROM loans;
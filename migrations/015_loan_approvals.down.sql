@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_loan_approvals_loan_id;
+DROP TABLE IF EXISTS loan_approvals;
+
+ALTER TABLE loans DROP COLUMN submitted_at;
+ALTER TABLE loans DROP COLUMN submitted_by;
+ALTER TABLE loans DROP COLUMN created_by;
@@ -0,0 +1,23 @@
+ALTER TABLE loans ADD COLUMN created_by TEXT;
+ALTER TABLE loans ADD COLUMN submitted_by TEXT;
+ALTER TABLE loans ADD COLUMN submitted_at DATETIME; -- NULL while the loan is still a draft
+
+CREATE TABLE loan_approvals (
+    id TEXT PRIMARY KEY,
+    loan_id TEXT NOT NULL,
+    level INTEGER NOT NULL, -- 1 for the first approver; loans above the multi-level threshold need more
+    approver_id TEXT NOT NULL,
+    decision TEXT NOT NULL CHECK (decision IN ('approved', 'rejected')),
+    notes TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (loan_id) REFERENCES loans(id) ON DELETE CASCADE,
+    FOREIGN KEY (approver_id) REFERENCES users(id),
+    UNIQUE (loan_id, approver_id)
+);
+
+CREATE INDEX idx_loan_approvals_loan_id ON loan_approvals(loan_id);
+
+-- The creator is known for loans created since the status history was added
+UPDATE loans
+SET created_by = (SELECT changed_by FROM loan_status_history h
+                  WHERE h.loan_id = loans.id AND h.from_status IS NULL);
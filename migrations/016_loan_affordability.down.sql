@@ -0,0 +1,2 @@
+ALTER TABLE loans DROP COLUMN dsr_override_reason;
+ALTER TABLE loans DROP COLUMN other_obligations;
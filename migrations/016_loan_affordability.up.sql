@@ -0,0 +1,6 @@
+-- Monthly installments the member pays outside the koperasi, as declared on
+-- the application
+ALTER TABLE loans ADD COLUMN other_obligations INTEGER NOT NULL DEFAULT 0;
+-- Why the loan may go ahead although its repayment-to-income ratio is above
+-- the configured maximum
+ALTER TABLE loans ADD COLUMN dsr_override_reason TEXT;
@@ -0,0 +1,4 @@
+ALTER TABLE loans DROP COLUMN credit_scored_at;
+ALTER TABLE loans DROP COLUMN credit_reasons;
+ALTER TABLE loans DROP COLUMN credit_grade;
+ALTER TABLE loans DROP COLUMN credit_score;
@@ -0,0 +1,7 @@
+-- Applicant credit score taken when the loan was created. Loans created
+-- before scoring existed have none.
+ALTER TABLE loans ADD COLUMN credit_score INTEGER;
+ALTER TABLE loans ADD COLUMN credit_grade TEXT;
+-- JSON array of reason codes
+ALTER TABLE loans ADD COLUMN credit_reasons TEXT;
+ALTER TABLE loans ADD COLUMN credit_scored_at DATETIME;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_loan_collectibility_as_of;
+DROP TABLE IF EXISTS loan_collectibility;
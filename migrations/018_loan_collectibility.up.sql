@@ -0,0 +1,17 @@
+-- Daily OJK collectibility (kolektibilitas) of each running loan with the
+-- loan-loss provision (PPAP) it requires
+CREATE TABLE loan_collectibility (
+    id TEXT PRIMARY KEY,
+    loan_id TEXT NOT NULL,
+    as_of DATE NOT NULL,
+    dpd INTEGER NOT NULL,
+    collectibility INTEGER NOT NULL CHECK (collectibility BETWEEN 1 AND 5),
+    outstanding_principal INTEGER NOT NULL,
+    provision_rate REAL NOT NULL, -- percent of the outstanding principal
+    provision INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (loan_id) REFERENCES loans(id) ON DELETE CASCADE,
+    UNIQUE (loan_id, as_of)
+);
+
+CREATE INDEX idx_loan_collectibility_as_of ON loan_collectibility(as_of);
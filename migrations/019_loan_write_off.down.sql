@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS idx_loan_recoveries_recovery_date;
+DROP INDEX IF EXISTS idx_loan_recoveries_loan_id;
+DROP TABLE IF EXISTS loan_recoveries;
+
+ALTER TABLE loans DROP COLUMN written_off_penalty;
+ALTER TABLE loans DROP COLUMN written_off_interest;
+ALTER TABLE loans DROP COLUMN written_off_principal;
+ALTER TABLE loans DROP COLUMN write_off_reason;
+ALTER TABLE loans DROP COLUMN written_off_by;
+ALTER TABLE loans DROP COLUMN written_off_at;
+
+UPDATE loans SET status = 'defaulted' WHERE status = 'written_off';
+
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema
+SET sql = replace(sql, '''defaulted'', ''cancelled'', ''written_off'')', '''defaulted'', ''cancelled'')')
+WHERE type = 'table' AND name = 'loans';
+PRAGMA writable_schema = RESET;
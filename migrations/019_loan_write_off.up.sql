@@ -0,0 +1,37 @@
+-- Write-off (hapus buku). The loans status CHECK gains 'written_off' by
+-- editing the stored schema: rebuilding loans would cascade deletes into
+-- every table referencing it, and widening an IN list leaves existing rows
+-- valid.
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema
+SET sql = replace(sql, '''defaulted'', ''cancelled'')', '''defaulted'', ''cancelled'', ''written_off'')')
+WHERE type = 'table' AND name = 'loans';
+PRAGMA writable_schema = RESET;
+
+ALTER TABLE loans ADD COLUMN written_off_at DATETIME;
+ALTER TABLE loans ADD COLUMN written_off_by TEXT;
+ALTER TABLE loans ADD COLUMN write_off_reason TEXT;
+-- What was still owed when the loan was written off
+ALTER TABLE loans ADD COLUMN written_off_principal INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE loans ADD COLUMN written_off_interest INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE loans ADD COLUMN written_off_penalty INTEGER NOT NULL DEFAULT 0;
+
+-- Amounts collected on written-off loans. They are kept apart from the
+-- payment ledger so installments stay as they were at write-off.
+CREATE TABLE loan_recoveries (
+    id TEXT PRIMARY KEY,
+    loan_id TEXT NOT NULL,
+    receipt_number TEXT UNIQUE NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    method TEXT NOT NULL CHECK (method IN ('cash', 'transfer', 'qris')),
+    reference TEXT,
+    recovery_date DATETIME NOT NULL,
+    posted_by TEXT,
+    notes TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (loan_id) REFERENCES loans(id) ON DELETE CASCADE,
+    FOREIGN KEY (posted_by) REFERENCES users(id)
+);
+
+CREATE INDEX idx_loan_recoveries_loan_id ON loan_recoveries(loan_id);
+CREATE INDEX idx_loan_recoveries_recovery_date ON loan_recoveries(recovery_date);
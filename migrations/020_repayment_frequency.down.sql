@@ -0,0 +1,2 @@
+ALTER TABLE loans DROP COLUMN repayment_frequency;
+ALTER TABLE loan_products DROP COLUMN repayment_frequency;
@@ -0,0 +1,7 @@
+-- Installments can fall due weekly, biweekly or monthly. term counts
+-- installments at that frequency, so existing loans and products are
+-- monthly and keep their meaning.
+ALTER TABLE loan_products ADD COLUMN repayment_frequency TEXT NOT NULL DEFAULT 'monthly'
+    CHECK (repayment_frequency IN ('weekly', 'biweekly', 'monthly'));
+ALTER TABLE loans ADD COLUMN repayment_frequency TEXT NOT NULL DEFAULT 'monthly'
+    CHECK (repayment_frequency IN ('weekly', 'biweekly', 'monthly'));
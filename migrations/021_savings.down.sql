@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_savings_transactions_account_id;
+DROP INDEX IF EXISTS idx_savings_accounts_customer_id;
+DROP TABLE IF EXISTS savings_transactions;
+DROP TABLE IF EXISTS savings_accounts;
@@ -0,0 +1,40 @@
+-- Member savings (simpanan). A member has at most one account of each type:
+-- pokok is paid once on joining, wajib is owed every month and sukarela can
+-- be deposited and withdrawn freely.
+CREATE TABLE savings_accounts (
+    id TEXT PRIMARY KEY,
+    customer_id TEXT NOT NULL,
+    account_number TEXT UNIQUE NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('pokok', 'wajib', 'sukarela')),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'closed')),
+    balance INTEGER NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    monthly_obligation INTEGER NOT NULL DEFAULT 0, -- simpanan wajib owed per month
+    opened_by TEXT,
+    opened_at DATETIME NOT NULL,
+    closed_at DATETIME,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (customer_id, type),
+    FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE,
+    FOREIGN KEY (opened_by) REFERENCES users(id)
+);
+
+-- Running balance ledger: balance_after is the account balance once the
+-- transaction is posted
+CREATE TABLE savings_transactions (
+    id TEXT PRIMARY KEY,
+    account_id TEXT NOT NULL,
+    receipt_number TEXT UNIQUE NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('deposit', 'withdrawal')),
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    balance_after INTEGER NOT NULL CHECK (balance_after >= 0),
+    method TEXT NOT NULL CHECK (method IN ('cash', 'transfer', 'qris')),
+    reference TEXT,
+    transaction_date DATETIME NOT NULL,
+    posted_by TEXT,
+    notes TEXT,
+    FOREIGN KEY (account_id) REFERENCES savings_accounts(id) ON DELETE CASCADE,
+    FOREIGN KEY (posted_by) REFERENCES users(id)
+);
+
+CREATE INDEX idx_savings_accounts_customer_id ON savings_accounts(customer_id);
+CREATE INDEX idx_savings_transactions_account_id ON savings_transactions(account_id, transaction_date);
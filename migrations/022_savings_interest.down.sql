@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS savings_interest_accruals;
+
+-- Keep the balances adding up: credited interest becomes a deposit and the
+-- tax withheld a withdrawal
+UPDATE savings_transactions SET type = 'deposit' WHERE type = 'interest';
+UPDATE savings_transactions SET type = 'withdrawal' WHERE type = 'tax';
+
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema
+SET sql = replace(sql, '(''deposit'', ''withdrawal'', ''interest'', ''tax'')', '(''deposit'', ''withdrawal'')')
+WHERE type = 'table' AND name = 'savings_transactions';
+PRAGMA writable_schema = RESET;
@@ -0,0 +1,24 @@
+-- Interest on simpanan sukarela. Credited interest and the tax withheld from
+-- it are posted to the savings ledger as their own transaction types. As for
+-- the loans status, the type CHECK is widened by editing the stored schema;
+-- widening an IN list leaves existing rows valid.
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema
+SET sql = replace(sql, '(''deposit'', ''withdrawal'')', '(''deposit'', ''withdrawal'', ''interest'', ''tax'')')
+WHERE type = 'table' AND name = 'savings_transactions';
+PRAGMA writable_schema = RESET;
+
+-- One row per account and day with the interest earned on the end-of-day
+-- balance. Interest is kept in fractions of a rupiah until it is credited.
+CREATE TABLE savings_interest_accruals (
+    account_id TEXT NOT NULL,
+    accrual_date DATE NOT NULL,
+    balance INTEGER NOT NULL,
+    annual_rate REAL NOT NULL,
+    interest REAL NOT NULL,
+    credited_at DATETIME,
+    transaction_id TEXT, -- the interest line it was credited with; NULL when it rounded down to nothing
+    PRIMARY KEY (account_id, accrual_date),
+    FOREIGN KEY (account_id) REFERENCES savings_accounts(id) ON DELETE CASCADE,
+    FOREIGN KEY (transaction_id) REFERENCES savings_transactions(id)
+);
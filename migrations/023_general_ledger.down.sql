@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_journal_lines_account_code;
+DROP INDEX IF EXISTS idx_journal_entries_source;
+DROP INDEX IF EXISTS idx_journal_entries_entry_date;
+DROP TABLE IF EXISTS journal_lines;
+DROP TABLE IF EXISTS journal_entries;
+DROP TABLE IF EXISTS gl_accounts;
@@ -0,0 +1,110 @@
+-- General ledger. Account codes start with the digit of their type:
+-- 1 asset, 2 liability, 3 equity, 4 income, 5 expense. System accounts are
+-- the ones the posting rules book to.
+CREATE TABLE gl_accounts (
+    code TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('asset', 'liability', 'equity', 'income', 'expense')),
+    system BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO gl_accounts (code, name, type, system) VALUES
+    ('1101', 'Kas', 'asset', TRUE),
+    ('1102', 'Bank', 'asset', TRUE),
+    ('1201', 'Piutang Pinjaman Anggota', 'asset', TRUE),
+    ('2101', 'Simpanan Sukarela', 'liability', TRUE),
+    ('2102', 'Titipan Angsuran', 'liability', TRUE),
+    ('2103', 'Utang Pajak', 'liability', TRUE),
+    ('2104', 'Bunga dan Denda Ditangguhkan', 'liability', TRUE),
+    ('3101', 'Simpanan Pokok', 'equity', TRUE),
+    ('3102', 'Simpanan Wajib', 'equity', TRUE),
+    ('3201', 'Cadangan', 'equity', FALSE),
+    ('3301', 'SHU Belum Dibagi', 'equity', FALSE),
+    ('3901', 'Ekuitas Saldo Awal', 'equity', FALSE),
+    ('4101', 'Pendapatan Bunga Pinjaman', 'income', TRUE),
+    ('4102', 'Pendapatan Denda', 'income', TRUE),
+    ('4103', 'Pendapatan Administrasi', 'income', TRUE),
+    ('4104', 'Pendapatan Provisi', 'income', TRUE),
+    ('4105', 'Pendapatan Pemulihan Piutang', 'income', TRUE),
+    ('5101', 'Beban Bunga Simpanan', 'expense', TRUE),
+    ('5102', 'Beban Penghapusan Piutang', 'expense', TRUE),
+    ('5201', 'Beban Gaji', 'expense', FALSE),
+    ('5202', 'Beban Operasional', 'expense', FALSE);
+
+-- source and source_id point at what an automatic entry was posted for: the
+-- loan for a disbursement or write-off, otherwise the payment, savings
+-- transaction, restructuring or recovery
+CREATE TABLE journal_entries (
+    id TEXT PRIMARY KEY,
+    entry_number TEXT UNIQUE NOT NULL,
+    entry_date DATETIME NOT NULL,
+    description TEXT NOT NULL,
+    source TEXT NOT NULL CHECK (source IN ('manual', 'disbursement', 'payment', 'savings', 'restructuring',
+                                           'write_off', 'recovery')),
+    source_id TEXT,
+    posted_by TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (posted_by) REFERENCES users(id)
+);
+
+-- Every line is either a debit or a credit; that the lines of an entry
+-- balance is checked before they are written
+CREATE TABLE journal_lines (
+    entry_id TEXT NOT NULL,
+    line_number INTEGER NOT NULL,
+    account_code TEXT NOT NULL,
+    debit INTEGER NOT NULL DEFAULT 0 CHECK (debit >= 0),
+    credit INTEGER NOT NULL DEFAULT 0 CHECK (credit >= 0),
+    memo TEXT,
+    PRIMARY KEY (entry_id, line_number),
+    CHECK ((debit > 0) + (credit > 0) = 1),
+    FOREIGN KEY (entry_id) REFERENCES journal_entries(id) ON DELETE CASCADE,
+    FOREIGN KEY (account_code) REFERENCES gl_accounts(code)
+);
+
+CREATE INDEX idx_journal_entries_entry_date ON journal_entries(entry_date);
+CREATE UNIQUE INDEX idx_journal_entries_source ON journal_entries(source, source_id) WHERE source_id IS NOT NULL;
+CREATE INDEX idx_journal_lines_account_code ON journal_lines(account_code);
+
+-- Book what loans, payments and savings from before the ledger left open as
+-- one opening entry, so the postings that follow start from their balances.
+-- The receivable of a running loan is its amount and capitalized arrears less
+-- the principal repaid; the arrears are not earned yet. Ekuitas Saldo Awal
+-- takes the difference. amount is a debit when positive, a credit otherwise.
+CREATE TEMP TABLE opening_balances AS
+WITH running AS (
+    SELECT l.amount + COALESCE(r.capitalized, 0) - COALESCE(p.principal, 0) AS receivable,
+           COALESCE(r.capitalized, 0) AS deferred
+    FROM loans l
+    LEFT JOIN (SELECT loan_id, SUM(capitalized_amount) AS capitalized
+               FROM loan_restructurings GROUP BY loan_id) r ON r.loan_id = l.id
+    LEFT JOIN (SELECT loan_id, SUM(principal_amount) AS principal
+               FROM payments GROUP BY loan_id) p ON p.loan_id = l.id
+    WHERE l.status IN ('disbursed', 'active', 'defaulted')
+),
+balances (account_code, amount) AS (
+    SELECT '1201', (SELECT COALESCE(SUM(receivable), 0) FROM running)
+    UNION ALL SELECT '2102', -(SELECT COALESCE(SUM(credit_amount), 0) FROM payments)
+    UNION ALL SELECT '2104', -(SELECT COALESCE(SUM(deferred), 0) FROM running)
+    UNION ALL SELECT '3101', -(SELECT COALESCE(SUM(balance), 0) FROM savings_accounts WHERE type = 'pokok')
+    UNION ALL SELECT '3102', -(SELECT COALESCE(SUM(balance), 0) FROM savings_accounts WHERE type = 'wajib')
+    UNION ALL SELECT '2101', -(SELECT COALESCE(SUM(balance), 0) FROM savings_accounts WHERE type = 'sukarela')
+)
+SELECT account_code, amount FROM balances WHERE amount != 0
+UNION ALL
+SELECT '3901', -SUM(amount) FROM balances HAVING SUM(amount) != 0;
+
+INSERT INTO journal_entries (id, entry_number, entry_date, description, source)
+SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+             substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+       'JU-' || strftime('%Y%m%d', 'now') || '-0001', CURRENT_TIMESTAMP,
+       'Opening balances from before the ledger', 'manual'
+WHERE EXISTS (SELECT 1 FROM opening_balances);
+
+INSERT INTO journal_lines (entry_id, line_number, account_code, debit, credit)
+SELECT (SELECT id FROM journal_entries), ROW_NUMBER() OVER (ORDER BY account_code), account_code,
+       MAX(amount, 0), MAX(-amount, 0)
+FROM opening_balances;
+
+DROP TABLE opening_balances;
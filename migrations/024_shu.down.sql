@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS shu_member_allocations;
+DROP TABLE IF EXISTS shu_fund_allocations;
+DROP TABLE IF EXISTS shu_distributions;
+
+-- Keep the balances adding up: credited SHU becomes a deposit. Its journal
+-- entries are kept as manual ones.
+UPDATE savings_transactions SET type = 'deposit' WHERE type = 'shu';
+UPDATE journal_entries SET source = 'manual', source_id = NULL WHERE source = 'shu';
+
+DELETE FROM gl_accounts
+WHERE code IN ('2201', '2202', '2203', '2204')
+  AND code NOT IN (SELECT account_code FROM journal_lines);
+
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema
+SET sql = replace(sql, '(''deposit'', ''withdrawal'', ''interest'', ''tax'', ''shu'')', '(''deposit'', ''withdrawal'', ''interest'', ''tax'')')
+WHERE type = 'table' AND name = 'savings_transactions';
+UPDATE sqlite_schema
+SET sql = replace(sql, '''write_off'', ''recovery'', ''shu'')', '''write_off'', ''recovery'')')
+WHERE type = 'table' AND name = 'journal_entries';
+PRAGMA writable_schema = RESET;
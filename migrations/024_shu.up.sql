@@ -0,0 +1,61 @@
+-- SHU (sisa hasil usaha) distribution. The members' shares are credited to
+-- their simpanan sukarela as their own savings transaction type and the
+-- funds are booked in one journal entry of source 'shu'. Both CHECKs are
+-- widened by editing the stored schema, as for the savings interest.
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema
+SET sql = replace(sql, '(''deposit'', ''withdrawal'', ''interest'', ''tax'')', '(''deposit'', ''withdrawal'', ''interest'', ''tax'', ''shu'')')
+WHERE type = 'table' AND name = 'savings_transactions';
+UPDATE sqlite_schema
+SET sql = replace(sql, '''write_off'', ''recovery'')', '''write_off'', ''recovery'', ''shu'')')
+WHERE type = 'table' AND name = 'journal_entries';
+PRAGMA writable_schema = RESET;
+
+-- Funds set aside from the SHU; cadangan is kept in 3201
+INSERT OR IGNORE INTO gl_accounts (code, name, type, system) VALUES
+    ('2201', 'Dana Pengurus', 'liability', FALSE),
+    ('2202', 'Dana Karyawan', 'liability', FALSE),
+    ('2203', 'Dana Pendidikan', 'liability', FALSE),
+    ('2204', 'Dana Sosial', 'liability', FALSE);
+
+-- One distribution per financial year, with the percentages it was made with
+CREATE TABLE shu_distributions (
+    id TEXT PRIMARY KEY,
+    year INTEGER UNIQUE NOT NULL,
+    net_surplus INTEGER NOT NULL,
+    jasa_modal_percent REAL NOT NULL,
+    jasa_usaha_percent REAL NOT NULL,
+    jasa_modal INTEGER NOT NULL, -- shared by the members' simpanan pokok and wajib
+    jasa_usaha INTEGER NOT NULL, -- shared by the loan interest they paid
+    total_savings INTEGER NOT NULL,
+    total_interest INTEGER NOT NULL,
+    posted_by TEXT,
+    posted_at DATETIME NOT NULL,
+    FOREIGN KEY (posted_by) REFERENCES users(id)
+);
+
+CREATE TABLE shu_fund_allocations (
+    distribution_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    percent REAL NOT NULL,
+    account_code TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    PRIMARY KEY (distribution_id, name),
+    FOREIGN KEY (distribution_id) REFERENCES shu_distributions(id) ON DELETE CASCADE,
+    FOREIGN KEY (account_code) REFERENCES gl_accounts(code)
+);
+
+-- transaction_id is the sukarela line the member's share was credited with
+CREATE TABLE shu_member_allocations (
+    distribution_id TEXT NOT NULL,
+    customer_id TEXT NOT NULL,
+    savings INTEGER NOT NULL,
+    interest_paid INTEGER NOT NULL,
+    jasa_modal INTEGER NOT NULL,
+    jasa_usaha INTEGER NOT NULL,
+    transaction_id TEXT,
+    PRIMARY KEY (distribution_id, customer_id),
+    FOREIGN KEY (distribution_id) REFERENCES shu_distributions(id) ON DELETE CASCADE,
+    FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE,
+    FOREIGN KEY (transaction_id) REFERENCES savings_transactions(id)
+);
@@ -0,0 +1 @@
+ALTER TABLE loan_installments DROP COLUMN arrears_interest;
@@ -0,0 +1,5 @@
+-- Interest in arrears carried onto the first installment of a restructured
+-- schedule. It is part of interest_due but was already owed when the loan
+-- was restructured, so early settlement collects it in full rather than pro
+-- rata over the period.
+ALTER TABLE loan_installments ADD COLUMN arrears_interest INTEGER NOT NULL DEFAULT 0;
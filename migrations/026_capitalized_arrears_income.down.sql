@@ -0,0 +1,3 @@
+ALTER TABLE payments DROP COLUMN capitalized_penalty;
+ALTER TABLE payments DROP COLUMN capitalized_interest;
+ALTER TABLE loan_restructurings DROP COLUMN capitalized_penalty;
@@ -0,0 +1,9 @@
+-- Arrears capitalized by a restructuring sit in Bunga dan Denda Ditangguhkan
+-- until the principal they were added to is repaid. capitalized_penalty is
+-- the penalty part of capitalized_amount; restructurings from before this
+-- migration count wholly as interest.
+ALTER TABLE loan_restructurings ADD COLUMN capitalized_penalty INTEGER NOT NULL DEFAULT 0;
+
+-- Capitalized interest and penalties earned by the principal of a payment
+ALTER TABLE payments ADD COLUMN capitalized_interest INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE payments ADD COLUMN capitalized_penalty INTEGER NOT NULL DEFAULT 0;
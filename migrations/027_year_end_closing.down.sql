@@ -0,0 +1,13 @@
+-- Closing entries are kept as manual ones
+UPDATE journal_entries SET source = 'manual', source_id = NULL WHERE source = 'closing';
+
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema
+SET sql = replace(sql, '''recovery'', ''shu'', ''closing'')', '''recovery'', ''shu'')')
+WHERE type = 'table' AND name = 'journal_entries';
+PRAGMA writable_schema = RESET;
+
+-- Editing sqlite_schema leaves the schema cookie as it was, so connections
+-- that cached the old CHECK would keep it. Any DDL bumps the cookie.
+CREATE TABLE year_end_closing_schema_change (id INTEGER);
+DROP TABLE year_end_closing_schema_change;
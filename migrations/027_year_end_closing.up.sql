@@ -0,0 +1,13 @@
+-- Closing a financial year moves the balances of the income and expense
+-- accounts to SHU Belum Dibagi in one journal entry of source 'closing',
+-- dated the last day of the year.
+PRAGMA writable_schema = ON;
+UPDATE sqlite_schema
+SET sql = replace(sql, '''recovery'', ''shu'')', '''recovery'', ''shu'', ''closing'')')
+WHERE type = 'table' AND name = 'journal_entries';
+PRAGMA writable_schema = RESET;
+
+-- Editing sqlite_schema leaves the schema cookie as it was, so connections
+-- that cached the old CHECK would keep it. Any DDL bumps the cookie.
+CREATE TABLE year_end_closing_schema_change (id INTEGER);
+DROP TABLE year_end_closing_schema_change;
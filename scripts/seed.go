@@ -48,8 +48,13 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	encryptor, err := db.LoadEncryptor(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load encryption key: %v", err)
+	}
+
 	// Run seeder
-	seeder := database.NewSeeder(db)
+	seeder := database.NewSeeder(db, encryptor)
 	if err := seeder.SeedAll(); err != nil {
 		log.Fatalf("Failed to seed database: %v", err)
 	}
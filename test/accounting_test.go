@@ -0,0 +1,103 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/models"
+)
+
+func TestValidateEntry(t *testing.T) {
+	line := func(code string, debit, credit int64) models.JournalLine {
+		return models.JournalLine{AccountCode: code, Debit: debit, Credit: credit}
+	}
+
+	testCases := []struct {
+		name  string
+		lines []models.JournalLine
+		valid bool
+	}{
+		{"balanced", []models.JournalLine{line("5202", 150000, 0), line("1101", 0, 150000)}, true},
+		{"unbalanced", []models.JournalLine{line("5202", 150000, 0), line("1101", 0, 100000)}, false},
+		{"single line", []models.JournalLine{line("1101", 0, 0)}, false},
+		{"debit and credit on one line", []models.JournalLine{line("5202", 100, 100), line("1101", 0, 0)}, false},
+		{"negative", []models.JournalLine{line("5202", -100, 0), line("1101", 0, -100)}, false},
+	}
+
+	for _, tc := range testCases {
+		err := accounting.ValidateEntry(models.JournalEntry{Description: tc.name, Lines: tc.lines})
+		if (err == nil) != tc.valid {
+			t.Errorf("%s: got error %v, want valid %v", tc.name, err, tc.valid)
+		}
+	}
+}
+
+func TestPaymentPosting(t *testing.T) {
+	// Rp 1.050.000 in cash plus Rp 20.000 of credit pays a penalty, interest
+	// and principal; Rp 5.000 is left as credit
+	payment := models.Payment{
+		ID:              uuid.New(),
+		ReceiptNumber:   "KW-20260101-0001",
+		Amount:          1050000,
+		PenaltyAmount:   15000,
+		InterestAmount:  100000,
+		PrincipalAmount: 950000,
+		CreditAmount:    -15000,
+		Method:          models.PaymentMethodTransfer,
+	}
+
+	entry := accounting.Payment(payment, "KSP-001")
+	if err := accounting.ValidateEntry(entry); err != nil {
+		t.Fatalf("payment entry: %v", err)
+	}
+	if entry.Lines[0].AccountCode != accounting.AccountBank || entry.Lines[0].Debit != 1050000 {
+		t.Errorf("Expected Rp 1.050.000 debited to the bank, got %+v", entry.Lines[0])
+	}
+
+	reversal := payment
+	reversal.Amount, reversal.PenaltyAmount, reversal.InterestAmount = -payment.Amount, -payment.PenaltyAmount, -payment.InterestAmount
+	reversal.PrincipalAmount, reversal.CreditAmount = -payment.PrincipalAmount, -payment.CreditAmount
+	reversal.ReversalOf = &payment.ID
+
+	reversed := accounting.Payment(reversal, "KSP-001")
+	if err := accounting.ValidateEntry(reversed); err != nil {
+		t.Fatalf("reversal entry: %v", err)
+	}
+	for i, line := range reversed.Lines {
+		if line.AccountCode != entry.Lines[i].AccountCode || line.Debit != entry.Lines[i].Credit || line.Credit != entry.Lines[i].Debit {
+			t.Errorf("reversal line %d %+v does not mirror %+v", i+1, line, entry.Lines[i])
+		}
+	}
+}
+
+func TestDisbursementPosting(t *testing.T) {
+	loan := models.Loan{ID: uuid.New(), ContractNumber: "KSP-002", Amount: 10000000, AdminFee: 50000, ProvisionFee: 100000}
+
+	entry := accounting.Disbursement(loan, loan.CreatedAt, nil)
+	if err := accounting.ValidateEntry(entry); err != nil {
+		t.Fatalf("disbursement entry: %v", err)
+	}
+	if len(entry.Lines) != 4 || entry.Lines[1].AccountCode != accounting.AccountKas || entry.Lines[1].Credit != 9850000 {
+		t.Errorf("Expected Rp 9.850.000 paid out of Kas, got %+v", entry.Lines)
+	}
+}
+
+func TestWriteOffPosting(t *testing.T) {
+	// Rp 300.000 of the Rp 2.000.000 written off is capitalized interest
+	// that was never earned, so only the rest is an expense
+	entry := accounting.WriteOff(uuid.New(), "KSP-003", 2000000, 300000, time.Now(), nil)
+	if err := accounting.ValidateEntry(entry); err != nil {
+		t.Fatalf("write-off entry: %v", err)
+	}
+
+	debits := map[string]int64{}
+	for _, line := range entry.Lines {
+		debits[line.AccountCode] += line.Debit - line.Credit
+	}
+	if debits[accounting.AccountBungaDitangguhkan] != 300000 || debits[accounting.AccountBebanPenghapusan] != 1700000 ||
+		debits[accounting.AccountPiutangPinjaman] != -2000000 {
+		t.Errorf("Unexpected write-off lines %+v", entry.Lines)
+	}
+}
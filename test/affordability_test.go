@@ -0,0 +1,39 @@
+package test
+
+import (
+	"testing"
+
+	"koperasi-app/internal/services"
+)
+
+func TestAssessAffordability(t *testing.T) {
+	tests := []struct {
+		name           string
+		income         int64
+		monthlyPayment int64
+		existing       int64
+		other          int64
+		maxDSR         float64
+		wantDSR        float64
+		wantExceeded   bool
+	}{
+		{"within limit", 10000000, 2000000, 500000, 0, 30, 25, false},
+		{"at the limit", 10000000, 2000000, 500000, 500000, 30, 30, false},
+		{"above the limit", 10000000, 2000000, 500000, 500001, 30, 30.00, true},
+		{"no income", 0, 1000000, 0, 0, 30, 0, true},
+		{"check disabled", 1000000, 900000, 0, 0, 0, 90, false},
+	}
+
+	for _, tt := range tests {
+		got := services.AssessAffordability(tt.income, tt.monthlyPayment, tt.existing, tt.other, tt.maxDSR)
+		if got.DSRPercent != tt.wantDSR {
+			t.Errorf("%s: expected DSR %.2f%%, got %.2f%%", tt.name, tt.wantDSR, got.DSRPercent)
+		}
+		if got.Exceeded != tt.wantExceeded {
+			t.Errorf("%s: expected exceeded %v, got %v", tt.name, tt.wantExceeded, got.Exceeded)
+		}
+		if want := tt.monthlyPayment + tt.existing + tt.other; got.TotalObligations != want {
+			t.Errorf("%s: expected total obligations %d, got %d", tt.name, want, got.TotalObligations)
+		}
+	}
+}
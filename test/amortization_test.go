@@ -0,0 +1,169 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/utils"
+)
+
+func TestAnnuityPayment(t *testing.T) {
+	// Rp 10 juta at 12% p.a. over 12 months
+	if got := utils.AnnuityPayment(10000000, 0.12/12, 12); got != 888488 {
+		t.Errorf("Expected monthly payment 888488, got %d", got)
+	}
+
+	if got := utils.AnnuityPayment(1200000, 0, 12); got != 100000 {
+		t.Errorf("Expected zero-rate payment 100000, got %d", got)
+	}
+}
+
+func TestAmortizationSchedule(t *testing.T) {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		principal int64
+		rate      float64
+		term      int
+	}{
+		{10000000, 12, 12},
+		{5000000, 18.5, 7},
+		{1000001, 0, 3},
+		{75000000, 24, 60},
+	}
+
+	for _, tc := range testCases {
+		schedule, err := utils.AmortizationSchedule(tc.principal, tc.rate, tc.term, start, models.RepaymentFrequencyMonthly)
+		if err != nil {
+			t.Fatalf("Failed to build schedule for %d: %v", tc.principal, err)
+		}
+
+		if len(schedule) != tc.term {
+			t.Errorf("Expected %d installments, got %d", tc.term, len(schedule))
+			continue
+		}
+
+		var principalSum int64
+		balance := tc.principal
+		for _, line := range schedule {
+			principalSum += line.PrincipalDue
+			balance -= line.PrincipalDue
+
+			if line.AmountDue != line.PrincipalDue+line.InterestDue {
+				t.Errorf("Installment %d: amount %d != principal %d + interest %d",
+					line.Number, line.AmountDue, line.PrincipalDue, line.InterestDue)
+			}
+			if line.OutstandingBalance != balance {
+				t.Errorf("Installment %d: expected outstanding %d, got %d", line.Number, balance, line.OutstandingBalance)
+			}
+		}
+
+		if principalSum != tc.principal {
+			t.Errorf("Principal column sums to %d, expected %d", principalSum, tc.principal)
+		}
+		if last := schedule[len(schedule)-1]; last.OutstandingBalance != 0 {
+			t.Errorf("Expected zero balance after last installment, got %d", last.OutstandingBalance)
+		}
+	}
+
+	// First installment interest is one month on the full principal
+	schedule, _ := utils.AmortizationSchedule(10000000, 12, 12, start, models.RepaymentFrequencyMonthly)
+	if schedule[0].InterestDue != 100000 {
+		t.Errorf("Expected first interest 100000, got %d", schedule[0].InterestDue)
+	}
+	if !schedule[0].DueDate.Equal(start.AddDate(0, 1, 0)) {
+		t.Errorf("Expected first due date one month after start, got %v", schedule[0].DueDate)
+	}
+
+	if _, err := utils.AmortizationSchedule(0, 12, 12, start, models.RepaymentFrequencyMonthly); err == nil {
+		t.Errorf("Expected error for zero principal")
+	}
+}
+
+func TestFlatAndDecliningSchedules(t *testing.T) {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	// Rp 12 juta at flat 1.5% per month
+	flat, err := utils.FlatSchedule(12000000, 18, 12, start, models.RepaymentFrequencyMonthly)
+	if err != nil {
+		t.Fatalf("Failed to build flat schedule: %v", err)
+	}
+	for _, line := range flat {
+		if line.InterestDue != 180000 || line.AmountDue != 1180000 {
+			t.Errorf("Flat installment %d: expected 1000000 + 180000, got %d + %d",
+				line.Number, line.PrincipalDue, line.InterestDue)
+		}
+	}
+
+	declining, err := utils.DecliningBalanceSchedule(10000000, 12, 3, start, models.RepaymentFrequencyMonthly)
+	if err != nil {
+		t.Fatalf("Failed to build declining balance schedule: %v", err)
+	}
+	var principalSum int64
+	for i, line := range declining {
+		principalSum += line.PrincipalDue
+		if i > 0 && line.AmountDue >= declining[i-1].AmountDue {
+			t.Errorf("Declining installment %d (%d) is not smaller than the previous one (%d)",
+				line.Number, line.AmountDue, declining[i-1].AmountDue)
+		}
+	}
+	if principalSum != 10000000 {
+		t.Errorf("Principal column sums to %d, expected 10000000", principalSum)
+	}
+	if declining[0].InterestDue != 100000 || declining[2].PrincipalDue != 3333334 {
+		t.Errorf("Unexpected declining schedule: %+v", declining)
+	}
+}
+
+func TestWeeklySchedules(t *testing.T) {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	// Rp 5.2 juta at 26% p.a. is 0.5% per week
+	weekly, err := utils.AmortizationSchedule(5200000, 26, 52, start, models.RepaymentFrequencyWeekly)
+	if err != nil {
+		t.Fatalf("Failed to build weekly schedule: %v", err)
+	}
+	if weekly[0].InterestDue != 26000 {
+		t.Errorf("Expected first weekly interest 26000, got %d", weekly[0].InterestDue)
+	}
+	for i, line := range weekly {
+		if want := start.AddDate(0, 0, 7*(i+1)); !line.DueDate.Equal(want) {
+			t.Errorf("Weekly installment %d: expected due date %v, got %v", line.Number, want, line.DueDate)
+		}
+	}
+
+	flat, err := utils.FlatSchedule(5200000, 26, 26, start, models.RepaymentFrequencyBiweekly)
+	if err != nil {
+		t.Fatalf("Failed to build biweekly flat schedule: %v", err)
+	}
+	if flat[0].InterestDue != 52000 || flat[0].PrincipalDue != 200000 {
+		t.Errorf("Expected biweekly flat installment 200000 + 52000, got %d + %d",
+			flat[0].PrincipalDue, flat[0].InterestDue)
+	}
+	if last := flat[len(flat)-1]; !last.DueDate.Equal(start.AddDate(0, 0, 14*26)) {
+		t.Errorf("Expected last biweekly due date 364 days after start, got %v", last.DueDate)
+	}
+
+	if got := models.RepaymentFrequencyWeekly.MonthlyEquivalent(120000); got != 520000 {
+		t.Errorf("Expected weekly 120000 to be 520000 a month, got %d", got)
+	}
+}
+
+func TestFlatToEffectiveRate(t *testing.T) {
+	// Flat 1.5% per month over a year is roughly 32% effective
+	rate := utils.FlatToEffectiveRate(18, 12, models.RepaymentFrequencyMonthly)
+	if rate < 31.5 || rate > 32.5 {
+		t.Errorf("Expected effective rate around 32%%, got %.2f", rate)
+	}
+
+	// The annuity at the effective rate matches the flat installment
+	payment := utils.AnnuityPayment(12000000, rate/100/12, 12)
+	if payment < 1179900 || payment > 1180100 {
+		t.Errorf("Expected annuity payment close to 1180000, got %d", payment)
+	}
+
+	if got := utils.FlatToEffectiveRate(0, 12, models.RepaymentFrequencyMonthly); got != 0 {
+		t.Errorf("Expected 0 for zero flat rate, got %.2f", got)
+	}
+}
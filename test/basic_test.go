@@ -1,8 +1,12 @@
 package test
 
 import (
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
+	"filippo.io/age"
 	"koperasi-app/internal/utils"
 )
 
@@ -91,6 +95,12 @@ func TestEmailValidation(t *testing.T) {
 }
 
 func TestEncryption(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	encryptor := utils.NewEncryptor(identity, 1)
+
 	testData := []string{
 		"1234567890123456", // NIK
 		"test@example.com", // Email
@@ -100,14 +110,14 @@ func TestEncryption(t *testing.T) {
 
 	for _, data := range testData {
 		// Test encryption
-		encrypted, err := utils.Encrypt(data)
+		encrypted, err := encryptor.Encrypt(data)
 		if err != nil {
 			t.Errorf("Failed to encrypt data %s: %v", data, err)
 			continue
 		}
 
 		// Test decryption
-		decrypted, err := utils.Decrypt(encrypted)
+		decrypted, err := encryptor.Decrypt(encrypted)
 		if err != nil {
 			t.Errorf("Failed to decrypt data %s: %v", encrypted, err)
 			continue
@@ -120,6 +130,71 @@ func TestEncryption(t *testing.T) {
 	}
 }
 
+func TestBlindIndex(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	encryptor := utils.NewEncryptor(identity, 1)
+
+	// Different spellings of the same phone must share an index
+	phones := []string{"081234567890", "0812-3456-7890", "+6281234567890", "62 812 3456 7890"}
+	expected := encryptor.BlindIndex(utils.NormalizeIndonesianPhone(phones[0]))
+	for _, phone := range phones[1:] {
+		if got := encryptor.BlindIndex(utils.NormalizeIndonesianPhone(phone)); got != expected {
+			t.Errorf("Blind index for %s differs from %s", phone, phones[0])
+		}
+	}
+
+	if encryptor.BlindIndex("") != "" {
+		t.Errorf("Expected empty value to have an empty blind index")
+	}
+
+	// The index is keyed: another key gives another index
+	otherIdentity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	other := utils.NewEncryptor(otherIdentity, 2)
+	if other.BlindIndex("3374010101851234") == encryptor.BlindIndex("3374010101851234") {
+		t.Errorf("Expected blind index to depend on the key")
+	}
+}
+
+func TestIdentityFile(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "keys", "encryption.key")
+	if err := utils.WriteIdentityFile(keyFile, identity); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	// Existing key files must never be overwritten
+	if err := utils.WriteIdentityFile(keyFile, identity); err == nil {
+		t.Errorf("Expected writing over an existing key file to fail")
+	}
+
+	loaded, err := utils.LoadIdentityFile(keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load key file: %v", err)
+	}
+	if loaded.String() != identity.String() {
+		t.Errorf("Loaded identity does not match the written one")
+	}
+
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(keyFile, 0644); err != nil {
+			t.Fatalf("Failed to chmod key file: %v", err)
+		}
+		if _, err := utils.LoadIdentityFile(keyFile); err == nil {
+			t.Errorf("Expected world-readable key file to be rejected")
+		}
+	}
+}
+
 func TestMasking(t *testing.T) {
 	testCases := []struct {
 		input    string
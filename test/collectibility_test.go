@@ -0,0 +1,40 @@
+package test
+
+import (
+	"testing"
+
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/services"
+)
+
+func TestClassifyCollectibility(t *testing.T) {
+	tests := []struct {
+		dpd            int
+		restructured   bool
+		timelyPayments int
+		want           models.Collectibility
+	}{
+		{0, false, 0, models.CollectibilityLancar},
+		{1, false, 0, models.CollectibilityDPK},
+		{90, false, 0, models.CollectibilityDPK},
+		{91, false, 0, models.CollectibilityKurangLancar},
+		{120, false, 0, models.CollectibilityKurangLancar},
+		{121, false, 0, models.CollectibilityDiragukan},
+		{180, false, 0, models.CollectibilityDiragukan},
+		{181, false, 0, models.CollectibilityMacet},
+		{0, true, 2, models.CollectibilityKurangLancar},
+		{0, true, 3, models.CollectibilityLancar},
+		{150, true, 0, models.CollectibilityDiragukan},
+	}
+
+	for _, tt := range tests {
+		got := services.ClassifyCollectibility(tt.dpd, tt.restructured, tt.timelyPayments)
+		if got != tt.want {
+			t.Errorf("ClassifyCollectibility(%d, %v, %d) = %d, want %d", tt.dpd, tt.restructured, tt.timelyPayments, got, tt.want)
+		}
+	}
+
+	if rate := services.ProvisionRate(models.CollectibilityMacet); rate != 100 {
+		t.Errorf("Expected 100%% provision for Macet, got %.1f%%", rate)
+	}
+}
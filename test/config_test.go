@@ -0,0 +1,55 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"koperasi-app/internal/config"
+)
+
+func TestLoadDefaultsMissingSections(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".koperasi"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(home, ".koperasi", "config.json"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A config written before the sections existed gets their defaults
+	write(`{"app": {"name": "Koperasi Lama"}}`)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Penalty.Type != config.PenaltyTypePercentage {
+		t.Errorf("Expected default penalties, got %+v", cfg.Penalty)
+	}
+	if cfg.Delinquency.DefaultDPD != 90 {
+		t.Errorf("Expected default delinquency settings, got %+v", cfg.Delinquency)
+	}
+	if cfg.Affordability.MaxDSRPercent != 30 {
+		t.Errorf("Expected the default DSR limit, got %+v", cfg.Affordability)
+	}
+
+	// Sections that are there but zero stay disabled
+	write(`{"app": {"name": "Koperasi Lama"}, "penalty": {"type": ""}, "delinquency": {"default_dpd": 0}, "affordability": {"max_dsr_percent": 0}}`)
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Penalty.Type != "" {
+		t.Errorf("Expected penalties to stay disabled, got %+v", cfg.Penalty)
+	}
+	if cfg.Delinquency.DefaultDPD != 0 {
+		t.Errorf("Expected automatic defaulting to stay disabled, got %+v", cfg.Delinquency)
+	}
+	if cfg.Affordability.MaxDSRPercent != 0 {
+		t.Errorf("Expected the DSR check to stay disabled, got %+v", cfg.Affordability)
+	}
+}
@@ -0,0 +1,138 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/database"
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/services"
+)
+
+// testEnv is a migrated and seeded database in a temporary home directory,
+// for tests that need to go through the services
+type testEnv struct {
+	db       *database.DB
+	cfg      *config.Config
+	loans    *services.LoanService
+	payments *services.PaymentService
+
+	adminID      string
+	superadminID string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	// Migrations are read from the module root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	db, err := database.New(cfg)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.RunMigrations(); err != nil {
+		t.Fatal(err)
+	}
+	encryptor, err := db.LoadEncryptor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.NewSeeder(db, encryptor).SeedAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	env := &testEnv{
+		db:       db,
+		cfg:      cfg,
+		loans:    services.NewLoanService(db, cfg, services.NewCreditScoringService(db, cfg, encryptor)),
+		payments: services.NewPaymentService(db, services.NewAuditService(db)),
+	}
+	env.queryRow(t, "SELECT id FROM users WHERE email = 'admin@koperasi.com'").Scan(&env.adminID)
+	env.queryRow(t, "SELECT id FROM users WHERE email = 'superadmin@koperasi.com'").Scan(&env.superadminID)
+
+	// Seeded loans hold the contract numbers new loans would be given
+	env.exec(t, "UPDATE loans SET contract_number = 'SEED-' || contract_number")
+
+	return env
+}
+
+// overdueLoan disburses a new loan and moves it back by months, so that
+// many installments are due and unpaid
+func (e *testEnv) overdueLoan(t *testing.T, months int) *models.Loan {
+	t.Helper()
+
+	var customerID, productID string
+	e.queryRow(t, "SELECT id FROM customers WHERE id NOT IN (SELECT customer_id FROM loans) LIMIT 1").Scan(&customerID)
+	e.queryRow(t, "SELECT id FROM loan_products WHERE code = 'PRODUKTIF'").Scan(&productID)
+	e.exec(t, `
+		UPDATE customers SET created_at = date('now', '-2 years'), ktp_verified = TRUE, monthly_income = 20000000
+		WHERE id = ?`, customerID)
+
+	loan, err := e.loans.CreateLoan(services.LoanCreateRequest{
+		CustomerID: customerID,
+		ProductID:  productID,
+		Amount:     12000000,
+		Term:       12,
+		CreatedBy:  e.adminID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create loan: %v", err)
+	}
+	e.exec(t, "UPDATE loans SET status = 'approved' WHERE id = ?", loan.ID.String())
+	if _, err := e.loans.DisburseLoan(loan.ID.String(), e.adminID); err != nil {
+		t.Fatalf("Failed to disburse loan: %v", err)
+	}
+
+	e.exec(t, "UPDATE loans SET disbursed_at = ? WHERE id = ?", time.Now().AddDate(0, -months, 0), loan.ID.String())
+	e.exec(t, "UPDATE loan_installments SET due_date = date(due_date, ?) WHERE loan_id = ?",
+		fmt.Sprintf("-%d months", months), loan.ID.String())
+
+	loan, err = e.loans.GetLoan(loan.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loan
+}
+
+func (e *testEnv) exec(t *testing.T, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := e.db.Exec(query, args...); err != nil {
+		t.Fatalf("Failed to run %q: %v", query, err)
+	}
+}
+
+// queryRow fails the test if the row cannot be scanned
+func (e *testEnv) queryRow(t *testing.T, query string, args ...interface{}) scanner {
+	t.Helper()
+	return scanner{t: t, row: e.db.QueryRow(query, args...)}
+}
+
+type scanner struct {
+	t   *testing.T
+	row interface{ Scan(...interface{}) error }
+}
+
+func (s scanner) Scan(dest ...interface{}) {
+	s.t.Helper()
+	if err := s.row.Scan(dest...); err != nil {
+		s.t.Fatalf("Failed to scan row: %v", err)
+	}
+}
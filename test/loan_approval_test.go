@@ -0,0 +1,44 @@
+package test
+
+import (
+	"testing"
+
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/services"
+)
+
+func TestApprovalLimits(t *testing.T) {
+	rules := config.ApprovalConfig{
+		Limits: map[string]int64{
+			"karyawan":   0,
+			"admin":      10000000,
+			"superadmin": -1,
+		},
+		MultiLevelThreshold: 50000000,
+		MultiLevelApprovals: 2,
+	}
+
+	tests := []struct {
+		role    models.UserRole
+		amount  int64
+		allowed bool
+	}{
+		{models.RoleKaryawan, 1000000, false},
+		{models.RoleAdmin, 10000000, true},
+		{models.RoleAdmin, 10000001, false},
+		{models.RoleSuperadmin, 500000000, true},
+	}
+	for _, tt := range tests {
+		if got := services.CanApproveAmount(rules, tt.role, tt.amount); got != tt.allowed {
+			t.Errorf("CanApproveAmount(%s, %d) = %v, want %v", tt.role, tt.amount, got, tt.allowed)
+		}
+	}
+
+	if got := services.ApprovalsRequired(rules, 50000000); got != 1 {
+		t.Errorf("Expected 1 approval at the threshold, got %d", got)
+	}
+	if got := services.ApprovalsRequired(rules, 50000001); got != 2 {
+		t.Errorf("Expected 2 approvals above the threshold, got %d", got)
+	}
+}
@@ -0,0 +1,25 @@
+package test
+
+import (
+	"testing"
+
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/services"
+)
+
+func TestLoanFees(t *testing.T) {
+	product := &models.LoanProduct{AdminFee: 50000, ProvisionFeeRate: 1.5}
+
+	adminFee, provisionFee := services.LoanFees(product, 10000000)
+	if adminFee != 50000 {
+		t.Errorf("Expected admin fee 50000, got %d", adminFee)
+	}
+	if provisionFee != 150000 {
+		t.Errorf("Expected provision fee 150000, got %d", provisionFee)
+	}
+
+	// Provision is rounded to the rupiah
+	if _, provisionFee := services.LoanFees(product, 333333); provisionFee != 5000 {
+		t.Errorf("Expected rounded provision fee 5000, got %d", provisionFee)
+	}
+}
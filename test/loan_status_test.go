@@ -0,0 +1,127 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/services"
+)
+
+func TestCanTransitionLoan(t *testing.T) {
+	tests := []struct {
+		from, to models.LoanStatus
+		allowed  bool
+	}{
+		{models.LoanStatusPending, models.LoanStatusApproved, true},
+		{models.LoanStatusPending, models.LoanStatusDisbursed, false},
+		{models.LoanStatusApproved, models.LoanStatusCancelled, true},
+		{models.LoanStatusDisbursed, models.LoanStatusCancelled, false},
+		{models.LoanStatusActive, models.LoanStatusDefaulted, true},
+		{models.LoanStatusDefaulted, models.LoanStatusActive, true},
+		{models.LoanStatusCompleted, models.LoanStatusActive, true},
+		{models.LoanStatusCompleted, models.LoanStatusPending, false},
+		{models.LoanStatusCancelled, models.LoanStatusPending, false},
+		{models.LoanStatusDefaulted, models.LoanStatusWrittenOff, true},
+		{models.LoanStatusDisbursed, models.LoanStatusWrittenOff, false},
+		{models.LoanStatusWrittenOff, models.LoanStatusActive, false},
+	}
+
+	for _, tt := range tests {
+		if got := services.CanTransitionLoan(tt.from, tt.to); got != tt.allowed {
+			t.Errorf("CanTransitionLoan(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.allowed)
+		}
+	}
+}
+
+func TestDefaultedLoanServicing(t *testing.T) {
+	env := newTestEnv(t)
+	loan := env.overdueLoan(t, 4)
+	now := time.Now()
+
+	if _, err := env.loans.DefaultDelinquentLoans(env.cfg.Delinquency.DefaultDPD, now); err != nil {
+		t.Fatal(err)
+	}
+	defaulted, err := env.loans.GetLoan(loan.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaulted.Status != models.LoanStatusDefaulted {
+		t.Fatalf("Expected loan to be defaulted, got %s", defaulted.Status)
+	}
+
+	updated, err := env.loans.AccruePenalties(env.cfg.Penalty, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated == 0 {
+		t.Error("Expected penalties to accrue on a defaulted loan")
+	}
+
+	quote, err := env.loans.QuoteEarlySettlement(loan.ID.String(), now)
+	if err != nil {
+		t.Fatalf("Expected a defaulted loan to be quoted for settlement, got %v", err)
+	}
+	if quote.UnpaidPenalty == 0 {
+		t.Error("Expected the quote to include the accrued penalties")
+	}
+
+	_, err = env.payments.PayLoan(services.LoanPaymentRequest{
+		LoanID:   loan.ID.String(),
+		Amount:   1000000,
+		PostedBy: env.adminID,
+	})
+	if err != nil {
+		t.Fatalf("Expected a payment on a defaulted loan to be taken, got %v", err)
+	}
+
+	quote, err = env.loans.QuoteEarlySettlement(loan.ID.String(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = env.loans.SettleLoan(services.LoanSettlementRequest{
+		LoanID:         loan.ID.String(),
+		SettlementDate: now,
+		Amount:         quote.Total,
+		Reason:         "pelunasan oleh keluarga",
+		PostedBy:       env.adminID,
+	})
+	if err != nil {
+		t.Fatalf("Expected a defaulted loan to be settled, got %v", err)
+	}
+	settled, err := env.loans.GetLoan(loan.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settled.Status != models.LoanStatusCompleted {
+		t.Errorf("Expected settled loan to be completed, got %s", settled.Status)
+	}
+}
+
+func TestOverdueInstallmentsOfClosedLoans(t *testing.T) {
+	env := newTestEnv(t)
+	loan := env.overdueLoan(t, 3)
+
+	countOverdue := func() int {
+		t.Helper()
+		installments, err := env.loans.GetOverdueInstallments()
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for _, installment := range installments {
+			if installment.LoanID == loan.ID {
+				count++
+			}
+		}
+		return count
+	}
+
+	if countOverdue() == 0 {
+		t.Fatal("Expected the loan to have overdue installments")
+	}
+	env.exec(t, "UPDATE loans SET status = 'written_off' WHERE id = ?", loan.ID.String())
+	if got := countOverdue(); got != 0 {
+		t.Errorf("Expected a written off loan to have no overdue installments, got %d", got)
+	}
+}
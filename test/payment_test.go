@@ -0,0 +1,52 @@
+package test
+
+import (
+	"testing"
+
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/services"
+)
+
+func TestAllocateInstallmentPaymentExcess(t *testing.T) {
+	installment := &models.LoanInstallment{
+		PrincipalDue: 800000,
+		InterestDue:  100000,
+		AmountDue:    900000,
+		AmountPaid:   150000,
+	}
+
+	// Only the 750000 still owed is applied; the rest is excess
+	allocation := services.AllocateInstallmentPayment(installment, 1000000)
+	if allocation.Interest != 0 || allocation.Principal != 750000 || allocation.Excess != 250000 {
+		t.Errorf("Unexpected allocation %+v", allocation)
+	}
+}
+
+func TestAllocateLoanPayment(t *testing.T) {
+	installments := []models.LoanInstallment{
+		{Number: 1, PrincipalDue: 900000, InterestDue: 100000, AmountDue: 1000000, AmountPaid: 400000},
+		{Number: 2, PrincipalDue: 900000, InterestDue: 100000, AmountDue: 1000000, PenaltyDue: 20000},
+		{Number: 3, PrincipalDue: 900000, InterestDue: 100000, AmountDue: 1000000},
+	}
+
+	// Penalty on #2 comes first, then #1 and #2 in full, then part of #3
+	allocations, remainder := services.AllocateLoanPayment(installments, 2000000)
+	if remainder != 0 {
+		t.Errorf("Expected no remainder, got %d", remainder)
+	}
+	if a := allocations[0]; a.Interest != 0 || a.Principal != 600000 {
+		t.Errorf("Unexpected allocation for #1: %+v", a)
+	}
+	if a := allocations[1]; a.Penalty != 20000 || a.Interest != 100000 || a.Principal != 900000 {
+		t.Errorf("Unexpected allocation for #2: %+v", a)
+	}
+	if a := allocations[2]; a.Interest != 100000 || a.Principal != 280000 {
+		t.Errorf("Unexpected allocation for #3: %+v", a)
+	}
+
+	// Paying more than is owed leaves a remainder
+	_, remainder = services.AllocateLoanPayment(installments, 3000000)
+	if remainder != 380000 {
+		t.Errorf("Expected remainder 380000, got %d", remainder)
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"testing"
+
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/services"
+)
+
+func TestCalculatePenalty(t *testing.T) {
+	percentage := config.PenaltyConfig{Type: config.PenaltyTypePercentage, DailyRate: 0.1}
+	if got := services.CalculatePenalty(percentage, 1000000, 5); got != 5000 {
+		t.Errorf("Expected 0.1%% x 5 days of 1000000 = 5000, got %d", got)
+	}
+
+	fixed := config.PenaltyConfig{Type: config.PenaltyTypeFixed, DailyAmount: 2500}
+	if got := services.CalculatePenalty(fixed, 1000000, 4); got != 10000 {
+		t.Errorf("Expected 2500 x 4 days = 10000, got %d", got)
+	}
+
+	if got := services.CalculatePenalty(config.PenaltyConfig{}, 1000000, 10); got != 0 {
+		t.Errorf("Expected no penalty when penalties are disabled, got %d", got)
+	}
+	if got := services.CalculatePenalty(fixed, 0, 10); got != 0 {
+		t.Errorf("Expected no penalty on a settled installment, got %d", got)
+	}
+}
+
+func TestAllocateInstallmentPayment(t *testing.T) {
+	installment := &models.LoanInstallment{
+		PrincipalDue: 800000,
+		InterestDue:  100000,
+		AmountDue:    900000,
+		PenaltyDue:   30000,
+	}
+
+	// Penalty first, then interest, then principal
+	allocation := services.AllocateInstallmentPayment(installment, 200000)
+	if allocation.Penalty != 30000 || allocation.Interest != 100000 || allocation.Principal != 70000 {
+		t.Errorf("Unexpected allocation %+v", allocation)
+	}
+
+	// A payment smaller than the penalty only reduces the penalty
+	allocation = services.AllocateInstallmentPayment(installment, 10000)
+	if allocation.Penalty != 10000 || allocation.Interest != 0 || allocation.Principal != 0 {
+		t.Errorf("Unexpected allocation %+v", allocation)
+	}
+
+	// Earlier payments already covered penalty and part of the interest
+	installment.PenaltyPaid = 30000
+	installment.AmountPaid = 60000
+	allocation = services.AllocateInstallmentPayment(installment, 100000)
+	if allocation.Penalty != 0 || allocation.Interest != 40000 || allocation.Principal != 60000 {
+		t.Errorf("Unexpected allocation %+v", allocation)
+	}
+}
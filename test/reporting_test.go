@@ -0,0 +1,36 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"koperasi-app/internal/services"
+)
+
+func TestPriorPeriod(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name       string
+		start, end time.Time
+		priorStart time.Time
+		priorEnd   time.Time
+	}{
+		{"year", date(2026, 1, 1), date(2026, 12, 31), date(2025, 1, 1), date(2025, 12, 31)},
+		{"month to date", date(2026, 10, 1), date(2026, 10, 17), date(2026, 9, 1), date(2026, 9, 17)},
+		{"month after a short one", date(2026, 3, 1), date(2026, 3, 31), date(2026, 2, 1), date(2026, 2, 28)},
+		{"day past a short month", date(2026, 3, 1), date(2026, 3, 30), date(2026, 2, 1), date(2026, 2, 28)},
+		{"quarter", date(2026, 4, 1), date(2026, 6, 30), date(2026, 1, 1), date(2026, 3, 31)},
+		{"days", date(2026, 10, 10), date(2026, 10, 16), date(2026, 10, 3), date(2026, 10, 9)},
+	}
+
+	for _, tc := range testCases {
+		start, end := services.PriorPeriod(tc.start, tc.end)
+		if !start.Equal(tc.priorStart) || !end.Equal(tc.priorEnd) {
+			t.Errorf("%s: got %s - %s, want %s - %s", tc.name, start.Format("2006-01-02"), end.Format("2006-01-02"),
+				tc.priorStart.Format("2006-01-02"), tc.priorEnd.Format("2006-01-02"))
+		}
+	}
+}
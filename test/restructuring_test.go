@@ -0,0 +1,125 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/services"
+)
+
+func TestRestructuringArrearsQuotedInFull(t *testing.T) {
+	env := newTestEnv(t)
+	loan := env.overdueLoan(t, 3)
+	today := time.Now()
+
+	before, err := env.loans.QuoteEarlySettlement(loan.ID.String(), today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.AccruedInterest == 0 {
+		t.Fatal("Expected interest in arrears before restructuring")
+	}
+
+	_, err = env.loans.RestructureLoan(services.LoanRestructureRequest{
+		LoanID:     loan.ID.String(),
+		Term:       12,
+		StartDate:  today,
+		Reason:     "gagal panen",
+		ApprovedBy: env.adminID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to restructure loan: %v", err)
+	}
+
+	// Nothing has accrued on the new schedule yet, so settling the same day
+	// owes exactly what was in arrears
+	after, err := env.loans.QuoteEarlySettlement(loan.ID.String(), today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.AccruedInterest != before.AccruedInterest {
+		t.Errorf("Expected the arrears of %d to be quoted in full, got %d", before.AccruedInterest, after.AccruedInterest)
+	}
+	if after.OutstandingPrincipal != before.OutstandingPrincipal {
+		t.Errorf("Expected outstanding principal %d, got %d", before.OutstandingPrincipal, after.OutstandingPrincipal)
+	}
+}
+
+func TestCapitalizedArrearsEarnedWhenRepaid(t *testing.T) {
+	env := newTestEnv(t)
+	loan := env.overdueLoan(t, 3)
+	today := time.Now()
+
+	if _, err := env.loans.AccruePenalties(env.cfg.Penalty, today); err != nil {
+		t.Fatal(err)
+	}
+	_, err := env.loans.RestructureLoan(services.LoanRestructureRequest{
+		LoanID:            loan.ID.String(),
+		Term:              6,
+		CapitalizeArrears: true,
+		StartDate:         today,
+		Reason:            "gagal panen",
+		ApprovedBy:        env.adminID,
+	})
+	if err != nil {
+		t.Fatalf("Failed to restructure loan: %v", err)
+	}
+	restructurings, err := env.loans.GetLoanRestructurings(loan.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	capitalized := restructurings[0]
+	if capitalized.CapitalizedAmount == 0 || capitalized.CapitalizedPenalty == 0 {
+		t.Fatalf("Expected interest and penalties to be capitalized, got %+v", capitalized)
+	}
+
+	// One installment earns part of the arrears, settling the rest earns all
+	first, err := env.payments.PayLoan(services.LoanPaymentRequest{
+		LoanID:   loan.ID.String(),
+		Amount:   1000000,
+		PostedBy: env.adminID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payment, err := env.payments.GetPayment(first.Installments[0].PaymentID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.CapitalizedInterest <= 0 || payment.CapitalizedInterest >= capitalized.CapitalizedAmount-capitalized.CapitalizedPenalty {
+		t.Errorf("Expected part of the capitalized interest to be earned, got %d", payment.CapitalizedInterest)
+	}
+
+	quote, err := env.loans.QuoteEarlySettlement(loan.ID.String(), today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = env.loans.SettleLoan(services.LoanSettlementRequest{
+		LoanID:         loan.ID.String(),
+		SettlementDate: today,
+		Amount:         quote.Total,
+		Reason:         "lunas",
+		PostedBy:       env.adminID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := services.NewReportingService(env.db, env.cfg)
+	balance, err := report.TrialBalance(services.FinancialReportRequest{PeriodStart: today, PeriodEnd: today})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !balance.Balanced {
+		t.Error("Expected the trial balance to balance")
+	}
+	for _, line := range balance.Lines {
+		if line.AccountCode != accounting.AccountBungaDitangguhkan {
+			continue
+		}
+		if line.Credit != capitalized.CapitalizedAmount || line.ClosingDebit != 0 || line.ClosingCredit != 0 {
+			t.Errorf("Expected Bunga dan Denda Ditangguhkan to be cleared, got %+v", line)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/services"
+)
+
+func TestCalculateWajibObligation(t *testing.T) {
+	opened := time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		asOf       time.Time
+		deposited  int64
+		monthsDue  int
+		monthsPaid int
+		arrears    int64
+	}{
+		{"month opened", time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC), 0, 1, 0, 50000},
+		{"across year end", time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), 100000, 4, 2, 100000},
+		{"paid ahead", time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), 175000, 2, 3, 0},
+		{"before opening", time.Date(2025, 10, 31, 0, 0, 0, 0, time.UTC), 0, 0, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		got := services.CalculateWajibObligation(50000, opened, tc.asOf, tc.deposited)
+		if got.MonthsDue != tc.monthsDue || got.MonthsPaid != tc.monthsPaid || got.Arrears != tc.arrears {
+			t.Errorf("%s: got %d months due, %d paid, arrears %d; want %d, %d, %d", tc.name,
+				got.MonthsDue, got.MonthsPaid, got.Arrears, tc.monthsDue, tc.monthsPaid, tc.arrears)
+		}
+		if got.AmountDue != 50000*int64(tc.monthsDue) {
+			t.Errorf("%s: amount due %d for %d months", tc.name, got.AmountDue, tc.monthsDue)
+		}
+	}
+}
+
+func TestSavingsInterestRate(t *testing.T) {
+	cfg := config.SavingsInterestConfig{
+		AnnualRate: 2,
+		Tiers: []config.SavingsInterestTier{
+			{MinBalance: 10000000, AnnualRate: 3},
+			{MinBalance: 50000000, AnnualRate: 4},
+		},
+	}
+
+	testCases := []struct {
+		balance int64
+		rate    float64
+	}{
+		{0, 2},
+		{9999999, 2},
+		{10000000, 3},
+		{75000000, 4},
+	}
+
+	for _, tc := range testCases {
+		if got := services.SavingsInterestRate(cfg, tc.balance); got != tc.rate {
+			t.Errorf("rate for balance %d = %v, want %v", tc.balance, got, tc.rate)
+		}
+	}
+
+	// Rp 36.500.000 at 4% p.a. earns Rp 4.000 a day
+	if got := services.DailySavingsInterest(36500000, 4); got != 4000 {
+		t.Errorf("Expected daily interest 4000, got %v", got)
+	}
+}
+
+func TestSavingsInterestTax(t *testing.T) {
+	cfg := config.SavingsInterestConfig{TaxRate: 10, TaxThreshold: 240000}
+
+	if got := services.SavingsInterestTax(cfg, 240000); got != 0 {
+		t.Errorf("Expected no tax up to the threshold, got %d", got)
+	}
+	if got := services.SavingsInterestTax(cfg, 250005); got != 25001 {
+		t.Errorf("Expected tax 25001 on all of the interest, got %d", got)
+	}
+}
@@ -0,0 +1,73 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/scoring"
+)
+
+func TestCreditScore(t *testing.T) {
+	weights := config.ScoringConfig{
+		PaymentHistory: 35,
+		KTPVerified:    10,
+		Documents:      10,
+		Age:            10,
+		Income:         20,
+		Membership:     15,
+	}
+	asOf := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	good := scoring.Applicant{
+		Installments:  12,
+		KTPVerified:   true,
+		Documents:     []models.DocumentType{models.DocumentTypeKTP, models.DocumentTypeKK},
+		DateOfBirth:   time.Date(1985, 3, 10, 0, 0, 0, 0, time.UTC),
+		MonthlyIncome: 12000000,
+		MemberSince:   time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	result := scoring.Score(good, weights, asOf)
+	if result.Score != 100 || result.Grade != "A" || len(result.Reasons) != 0 {
+		t.Errorf("Expected 100/A without reasons, got %d/%s %v", result.Score, result.Grade, result.Reasons)
+	}
+
+	bad := good
+	bad.LatePayments = 3
+	bad.MaxDPD = 120
+	bad.KTPVerified = false
+	bad.Documents = []models.DocumentType{models.DocumentTypeKTP}
+	result = scoring.Score(bad, weights, asOf)
+	// 0 + 0 + 5 + 10 + 20 + 15 of 100
+	if result.Score != 50 || result.Grade != "C" {
+		t.Errorf("Expected 50/C, got %d/%s", result.Score, result.Grade)
+	}
+	want := []string{scoring.ReasonSeriousDelinquency, scoring.ReasonLatePayments, scoring.ReasonKTPNotVerified, scoring.ReasonDocumentsIncomplete}
+	if len(result.Reasons) != len(want) {
+		t.Fatalf("Expected reasons %v, got %v", want, result.Reasons)
+	}
+	for i := range want {
+		if result.Reasons[i] != want[i] {
+			t.Errorf("Expected reasons %v, got %v", want, result.Reasons)
+			break
+		}
+	}
+}
+
+func TestApplicantAge(t *testing.T) {
+	asOf := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	if age, ok := scoring.Age(time.Date(1990, 6, 2, 0, 0, 0, 0, time.UTC), "", asOf); !ok || age != 34 {
+		t.Errorf("Expected age 34 the day before the birthday, got %d", age)
+	}
+
+	// Born 25 December 1988 (female, day + 40)
+	if age, ok := scoring.Age(time.Time{}, "3374016512880001", asOf); !ok || age != 36 {
+		t.Errorf("Expected age 36 from the NIK, got %d (ok %v)", age, ok)
+	}
+
+	if _, ok := scoring.Age(time.Time{}, "", asOf); ok {
+		t.Error("Expected unknown age without date of birth or NIK")
+	}
+}
@@ -0,0 +1,19 @@
+package test
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuoteRefusesInstallmentsWithoutSplit(t *testing.T) {
+	env := newTestEnv(t)
+	loan := env.overdueLoan(t, 2)
+
+	// Installments from before amortization schedules owe nothing by their
+	// principal and interest columns
+	env.exec(t, "UPDATE loan_installments SET principal_due = 0, interest_due = 0 WHERE loan_id = ?", loan.ID.String())
+
+	if _, err := env.loans.QuoteEarlySettlement(loan.ID.String(), time.Now()); err == nil {
+		t.Error("Expected a loan with unpaid installments to refuse a zero settlement quote")
+	}
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"koperasi-app/internal/accounting"
+	"koperasi-app/internal/config"
+	"koperasi-app/internal/models"
+	"koperasi-app/internal/services"
+)
+
+func TestAllocateSHU(t *testing.T) {
+	allocation := config.SHUConfig{
+		JasaModal: 20,
+		JasaUsaha: 25,
+		Funds: []config.SHUFund{
+			{Name: "Cadangan", Percent: 40, AccountCode: "3201"},
+			{Name: "Dana Pengurus", Percent: 15, AccountCode: "2201"},
+		},
+	}
+	members := []models.SHUMemberAllocation{
+		{CustomerName: "A", Savings: 100000, InterestPaid: 0},
+		{CustomerName: "B", Savings: 200000, InterestPaid: 300000},
+		{CustomerName: "C", Savings: 0, InterestPaid: 100000},
+	}
+
+	got := services.AllocateSHU(10000000, allocation, members)
+
+	if got.JasaModal != 2000000 || got.JasaUsaha != 2500000 {
+		t.Fatalf("Expected pools 2000000 and 2500000, got %d and %d", got.JasaModal, got.JasaUsaha)
+	}
+	if got.Funds[0].Amount != 4000000 || got.Funds[1].Amount != 1500000 {
+		t.Errorf("Expected funds 4000000 and 1500000, got %d and %d", got.Funds[0].Amount, got.Funds[1].Amount)
+	}
+
+	want := []struct{ jasaModal, jasaUsaha int64 }{
+		{666666, 0},
+		{1333333, 1875000},
+		{0, 625000},
+	}
+	for i, w := range want {
+		member := got.Members[i]
+		if member.JasaModal != w.jasaModal || member.JasaUsaha != w.jasaUsaha {
+			t.Errorf("%s: got jasa modal %d, jasa usaha %d; want %d, %d", member.CustomerName,
+				member.JasaModal, member.JasaUsaha, w.jasaModal, w.jasaUsaha)
+		}
+		if member.Total != member.JasaModal+member.JasaUsaha {
+			t.Errorf("%s: total %d is not jasa modal plus jasa usaha", member.CustomerName, member.Total)
+		}
+	}
+
+	// Rounding down leaves a rupiah of jasa modal undistributed
+	if got.Distributed != 4499999 || got.Undistributed != 1 {
+		t.Errorf("Expected 4499999 distributed and 1 left over, got %d and %d", got.Distributed, got.Undistributed)
+	}
+}
+
+func TestSHUConfigValidate(t *testing.T) {
+	valid := config.SHUConfig{JasaModal: 30, JasaUsaha: 30, Funds: []config.SHUFund{
+		{Name: "Cadangan", Percent: 40, AccountCode: "3201"},
+	}}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid allocation, got %v", err)
+	}
+
+	short := valid
+	short.JasaUsaha = 20
+	if err := short.Validate(); err == nil {
+		t.Error("Expected percentages not adding up to 100 to be refused")
+	}
+
+	expense := valid
+	expense.Funds = []config.SHUFund{{Name: "Cadangan", Percent: 40, AccountCode: "5202"}}
+	if err := expense.Validate(); err == nil {
+		t.Error("Expected a fund kept in an expense account to be refused")
+	}
+}
+
+func TestPostSHUClosesYear(t *testing.T) {
+	env := newTestEnv(t)
+	year := time.Now().Year() - 1
+	yearEnd := time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	// Rp 10.000.000 of interest and Rp 2.000.000 of expenses leave a
+	// surplus of Rp 8.000.000
+	ledger := services.NewAccountingService(env.db)
+	for _, lines := range [][]models.JournalLine{
+		{{AccountCode: accounting.AccountKas, Debit: 10000000}, {AccountCode: accounting.AccountPendapatanBunga, Credit: 10000000}},
+		{{AccountCode: accounting.AccountBebanPenghapusan, Debit: 2000000}, {AccountCode: accounting.AccountKas, Credit: 2000000}},
+	} {
+		_, err := ledger.PostJournalEntry(services.JournalEntryRequest{
+			EntryDate:   time.Date(year, 6, 30, 0, 0, 0, 0, time.UTC),
+			Description: "Saldo tahun lalu",
+			Lines:       lines,
+			PostedBy:    env.adminID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	shu := services.NewSHUService(env.db, env.cfg)
+	_, err := shu.CalculateSHU(services.SHURequest{Year: year, NetSurplus: 9000000})
+	if err == nil {
+		t.Error("Expected a net surplus above the year's surplus to be refused")
+	}
+
+	distribution, err := shu.PostSHU(services.SHURequest{Year: year, PostedBy: env.adminID})
+	if err != nil {
+		t.Fatalf("Failed to post SHU: %v", err)
+	}
+	if distribution.NetSurplus != 8000000 {
+		t.Fatalf("Expected a net surplus of 8000000, got %d", distribution.NetSurplus)
+	}
+
+	// The year is closed into SHU Belum Dibagi, which keeps what was not
+	// distributed
+	report := services.NewReportingService(env.db, env.cfg)
+	closed, err := report.TrialBalance(services.FinancialReportRequest{PeriodStart: yearEnd, PeriodEnd: yearEnd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now, err := report.TrialBalance(services.FinancialReportRequest{PeriodStart: time.Now(), PeriodEnd: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range closed.Lines {
+		closing := line.ClosingCredit - line.ClosingDebit
+		switch {
+		case line.AccountCode == accounting.AccountSHUBelumDibagi && closing != 8000000:
+			t.Errorf("Expected SHU Belum Dibagi to hold 8000000 at year end, got %d", closing)
+		case (line.AccountType == models.GLAccountIncome || line.AccountType == models.GLAccountExpense) && closing != 0:
+			t.Errorf("Expected %s to be closed, got %d", line.AccountCode, closing)
+		}
+	}
+	for _, line := range now.Lines {
+		if line.AccountCode == accounting.AccountSHUBelumDibagi && line.ClosingCredit-line.ClosingDebit != distribution.Undistributed {
+			t.Errorf("Expected SHU Belum Dibagi to keep the %d undistributed, got %+v", distribution.Undistributed, line)
+		}
+	}
+
+	// The laba rugi of a closed year still shows what it earned
+	statement, err := report.IncomeStatement(services.FinancialReportRequest{
+		PeriodStart: time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:   yearEnd,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.NetSurplus != 8000000 {
+		t.Errorf("Expected the closed year's net surplus to be 8000000, got %d", statement.NetSurplus)
+	}
+}